@@ -4,6 +4,7 @@ import (
 	"FurniSwap/pkg/config"
 	"FurniSwap/pkg/database"
 	"FurniSwap/pkg/middleware"
+	"FurniSwap/pkg/storage"
 
 	// Auth module
 	authHandler "FurniSwap/internal/modules/auth/handler"
@@ -15,6 +16,11 @@ import (
 	profileRepo "FurniSwap/internal/modules/profile/repository"
 	profileService "FurniSwap/internal/modules/profile/service"
 
+	// Category module
+	categoryHandler "FurniSwap/internal/modules/category/handler"
+	categoryRepo "FurniSwap/internal/modules/category/repository"
+	categoryService "FurniSwap/internal/modules/category/service"
+
 	// Listing module
 	listingHandler "FurniSwap/internal/modules/listing/handler"
 	listingRepo "FurniSwap/internal/modules/listing/repository"
@@ -32,9 +38,30 @@ import (
 
 	// Chat module
 	chatHandler "FurniSwap/internal/modules/chat/handler"
+	chatHub "FurniSwap/internal/modules/chat/hub"
 	chatRepo "FurniSwap/internal/modules/chat/repository"
 	chatService "FurniSwap/internal/modules/chat/service"
 
+	// Swap module
+	swapHandler "FurniSwap/internal/modules/swap/handler"
+	swapRepo "FurniSwap/internal/modules/swap/repository"
+	swapService "FurniSwap/internal/modules/swap/service"
+
+	// Moderation module
+	moderationHandler "FurniSwap/internal/modules/moderation/handler"
+	moderationRepo "FurniSwap/internal/modules/moderation/repository"
+	moderationService "FurniSwap/internal/modules/moderation/service"
+
+	// Saved search module
+	savedsearchHandler "FurniSwap/internal/modules/savedsearch/handler"
+	savedsearchRepo "FurniSwap/internal/modules/savedsearch/repository"
+	savedsearchService "FurniSwap/internal/modules/savedsearch/service"
+
+	// Offer module
+	offerHandler "FurniSwap/internal/modules/offer/handler"
+	offerRepo "FurniSwap/internal/modules/offer/repository"
+	offerService "FurniSwap/internal/modules/offer/service"
+
 	"context"
 	"database/sql"
 	"log"
@@ -64,13 +91,15 @@ func main() {
 	database.UpdateDatabaseStructure(db)
 	database.CheckDatabase(db)
 
-	// Create uploads directory if it doesn't exist
-	if err := os.MkdirAll("uploads", 0755); err != nil {
-		log.Fatalf("Error creating uploads directory: %v", err)
+	// Initialize file storage
+	fileStorage, err := storage.Init()
+	if err != nil {
+		log.Fatalf("Failed to initialize file storage: %v", err)
 	}
 
-	// Initialize router with default middleware
-	r := gin.Default()
+	// Initialize router; the access log redacts tokens passed in query strings
+	r := gin.New()
+	r.Use(middleware.Logger(), gin.Recovery())
 
 	// Configure CORS
 	r.Use(cors.New(cors.Config{
@@ -80,32 +109,61 @@ func main() {
 		AllowCredentials: true,
 	}))
 
-	// Serve static files
-	r.Static("/uploads", "./uploads")
+	// Serve uploaded files when they are stored locally; other storages serve them by their own URLs
+	if localStorage, ok := fileStorage.(*storage.Local); ok {
+		r.StaticFS("/uploads", localStorage.PublicFileSystem())
+	}
 
 	// Initialize module repositories
 	authRepository := authRepo.NewRepository(db)
 	profileRepository := profileRepo.NewRepository(db)
+	categoryRepository := categoryRepo.NewRepository(db)
 	listingRepository := listingRepo.NewRepository(db)
 	favoriteRepository := favoriteRepo.NewRepository(db)
 	purchaseRepository := purchaseRepo.NewRepository(db)
 	chatRepository := chatRepo.NewRepository(db)
+	swapRepository := swapRepo.NewRepository(db)
+	offerRepository := offerRepo.NewRepository(db)
+	moderationRepository := moderationRepo.NewRepository(db)
+	savedSearchRepository := savedsearchRepo.NewRepository(db)
 
 	// Initialize module services
 	authSvc := authService.NewService(authRepository)
 	profileSvc := profileService.NewService(profileRepository)
-	listingSvc := listingService.NewService(listingRepository)
+	categorySvc := categoryService.NewService(categoryRepository)
+	savedSearchSvc := savedsearchService.NewService(savedSearchRepository, config.Config.BaseURL)
+	listingSvc := listingService.NewService(listingRepository, savedSearchSvc)
 	favoriteSvc := favoriteService.NewService(favoriteRepository)
-	purchaseSvc := purchaseService.NewService(purchaseRepository, listingRepository)
-	chatSvc := chatService.NewService(chatRepository)
+	purchaseSvc := purchaseService.NewService(purchaseRepository)
+	chatSvc := chatService.NewService(chatRepository, chatHub.NewHub(), moderationRepository)
+	swapSvc := swapService.NewService(swapRepository, listingRepository, moderationRepository)
+	offerSvc := offerService.NewService(offerRepository, listingRepository, chatSvc, moderationRepository,
+		time.Duration(config.Config.OfferExpirationHours)*time.Hour)
+	moderationSvc := moderationService.NewService(moderationRepository)
 
 	// Initialize module handlers
 	authHandler := authHandler.NewHandler(authSvc)
 	profileHandler := profileHandler.NewHandler(profileSvc)
+	categoryHandler := categoryHandler.NewHandler(categorySvc)
 	listingHandler := listingHandler.NewHandler(listingSvc)
 	favoriteHandler := favoriteHandler.NewHandler(favoriteSvc)
 	purchaseHandler := purchaseHandler.NewHandler(purchaseSvc)
 	chatHandler := chatHandler.NewHandler(chatSvc)
+	swapHandler := swapHandler.NewHandler(swapSvc)
+	offerHandler := offerHandler.NewHandler(offerSvc)
+	moderationHandler := moderationHandler.NewHandler(moderationSvc)
+	savedSearchHandler := savedsearchHandler.NewHandler(savedSearchSvc)
+
+	// Start background workers
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	savedSearchSvc.Start(workersCtx)
+
+	// Copy avatars and listing images that are still third-party URLs to the storage
+	go func() {
+		profileSvc.MirrorURLAvatars(workersCtx)
+		listingSvc.MirrorURLImages(workersCtx)
+	}()
 
 	// Register public routes (no auth required)
 	authHandler.RegisterRoutes(r.Group(""))
@@ -150,35 +208,42 @@ func main() {
 		c.JSON(http.StatusOK, userProfile)
 	})
 
-	// Categories endpoint (public)
-	r.GET("/categories", func(c *gin.Context) {
-		var categories []struct {
-			ID   int    `db:"id" json:"id"`
-			Name string `db:"name" json:"name"`
-		}
-		err := db.Select(&categories, "SELECT id, name FROM categories ORDER BY name")
-		if err != nil {
-			log.Printf("Error getting categories: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error getting categories"})
-			return
-		}
-		c.JSON(http.StatusOK, categories)
-	})
+	// Category routes (public)
+	categoryHandler.RegisterPublicRoutes(r.Group(""))
+
+	// Reference data routes (public)
+	listingHandler.RegisterReferenceRoutes(r.Group(""))
 
 	// Public listing routes
 	publicListings := r.Group("/listings")
+	publicListings.Use(middleware.OptionalAuth(db))
 	listingHandler.RegisterPublicRoutes(publicListings)
 
+	// Saved search unsubscribe links from emails (public)
+	savedSearchHandler.RegisterPublicRoutes(r.Group(""))
+
 	// Protected API routes (auth required)
 	api := r.Group("/api")
 	api.Use(middleware.AuthRequired(db))
 	{
 		// Register module routes to protected API group
+		authHandler.RegisterProtectedRoutes(api)
 		profileHandler.RegisterRoutes(api)
 		listingHandler.RegisterProtectedRoutes(api)
 		favoriteHandler.RegisterRoutes(api)
 		purchaseHandler.RegisterRoutes(api)
 		chatHandler.RegisterRoutes(api)
+		swapHandler.RegisterRoutes(api)
+		offerHandler.RegisterRoutes(api)
+		moderationHandler.RegisterRoutes(api)
+		savedSearchHandler.RegisterRoutes(api)
+	}
+
+	// Admin API routes (admin rights required)
+	admin := api.Group("/admin")
+	admin.Use(middleware.AdminRequired(db))
+	{
+		categoryHandler.RegisterAdminRoutes(admin)
 	}
 
 	// Create HTTP server
@@ -201,6 +266,7 @@ func main() {
 	<-quit
 
 	log.Println("Server shutting down...")
+	stopWorkers()
 
 	// Create a deadline for server shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -29,6 +29,18 @@ func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
 		auth.POST("/verify", h.Verify)
 		auth.POST("/login", h.Login)
 		auth.POST("/verify-2fa", h.Verify2FA)
+		auth.POST("/refresh", h.Refresh)
+		auth.POST("/logout", h.Logout)
+		auth.POST("/password/forgot", h.ForgotPassword)
+		auth.POST("/password/reset", h.ResetPassword)
+	}
+}
+
+// RegisterProtectedRoutes registers auth routes that require authentication
+func (h *Handler) RegisterProtectedRoutes(router *gin.RouterGroup) {
+	auth := router.Group("/auth")
+	{
+		auth.POST("/logout-all", h.LogoutAll)
 	}
 }
 
@@ -40,7 +52,7 @@ func (h *Handler) Register(c *gin.Context) {
 		return
 	}
 
-	code, err := h.service.Register(req)
+	challenge, err := h.service.Register(req)
 	if err != nil {
 		if err.Error() == "user with this email already exists" {
 			c.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
@@ -51,10 +63,7 @@ func (h *Handler) Register(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Verification code sent",
-		"code":    code,
-	})
+	c.JSON(http.StatusOK, withChallenge(gin.H{"message": "Verification code sent"}, challenge))
 }
 
 // Verify handles email verification
@@ -71,6 +80,10 @@ func (h *Handler) Verify(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification code"})
 			return
 		}
+		if err.Error() == "too many attempts; request a new code" {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many attempts; request a new code"})
+			return
+		}
 		log.Printf("Error during verification: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Verification error"})
 		return
@@ -87,14 +100,14 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
-	user, code, err := h.service.Login(req)
+	user, challenge, err := h.service.Login(req)
 	if err != nil {
 		if err.Error() == "invalid email or password" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 			return
 		}
 		if err.Error() == "account not verified; new verification code sent" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Account not verified; new verification code sent"})
+			c.JSON(http.StatusForbidden, withChallenge(gin.H{"error": "Account not verified; new verification code sent"}, challenge))
 			return
 		}
 		log.Printf("Error during login: %v", err)
@@ -102,16 +115,15 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	c.JSON(http.StatusOK, withChallenge(gin.H{
 		"message": "2FA code sent to your email",
-		"code":    code,
 		"user": gin.H{
 			"id":        user.ID,
 			"email":     user.Email,
 			"name":      user.Name,
 			"last_name": user.LastName,
 		},
-	})
+	}, challenge))
 }
 
 // Verify2FA handles two-factor authentication
@@ -122,12 +134,16 @@ func (h *Handler) Verify2FA(c *gin.Context) {
 		return
 	}
 
-	user, err := h.service.Verify2FA(req)
+	user, err := h.service.Verify2FA(req, deviceInfo(c))
 	if err != nil {
 		if err.Error() == "invalid or expired 2FA code" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired 2FA code"})
 			return
 		}
+		if err.Error() == "too many attempts; request a new code" {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many attempts; request a new code"})
+			return
+		}
 		log.Printf("Error during 2FA verification: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "2FA verification error"})
 		return
@@ -135,3 +151,122 @@ func (h *Handler) Verify2FA(c *gin.Context) {
 
 	c.JSON(http.StatusOK, user)
 }
+
+// Refresh handles exchanging a refresh token for new tokens
+func (h *Handler) Refresh(c *gin.Context) {
+	var req model.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	tokens, err := h.service.Refresh(req.RefreshToken, deviceInfo(c))
+	if err != nil {
+		if err.Error() == "invalid refresh token" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+			return
+		}
+		log.Printf("Error refreshing tokens: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token refresh error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout handles ending the session of a refresh token
+func (h *Handler) Logout(c *gin.Context) {
+	var req model.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	if err := h.service.Logout(req.RefreshToken); err != nil {
+		log.Printf("Error during logout: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Logout error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll handles ending all sessions of the current user
+func (h *Handler) LogoutAll(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := h.service.LogoutAll(userID.(int)); err != nil {
+		log.Printf("Error logging out all sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Logout error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all devices"})
+}
+
+// ForgotPassword handles requesting a password reset token
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var req model.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	token, err := h.service.ForgotPassword(req)
+	if err != nil {
+		log.Printf("Error requesting password reset: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Password reset error"})
+		return
+	}
+
+	response := gin.H{"message": "If an account with this email exists, a password reset token has been sent"}
+	// Only set in development mode
+	if token != "" {
+		response["token"] = token
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ResetPassword handles setting a new password with a reset token
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var req model.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	err := h.service.ResetPassword(req)
+	if err != nil {
+		if err.Error() == "invalid or expired reset token" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+			return
+		}
+		log.Printf("Error resetting password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Password reset error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// withChallenge adds the challenge ID of an emailed code to a response body, and the
+// code itself in development mode
+func withChallenge(body gin.H, challenge *model.Challenge) gin.H {
+	body["challenge_id"] = challenge.ID
+	if challenge.Code != "" {
+		body["code"] = challenge.Code
+	}
+	return body
+}
+
+// deviceInfo describes the client of a request
+func deviceInfo(c *gin.Context) model.DeviceInfo {
+	return model.DeviceInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+}
@@ -12,15 +12,34 @@ type User struct {
 	City         string    `db:"city" json:"city"`
 	Avatar       string    `db:"avatar" json:"avatar"`
 	IsVerified   bool      `db:"is_verified" json:"is_verified"`
+	IsAdmin      bool      `db:"is_admin" json:"is_admin"`
+	Latitude     *float64  `db:"latitude" json:"latitude,omitempty"`
+	Longitude    *float64  `db:"longitude" json:"longitude,omitempty"`
 	CreatedAt    time.Time `db:"created_at" json:"created_at"`
 }
 
-// TwoFactorCode represents a 2FA verification code
+// One-time code purposes; a code only works for the purpose it was issued for
+const (
+	PurposeVerifyEmail = "verify_email"
+	PurposeLogin       = "login"
+)
+
+// TwoFactorCode represents a one-time email verification or 2FA code
 type TwoFactorCode struct {
-	ID        int       `db:"id"`
-	UserID    int       `db:"user_id"`
-	Code      string    `db:"code"`
-	ExpiresAt time.Time `db:"expires_at"`
+	ID          int       `db:"id"`
+	ChallengeID string    `db:"challenge_id"`
+	UserID      int       `db:"user_id"`
+	CodeHash    string    `db:"code_hash"`
+	Purpose     string    `db:"purpose"`
+	Attempts    int       `db:"attempts"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
+
+// Challenge identifies an emailed verification or 2FA code; the client sends its ID
+// back with the code. The code itself is only set in development mode.
+type Challenge struct {
+	ID   string `json:"challenge_id"`
+	Code string `json:"code,omitempty"`
 }
 
 // UserProfile represents a user profile for public access
@@ -39,7 +58,15 @@ type UserResponse struct {
 	Email    string `json:"email"`
 	Name     string `json:"name"`
 	LastName string `json:"last_name"`
-	Token    string `json:"token"`
+	TokenResponse
+}
+
+// TokenResponse holds the tokens of a session: a short-lived access token and the
+// refresh token used to get a new pair
+type TokenResponse struct {
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"` // Access token lifetime in seconds
 }
 
 // RegisterRequest represents the data needed for user registration
@@ -58,12 +85,34 @@ type LoginRequest struct {
 
 // VerifyRequest represents the data needed for verification code validation
 type VerifyRequest struct {
-	Email string `json:"email" binding:"required,email"`
-	Code  string `json:"code" binding:"required"`
+	ChallengeID string `json:"challenge_id" binding:"required,uuid"`
+	Code        string `json:"code" binding:"required"`
 }
 
 // Verify2FARequest represents the data needed for two-factor authentication
 type Verify2FARequest struct {
+	ChallengeID string `json:"challenge_id" binding:"required,uuid"`
+	Code        string `json:"code" binding:"required"`
+}
+
+// ForgotPasswordRequest represents the data needed to request a password reset token
+type ForgotPasswordRequest struct {
 	Email string `json:"email" binding:"required,email"`
-	Code  string `json:"code" binding:"required"`
+}
+
+// ResetPasswordRequest represents the data needed to set a new password with a reset token
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// DeviceInfo describes the client a session is used from
+type DeviceInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// RefreshRequest represents the data needed to refresh or end a session
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
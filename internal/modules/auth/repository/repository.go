@@ -68,31 +68,67 @@ func (r *Repository) UserExists(email string) (bool, error) {
 	return exists, nil
 }
 
-// SaveVerificationCode saves a verification code for a user
-func (r *Repository) SaveVerificationCode(userID int, code string, expiresAt time.Time) error {
-	_, err := r.db.Exec(`
-		INSERT INTO two_factor_codes (user_id, code, expires_at) VALUES ($1, $2, $3)
-	`, userID, code, expiresAt)
+// SaveVerificationCode saves a one-time code for a user, invalidating the user's
+// previous codes for the same purpose
+func (r *Repository) SaveVerificationCode(code model.TwoFactorCode) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return fmt.Errorf("error saving verification code: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM two_factor_codes WHERE user_id = $1 AND purpose = $2", code.UserID, code.Purpose)
+	if err != nil {
+		log.Printf("Error deleting previous codes: %v", err)
+		return fmt.Errorf("error saving verification code: %w", err)
+	}
+
+	_, err = tx.NamedExec(`
+		INSERT INTO two_factor_codes (challenge_id, user_id, code_hash, purpose, expires_at)
+		VALUES (:challenge_id, :user_id, :code_hash, :purpose, :expires_at)
+	`, code)
 	if err != nil {
 		log.Printf("Error saving verification code: %v", err)
 		return fmt.Errorf("error saving verification code: %w", err)
 	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return fmt.Errorf("error saving verification code: %w", err)
+	}
 	return nil
 }
 
-// VerifyCode checks if a verification code is valid
-func (r *Repository) VerifyCode(email, code string) (int, error) {
-	var userID int
-	err := r.db.Get(&userID, `
-		SELECT u.id FROM users u
-		JOIN two_factor_codes tfc ON u.id = tfc.user_id
-		WHERE u.email = $1 AND tfc.code = $2 AND tfc.expires_at > NOW()
-	`, email, code)
+// RecordCodeAttempt counts an attempt to use the unexpired code of a challenge and
+// returns the code with the updated count; sql.ErrNoRows means there is no such code
+func (r *Repository) RecordCodeAttempt(challengeID, purpose string) (*model.TwoFactorCode, error) {
+	var code model.TwoFactorCode
+	err := r.db.Get(&code, `
+		UPDATE two_factor_codes SET attempts = attempts + 1
+		WHERE challenge_id = $1 AND purpose = $2 AND expires_at > NOW()
+		RETURNING id, challenge_id, user_id, code_hash, purpose, attempts, expires_at
+	`, challengeID, purpose)
 	if err != nil {
-		log.Printf("Error verifying code: %v", err)
-		return 0, fmt.Errorf("error verifying code: %w", err)
+		log.Printf("Error recording code attempt: %v", err)
+		return nil, fmt.Errorf("error verifying code: %w", err)
 	}
-	return userID, nil
+	return &code, nil
+}
+
+// ConsumeCode deletes a used code, reporting whether it was still there so that a
+// code is only accepted once
+func (r *Repository) ConsumeCode(codeID int) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM two_factor_codes WHERE id = $1", codeID)
+	if err != nil {
+		log.Printf("Error consuming code: %v", err)
+		return false, fmt.Errorf("error consuming code: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error consuming code: %w", err)
+	}
+	return rows > 0, nil
 }
 
 // SetUserVerified sets a user as verified
@@ -105,12 +141,158 @@ func (r *Repository) SetUserVerified(userID int) error {
 	return nil
 }
 
-// DeleteVerificationCodes deletes all verification codes for a user
-func (r *Repository) DeleteVerificationCodes(userID int) error {
-	_, err := r.db.Exec("DELETE FROM two_factor_codes WHERE user_id = $1", userID)
+// SavePasswordResetToken saves a password reset token for a user, invalidating the
+// user's previous ones
+func (r *Repository) SavePasswordResetToken(userID int, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return fmt.Errorf("error saving password reset token: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM password_reset_tokens WHERE user_id = $1", userID)
+	if err != nil {
+		log.Printf("Error deleting previous password reset tokens: %v", err)
+		return fmt.Errorf("error saving password reset token: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`, userID, tokenHash, expiresAt)
+	if err != nil {
+		log.Printf("Error saving password reset token: %v", err)
+		return fmt.Errorf("error saving password reset token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return fmt.Errorf("error saving password reset token: %w", err)
+	}
+	return nil
+}
+
+// ResetPassword consumes an unexpired password reset token, sets a new password hash
+// for its user and revokes all of the user's sessions; sql.ErrNoRows means there is no
+// such token
+func (r *Repository) ResetPassword(tokenHash, passwordHash string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return fmt.Errorf("error resetting password: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Deleting the token makes sure it is only used once
+	var userID int
+	err = tx.Get(&userID, `
+		DELETE FROM password_reset_tokens
+		WHERE token_hash = $1 AND expires_at > NOW()
+		RETURNING user_id
+	`, tokenHash)
+	if err != nil {
+		log.Printf("Error consuming password reset token: %v", err)
+		return fmt.Errorf("error resetting password: %w", err)
+	}
+
+	_, err = tx.Exec("UPDATE users SET password_hash = $1 WHERE id = $2", passwordHash, userID)
+	if err != nil {
+		log.Printf("Error updating password: %v", err)
+		return fmt.Errorf("error resetting password: %w", err)
+	}
+
+	_, err = tx.Exec("UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+	if err != nil {
+		log.Printf("Error revoking user sessions: %v", err)
+		return fmt.Errorf("error resetting password: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return fmt.Errorf("error resetting password: %w", err)
+	}
+	return nil
+}
+
+// CreateSession creates a login session for a user and returns its ID
+func (r *Repository) CreateSession(userID int, refreshTokenHash string, expiresAt time.Time, device model.DeviceInfo) (int, error) {
+	var sessionID int
+	err := r.db.Get(&sessionID, `
+		INSERT INTO sessions (user_id, refresh_token_hash, expires_at, user_agent, ip_address)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, userID, refreshTokenHash, expiresAt, device.UserAgent, device.IPAddress)
+	if err != nil {
+		log.Printf("Error creating session: %v", err)
+		return 0, fmt.Errorf("error creating session: %w", err)
+	}
+	return sessionID, nil
+}
+
+// RotateSession replaces the refresh token of an active session, extends it and records
+// the client's address, returning the session and user IDs; sql.ErrNoRows means the
+// token is not current
+func (r *Repository) RotateSession(refreshTokenHash, newTokenHash string, expiresAt time.Time, ipAddress string) (int, int, error) {
+	var session struct {
+		ID     int `db:"id"`
+		UserID int `db:"user_id"`
+	}
+	err := r.db.Get(&session, `
+		UPDATE sessions
+		SET previous_token_hash = refresh_token_hash, refresh_token_hash = $2,
+			expires_at = $3, last_used_at = NOW(), ip_address = $4
+		WHERE refresh_token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+			AND user_id IN (SELECT id FROM users WHERE is_verified = true)
+		RETURNING id, user_id
+	`, refreshTokenHash, newTokenHash, expiresAt, ipAddress)
+	if err != nil {
+		log.Printf("Error rotating session: %v", err)
+		return 0, 0, fmt.Errorf("error rotating session: %w", err)
+	}
+	return session.ID, session.UserID, nil
+}
+
+// RevokeSessionByPreviousToken revokes the session a refresh token was rotated out of,
+// reporting whether there was one
+func (r *Repository) RevokeSessionByPreviousToken(refreshTokenHash string) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE previous_token_hash = $1 AND revoked_at IS NULL
+	`, refreshTokenHash)
+	if err != nil {
+		log.Printf("Error revoking session: %v", err)
+		return false, fmt.Errorf("error revoking session: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error revoking session: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// RevokeSessionByToken revokes the session of a refresh token
+func (r *Repository) RevokeSessionByToken(refreshTokenHash string) error {
+	_, err := r.db.Exec(`
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE refresh_token_hash = $1 AND revoked_at IS NULL
+	`, refreshTokenHash)
+	if err != nil {
+		log.Printf("Error revoking session: %v", err)
+		return fmt.Errorf("error revoking session: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes all active sessions of a user
+func (r *Repository) RevokeUserSessions(userID int) error {
+	_, err := r.db.Exec(`
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
 	if err != nil {
-		log.Printf("Error deleting verification codes: %v", err)
-		return fmt.Errorf("error deleting verification codes: %w", err)
+		log.Printf("Error revoking user sessions: %v", err)
+		return fmt.Errorf("error revoking user sessions: %w", err)
 	}
 	return nil
 }
@@ -3,16 +3,36 @@ package service
 import (
 	"FurniSwap/internal/modules/auth/model"
 	"FurniSwap/internal/modules/auth/repository"
+	"FurniSwap/pkg/config"
 	"FurniSwap/pkg/utils"
 	"database/sql"
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	// codeTTL is how long an emailed one-time code is valid
+	codeTTL = 10 * time.Minute
+
+	// maxCodeAttempts is the number of tries to enter a code before it is locked
+	maxCodeAttempts = 5
+
+	// resetTokenTTL is how long an emailed password reset token is valid
+	resetTokenTTL = 30 * time.Minute
+)
+
+// One-time code errors
+var (
+	errInvalidCode  = errors.New("invalid or expired code")
+	errCodeAttempts = errors.New("too many attempts; request a new code")
+)
+
 // Service provides authentication operations
 type Service struct {
 	repo *repository.Repository
@@ -25,60 +45,50 @@ func NewService(repo *repository.Repository) *Service {
 	}
 }
 
-// Register handles user registration
-func (s *Service) Register(req model.RegisterRequest) (string, error) {
+// Register handles user registration and sends an email verification code
+func (s *Service) Register(req model.RegisterRequest) (*model.Challenge, error) {
 	// Check if user already exists
 	exists, err := s.repo.UserExists(req.Email)
 	if err != nil {
-		return "", fmt.Errorf("error checking user existence: %w", err)
+		return nil, fmt.Errorf("error checking user existence: %w", err)
 	}
 	if exists {
-		return "", errors.New("user with this email already exists")
+		return nil, errors.New("user with this email already exists")
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return "", fmt.Errorf("error hashing password: %w", err)
+		return nil, fmt.Errorf("error hashing password: %w", err)
 	}
 
-	// Default values for city and avatar
+	// Default city; new users have no avatar until they upload one
 	defaultCity := "Москва"
-	defaultAvatar := "https://cs12.pikabu.ru/post_img/2021/05/08/12/1620504640126273687.jpg"
 
 	// Create user
-	userID, err := s.repo.CreateUser(req.Email, string(hashedPassword), req.Name, req.LastName, defaultCity, defaultAvatar)
-	if err != nil {
-		return "", fmt.Errorf("error creating user: %w", err)
-	}
-
-	// Generate verification code
-	code := utils.GenerateCode()
-
-	// Save code in database with 10-minute expiration
-	err = s.repo.SaveVerificationCode(userID, code, time.Now().Add(10*time.Minute))
+	userID, err := s.repo.CreateUser(req.Email, string(hashedPassword), req.Name, req.LastName, defaultCity, "")
 	if err != nil {
-		return "", fmt.Errorf("error saving verification code: %w", err)
+		return nil, fmt.Errorf("error creating user: %w", err)
 	}
 
-	// Send verification email
-	err = utils.SendEmail(req.Email, "Verification Code", "Your code: "+code)
+	// Send verification code
+	challenge, err := s.sendCode(userID, req.Email, model.PurposeVerifyEmail, "Verification Code", "Your code: ")
 	if err != nil {
-		log.Printf("Error sending verification email: %v", err)
+		return nil, fmt.Errorf("error saving verification code: %w", err)
 	}
 
-	return code, nil
+	return challenge, nil
 }
 
 // VerifyUser verifies a user's email
 func (s *Service) VerifyUser(req model.VerifyRequest) error {
 	// Verify the code
-	userID, err := s.repo.VerifyCode(req.Email, req.Code)
+	userID, err := s.verifyCode(req.ChallengeID, model.PurposeVerifyEmail, req.Code)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		if errors.Is(err, errInvalidCode) {
 			return errors.New("invalid or expired verification code")
 		}
-		return fmt.Errorf("error verifying code: %w", err)
+		return err
 	}
 
 	// Mark user as verified
@@ -87,65 +97,42 @@ func (s *Service) VerifyUser(req model.VerifyRequest) error {
 		return fmt.Errorf("error setting user as verified: %w", err)
 	}
 
-	// Delete used verification codes
-	err = s.repo.DeleteVerificationCodes(userID)
-	if err != nil {
-		log.Printf("Error deleting verification codes: %v", err)
-	}
-
 	return nil
 }
 
-// Login authenticates a user and returns user info with token
-func (s *Service) Login(req model.LoginRequest) (*model.UserResponse, string, error) {
+// Login checks the user's password and sends a 2FA code; the user gets a token after
+// confirming it. An unverified user is sent a new verification code instead.
+func (s *Service) Login(req model.LoginRequest) (*model.UserResponse, *model.Challenge, error) {
 	// Get user by email
 	user, err := s.repo.GetUserByEmail(req.Email)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, "", errors.New("invalid email or password")
+			return nil, nil, errors.New("invalid email or password")
 		}
-		return nil, "", fmt.Errorf("error getting user: %w", err)
+		return nil, nil, fmt.Errorf("error getting user: %w", err)
 	}
 
 	// Check password
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password))
 	if err != nil {
-		return nil, "", errors.New("invalid email or password")
+		return nil, nil, errors.New("invalid email or password")
 	}
 
 	// Check if user is verified
 	if !user.IsVerified {
-		// Generate new verification code
-		code := utils.GenerateCode()
-
-		// Save code in database with 10-minute expiration
-		err = s.repo.SaveVerificationCode(user.ID, code, time.Now().Add(10*time.Minute))
+		// Send new verification code
+		challenge, err := s.sendCode(user.ID, user.Email, model.PurposeVerifyEmail, "Verification Code", "Your code: ")
 		if err != nil {
-			return nil, "", fmt.Errorf("error saving verification code: %w", err)
+			return nil, nil, fmt.Errorf("error saving verification code: %w", err)
 		}
 
-		// Send verification email
-		err = utils.SendEmail(user.Email, "Verification Code", "Your code: "+code)
-		if err != nil {
-			log.Printf("Error sending verification email: %v", err)
-		}
-
-		return nil, "", errors.New("account not verified; new verification code sent")
-	}
-
-	// Generate 2FA code for login
-	code := utils.GenerateCode()
-
-	// Save code in database with 10-minute expiration
-	err = s.repo.SaveVerificationCode(user.ID, code, time.Now().Add(10*time.Minute))
-	if err != nil {
-		return nil, "", fmt.Errorf("error saving 2FA code: %w", err)
+		return nil, challenge, errors.New("account not verified; new verification code sent")
 	}
 
-	// Send 2FA code via email
-	err = utils.SendEmail(user.Email, "Two-Factor Authentication Code", "Your 2FA code: "+code)
+	// Send 2FA code for login
+	challenge, err := s.sendCode(user.ID, user.Email, model.PurposeLogin, "Two-Factor Authentication Code", "Your 2FA code: ")
 	if err != nil {
-		log.Printf("Error sending 2FA email: %v", err)
+		return nil, nil, fmt.Errorf("error saving 2FA code: %w", err)
 	}
 
 	// Return partial user info without token (will be completed after 2FA)
@@ -154,18 +141,156 @@ func (s *Service) Login(req model.LoginRequest) (*model.UserResponse, string, er
 		Email:    user.Email,
 		Name:     user.Name,
 		LastName: user.LastName,
-	}, code, nil
+	}, challenge, nil
 }
 
-// Verify2FA verifies the two-factor authentication code
-func (s *Service) Verify2FA(req model.Verify2FARequest) (*model.UserResponse, error) {
-	// Verify the code
-	userID, err := s.repo.VerifyCode(req.Email, req.Code)
+// sendCode generates a one-time code for a purpose and emails it to the user, replacing
+// the user's previous codes for it; the returned challenge only includes the code in
+// development mode
+func (s *Service) sendCode(userID int, email, purpose, subject, text string) (*model.Challenge, error) {
+	challenge, code, err := s.saveCode(userID, purpose)
+	if err != nil {
+		return nil, err
+	}
+
+	s.emailCode(email, subject, text, code)
+	return challenge, nil
+}
+
+// saveCode generates and saves a one-time code for a purpose, replacing the user's
+// previous codes for it, and returns its challenge and the code
+func (s *Service) saveCode(userID int, purpose string) (*model.Challenge, string, error) {
+	code, err := utils.GenerateCode()
+	if err != nil {
+		return nil, "", err
+	}
+
+	challengeID := uuid.New().String()
+	err = s.repo.SaveVerificationCode(model.TwoFactorCode{
+		ChallengeID: challengeID,
+		UserID:      userID,
+		CodeHash:    utils.HashCode(challengeID, code),
+		Purpose:     purpose,
+		ExpiresAt:   time.Now().Add(codeTTL),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	challenge := &model.Challenge{ID: challengeID}
+	if config.Config.AuthDevMode {
+		challenge.Code = code
+	}
+	return challenge, code, nil
+}
+
+// emailCode sends a one-time code to the user, logging errors
+func (s *Service) emailCode(email, subject, text, code string) {
+	err := utils.SendEmail(email, subject, text+code)
+	if err != nil {
+		log.Printf("Error sending verification email: %v", err)
+	}
+}
+
+// ForgotPassword emails a password reset token if an account with the email exists.
+// The lookup and the email happen in the background, so neither the response nor its
+// timing shows whether the email is registered. In development mode they happen inline
+// and the token is returned.
+func (s *Service) ForgotPassword(req model.ForgotPasswordRequest) (string, error) {
+	if config.Config.AuthDevMode {
+		return s.sendResetToken(req.Email)
+	}
+
+	go func() {
+		if _, err := s.sendResetToken(req.Email); err != nil {
+			log.Printf("Error requesting password reset: %v", err)
+		}
+	}()
+	return "", nil
+}
+
+// sendResetToken generates a password reset token for the user with the email and
+// emails it, replacing the user's previous tokens. Unknown emails are ignored.
+func (s *Service) sendResetToken(email string) (string, error) {
+	user, err := s.repo.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("error getting user: %w", err)
+	}
+
+	token, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.repo.SavePasswordResetToken(user.ID, utils.HashToken(token), time.Now().Add(resetTokenTTL))
+	if err != nil {
+		return "", err
+	}
+
+	s.emailCode(user.Email, "Password Reset", "Your password reset token: ", token)
+	return token, nil
+}
+
+// ResetPassword sets a new password using an emailed reset token and ends all sessions
+func (s *Service) ResetPassword(req model.ResetPasswordRequest) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("error hashing password: %w", err)
+	}
+
+	err = s.repo.ResetPassword(utils.HashToken(req.Token), string(hashedPassword))
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("invalid or expired reset token")
+	}
+	return err
+}
+
+// verifyCode checks a code entered for a challenge and consumes it, returning the user
+// it was issued to. Every attempt is counted, and the code stops working after
+// maxCodeAttempts wrong ones.
+func (s *Service) verifyCode(challengeID, purpose, code string) (int, error) {
+	stored, err := s.repo.RecordCodeAttempt(challengeID, purpose)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			return 0, errInvalidCode
+		}
+		return 0, fmt.Errorf("error verifying code: %w", err)
+	}
+
+	if stored.Attempts > maxCodeAttempts {
+		return 0, errCodeAttempts
+	}
+	if !utils.CodeHashesEqual(stored.CodeHash, utils.HashCode(challengeID, code)) {
+		return 0, errInvalidCode
+	}
+
+	// Only one of concurrent requests with the right code gets to use it
+	consumed, err := s.repo.ConsumeCode(stored.ID)
+	if err != nil {
+		return 0, fmt.Errorf("error verifying code: %w", err)
+	}
+	if !consumed {
+		return 0, errInvalidCode
+	}
+
+	return stored.UserID, nil
+}
+
+// maxUserAgentLength limits the user agent stored for a session
+const maxUserAgentLength = 512
+
+// Verify2FA verifies the two-factor authentication code and starts a session on the device
+func (s *Service) Verify2FA(req model.Verify2FARequest, device model.DeviceInfo) (*model.UserResponse, error) {
+	// Verify the code
+	userID, err := s.verifyCode(req.ChallengeID, model.PurposeLogin, req.Code)
+	if err != nil {
+		if errors.Is(err, errInvalidCode) {
 			return nil, errors.New("invalid or expired 2FA code")
 		}
-		return nil, fmt.Errorf("error verifying 2FA code: %w", err)
+		return nil, err
 	}
 
 	// Get user by ID
@@ -174,24 +299,95 @@ func (s *Service) Verify2FA(req model.Verify2FARequest) (*model.UserResponse, er
 		return nil, fmt.Errorf("error getting user: %w", err)
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user.ID)
+	// Start a session with an access and a refresh token
+	tokens, err := s.createSession(user.ID, device)
 	if err != nil {
-		return nil, fmt.Errorf("error generating token: %w", err)
+		return nil, err
 	}
 
-	// Delete used verification codes
-	err = s.repo.DeleteVerificationCodes(user.ID)
+	// Return user info with tokens
+	return &model.UserResponse{
+		ID:            user.ID,
+		Email:         user.Email,
+		Name:          user.Name,
+		LastName:      user.LastName,
+		TokenResponse: *tokens,
+	}, nil
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token pair. Each
+// refresh token works once; reusing a rotated one revokes its session, since that
+// means the token was copied.
+func (s *Service) Refresh(refreshToken string, device model.DeviceInfo) (*model.TokenResponse, error) {
+	tokenHash := utils.HashToken(refreshToken)
+
+	newRefreshToken, err := utils.GenerateRefreshToken()
 	if err != nil {
-		log.Printf("Error deleting verification codes: %v", err)
+		return nil, err
 	}
 
-	// Return user info with token
-	return &model.UserResponse{
-		ID:       user.ID,
-		Email:    user.Email,
-		Name:     user.Name,
-		LastName: user.LastName,
-		Token:    token,
+	sessionID, userID, err := s.repo.RotateSession(tokenHash, utils.HashToken(newRefreshToken), refreshTokenExpiry(), device.IPAddress)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("error refreshing session: %w", err)
+		}
+
+		revoked, err := s.repo.RevokeSessionByPreviousToken(tokenHash)
+		if err != nil {
+			log.Printf("Error revoking session after refresh token reuse: %v", err)
+		} else if revoked {
+			log.Printf("Refresh token reused, session revoked")
+		}
+		return nil, errors.New("invalid refresh token")
+	}
+
+	return s.issueTokens(userID, sessionID, newRefreshToken)
+}
+
+// Logout ends the session of a refresh token
+func (s *Service) Logout(refreshToken string) error {
+	return s.repo.RevokeSessionByToken(utils.HashToken(refreshToken))
+}
+
+// LogoutAll ends all sessions of a user
+func (s *Service) LogoutAll(userID int) error {
+	return s.repo.RevokeUserSessions(userID)
+}
+
+// createSession starts a new session for a user on a device and returns its tokens
+func (s *Service) createSession(userID int, device model.DeviceInfo) (*model.TokenResponse, error) {
+	refreshToken, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(device.UserAgent) > maxUserAgentLength {
+		device.UserAgent = strings.ToValidUTF8(device.UserAgent[:maxUserAgentLength], "")
+	}
+
+	sessionID, err := s.repo.CreateSession(userID, utils.HashToken(refreshToken), refreshTokenExpiry(), device)
+	if err != nil {
+		return nil, fmt.Errorf("error creating session: %w", err)
+	}
+
+	return s.issueTokens(userID, sessionID, refreshToken)
+}
+
+// issueTokens generates an access token for a session and pairs it with the refresh token
+func (s *Service) issueTokens(userID, sessionID int, refreshToken string) (*model.TokenResponse, error) {
+	token, expiresAt, err := utils.GenerateToken(userID, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("error generating token: %w", err)
+	}
+
+	return &model.TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(time.Until(expiresAt).Seconds()),
 	}, nil
 }
+
+// refreshTokenExpiry returns the expiration time of a session refreshed now
+func refreshTokenExpiry() time.Time {
+	return time.Now().AddDate(0, 0, config.Config.RefreshTokenDays)
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"FurniSwap/internal/modules/category/model"
+	"FurniSwap/internal/modules/category/repository"
+	"FurniSwap/internal/modules/category/service"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler provides category handlers
+type Handler struct {
+	service *service.Service
+}
+
+// NewHandler creates a new category handler
+func NewHandler(service *service.Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// RegisterPublicRoutes registers public category routes (no auth required)
+func (h *Handler) RegisterPublicRoutes(router *gin.RouterGroup) {
+	router.GET("/categories", h.GetCategories)
+	router.GET("/categories/:id", h.GetCategory)
+}
+
+// RegisterAdminRoutes registers category management routes (admin required)
+func (h *Handler) RegisterAdminRoutes(router *gin.RouterGroup) {
+	router.POST("/categories", h.CreateCategory)
+	router.PUT("/categories/:id", h.UpdateCategory)
+	router.DELETE("/categories/:id", h.DeleteCategory)
+}
+
+// GetCategories handles getting the category tree
+func (h *Handler) GetCategories(c *gin.Context) {
+	categories, err := h.service.GetCategoryTree()
+	if err != nil {
+		log.Printf("Error getting categories: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "error getting categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+// GetCategory handles getting a category with its subcategories
+func (h *Handler) GetCategory(c *gin.Context) {
+	// Parse category ID
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	category, err := h.service.GetCategory(categoryID)
+	if err != nil {
+		h.handleCategoryError(c, err, "Error getting category")
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// CreateCategory handles creating a category
+func (h *Handler) CreateCategory(c *gin.Context) {
+	// Parse request body
+	var req model.CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	categoryID, err := h.service.CreateCategory(req)
+	if err != nil {
+		h.handleCategoryError(c, err, "Error creating category")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": categoryID, "message": "Category created"})
+}
+
+// UpdateCategory handles updating a category
+func (h *Handler) UpdateCategory(c *gin.Context) {
+	// Parse category ID
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	// Parse request body
+	var req model.CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	if err = h.service.UpdateCategory(categoryID, req); err != nil {
+		h.handleCategoryError(c, err, "Error updating category")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category updated"})
+}
+
+// DeleteCategory handles deleting a category
+func (h *Handler) DeleteCategory(c *gin.Context) {
+	// Parse category ID
+	categoryID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	if err = h.service.DeleteCategory(categoryID); err != nil {
+		h.handleCategoryError(c, err, "Error deleting category")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted"})
+}
+
+// handleCategoryError maps category service errors to HTTP responses
+func (h *Handler) handleCategoryError(c *gin.Context, err error, fallback string) {
+	if errors.Is(err, repository.ErrCategoryExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Category with this slug or name already exists"})
+		return
+	}
+
+	switch err.Error() {
+	case "category not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+	case "parent category not found":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parent category not found"})
+	case "category name is required":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category name is required"})
+	case "invalid slug":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Slug must contain only lowercase latin letters, digits and dashes"})
+	case "category cannot be nested under itself":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Category cannot be nested under itself or its subcategory"})
+	case "category has subcategories":
+		c.JSON(http.StatusConflict, gin.H{"error": "Category has subcategories"})
+	case "category has listings":
+		c.JSON(http.StatusConflict, gin.H{"error": "Category has listings"})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
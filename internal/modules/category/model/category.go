@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// Category represents a listing category, possibly nested under a parent
+type Category struct {
+	ID           int        `db:"id" json:"id"`
+	ParentID     *int       `db:"parent_id" json:"parent_id"`
+	Name         string     `db:"name" json:"name"`
+	Slug         string     `db:"slug" json:"slug"`
+	Icon         string     `db:"icon" json:"icon"`
+	SortOrder    int        `db:"sort_order" json:"sort_order"`
+	ListingCount int        `db:"listing_count" json:"listing_count"` // Active listings in the category and its descendants
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time  `db:"updated_at" json:"updated_at"`
+	Children     []Category `db:"-" json:"children"`
+}
+
+// CategoryRequest represents the data needed to create or update a category
+type CategoryRequest struct {
+	ParentID  *int   `json:"parent_id" binding:"omitempty,min=1"`
+	Name      string `json:"name" binding:"required,max=100"`
+	Slug      string `json:"slug" binding:"required,max=100"`
+	Icon      string `json:"icon" binding:"max=500"`
+	SortOrder int    `json:"sort_order"`
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"FurniSwap/internal/modules/category/model"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrCategoryExists is returned when a category with the same slug, or the same
+// name under the same parent, already exists
+var ErrCategoryExists = errors.New("category already exists")
+
+// Repository handles database operations for the category module
+type Repository struct {
+	db *sqlx.DB
+}
+
+// NewRepository creates a new category repository
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// DescendantsQuery returns a subquery selecting the ID of a category and of all its
+// descendants; categoryID is a SQL expression such as a parameter or a column
+func DescendantsQuery(categoryID string) string {
+	return "(WITH RECURSIVE category_tree AS (" +
+		"SELECT id FROM categories WHERE id = " + categoryID + " " +
+		"UNION ALL SELECT c.id FROM categories c JOIN category_tree t ON c.parent_id = t.id" +
+		") SELECT id FROM category_tree)"
+}
+
+// categorySelect selects categories with the number of active listings in them and their descendants
+const categorySelect = `
+	WITH RECURSIVE category_tree AS (
+		SELECT id, id AS root_id FROM categories
+		UNION ALL
+		SELECT c.id, t.root_id FROM categories c JOIN category_tree t ON c.parent_id = t.id
+	), counts AS (
+		SELECT t.root_id, COUNT(l.id) AS listing_count
+		FROM category_tree t
+		LEFT JOIN listings l ON l.category_id = t.id AND l.status = 'active'
+		GROUP BY t.root_id
+	)
+	SELECT c.id, c.parent_id, c.name, c.slug, c.icon, c.sort_order, c.created_at, c.updated_at,
+		   COALESCE(counts.listing_count, 0) AS listing_count
+	FROM categories c
+	LEFT JOIN counts ON counts.root_id = c.id
+`
+
+// GetCategories gets all categories ordered by sort order and name
+func (r *Repository) GetCategories() ([]model.Category, error) {
+	categories := []model.Category{}
+	err := r.db.Select(&categories, categorySelect+" ORDER BY c.sort_order, c.name")
+	if err != nil {
+		log.Printf("Error getting categories: %v", err)
+		return nil, fmt.Errorf("error getting categories: %w", err)
+	}
+
+	return categories, nil
+}
+
+// GetCategory gets a category by ID, or nil if it doesn't exist
+func (r *Repository) GetCategory(categoryID int) (*model.Category, error) {
+	var category model.Category
+	err := r.db.Get(&category, categorySelect+" WHERE c.id = $1", categoryID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error getting category: %v", err)
+		return nil, fmt.Errorf("error getting category: %w", err)
+	}
+
+	return &category, nil
+}
+
+// IsDescendant checks if a category is the same as or nested under the ancestor
+func (r *Repository) IsDescendant(categoryID, ancestorID int) (bool, error) {
+	var isDescendant bool
+	err := r.db.Get(&isDescendant, "SELECT $1 IN "+DescendantsQuery("$2"), categoryID, ancestorID)
+	if err != nil {
+		log.Printf("Error checking category ancestry: %v", err)
+		return false, fmt.Errorf("error checking category ancestry: %w", err)
+	}
+
+	return isDescendant, nil
+}
+
+// CreateCategory creates a new category
+func (r *Repository) CreateCategory(req model.CategoryRequest) (int, error) {
+	var categoryID int
+	err := r.db.QueryRow(`
+		INSERT INTO categories (parent_id, name, slug, icon, sort_order, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		RETURNING id
+	`, req.ParentID, req.Name, req.Slug, req.Icon, req.SortOrder, time.Now()).Scan(&categoryID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, ErrCategoryExists
+		}
+		log.Printf("Error creating category: %v", err)
+		return 0, fmt.Errorf("error creating category: %w", err)
+	}
+
+	return categoryID, nil
+}
+
+// UpdateCategory updates a category, returning false if it doesn't exist
+func (r *Repository) UpdateCategory(categoryID int, req model.CategoryRequest) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE categories
+		SET parent_id = $1, name = $2, slug = $3, icon = $4, sort_order = $5, updated_at = $6
+		WHERE id = $7
+	`, req.ParentID, req.Name, req.Slug, req.Icon, req.SortOrder, time.Now(), categoryID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return false, ErrCategoryExists
+		}
+		log.Printf("Error updating category: %v", err)
+		return false, fmt.Errorf("error updating category: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// HasChildren checks if a category has subcategories
+func (r *Repository) HasChildren(categoryID int) (bool, error) {
+	var exists bool
+	err := r.db.Get(&exists, "SELECT EXISTS(SELECT 1 FROM categories WHERE parent_id = $1)", categoryID)
+	if err != nil {
+		log.Printf("Error checking subcategories: %v", err)
+		return false, fmt.Errorf("error checking subcategories: %w", err)
+	}
+
+	return exists, nil
+}
+
+// HasListings checks if any listing, active or not, is in the category
+func (r *Repository) HasListings(categoryID int) (bool, error) {
+	var exists bool
+	err := r.db.Get(&exists, "SELECT EXISTS(SELECT 1 FROM listings WHERE category_id = $1)", categoryID)
+	if err != nil {
+		log.Printf("Error checking category listings: %v", err)
+		return false, fmt.Errorf("error checking category listings: %w", err)
+	}
+
+	return exists, nil
+}
+
+// DeleteCategory deletes a category, returning false if it doesn't exist
+func (r *Repository) DeleteCategory(categoryID int) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM categories WHERE id = $1", categoryID)
+	if err != nil {
+		log.Printf("Error deleting category: %v", err)
+		return false, fmt.Errorf("error deleting category: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// isUniqueViolation checks if the error is a unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
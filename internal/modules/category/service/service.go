@@ -0,0 +1,164 @@
+package service
+
+import (
+	"FurniSwap/internal/modules/category/model"
+	"FurniSwap/internal/modules/category/repository"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// slugPattern matches lowercase latin slugs with single dashes, e.g. "office-chairs"
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Service provides category operations
+type Service struct {
+	repo *repository.Repository
+}
+
+// NewService creates a new category service
+func NewService(repo *repository.Repository) *Service {
+	return &Service{
+		repo: repo,
+	}
+}
+
+// GetCategoryTree gets all categories nested under their parents
+func (s *Service) GetCategoryTree() ([]model.Category, error) {
+	categories, err := s.repo.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	return buildTree(categories, nil), nil
+}
+
+// GetCategory gets a category with its subcategories
+func (s *Service) GetCategory(categoryID int) (*model.Category, error) {
+	categories, err := s.repo.GetCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, category := range categories {
+		if category.ID == categoryID {
+			category.Children = buildTree(categories, &category.ID)
+			return &category, nil
+		}
+	}
+
+	return nil, errors.New("category not found")
+}
+
+// CreateCategory creates a new category
+func (s *Service) CreateCategory(req model.CategoryRequest) (int, error) {
+	if err := s.validateRequest(0, &req); err != nil {
+		return 0, err
+	}
+
+	return s.repo.CreateCategory(req)
+}
+
+// UpdateCategory updates a category, including moving it under another parent
+func (s *Service) UpdateCategory(categoryID int, req model.CategoryRequest) error {
+	if err := s.validateRequest(categoryID, &req); err != nil {
+		return err
+	}
+
+	updated, err := s.repo.UpdateCategory(categoryID, req)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return errors.New("category not found")
+	}
+
+	return nil
+}
+
+// DeleteCategory deletes a category that has no subcategories and no listings
+func (s *Service) DeleteCategory(categoryID int) error {
+	hasChildren, err := s.repo.HasChildren(categoryID)
+	if err != nil {
+		return err
+	}
+	if hasChildren {
+		return errors.New("category has subcategories")
+	}
+
+	hasListings, err := s.repo.HasListings(categoryID)
+	if err != nil {
+		return err
+	}
+	if hasListings {
+		return errors.New("category has listings")
+	}
+
+	deleted, err := s.repo.DeleteCategory(categoryID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.New("category not found")
+	}
+
+	return nil
+}
+
+// validateRequest normalizes and checks a category request; categoryID is 0 for new categories
+func (s *Service) validateRequest(categoryID int, req *model.CategoryRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Slug = strings.TrimSpace(req.Slug)
+	if req.Name == "" {
+		return errors.New("category name is required")
+	}
+	if !slugPattern.MatchString(req.Slug) {
+		return errors.New("invalid slug")
+	}
+
+	if req.ParentID == nil {
+		return nil
+	}
+
+	parent, err := s.repo.GetCategory(*req.ParentID)
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		return errors.New("parent category not found")
+	}
+
+	// A category can't be moved under itself or one of its descendants
+	if categoryID > 0 {
+		isDescendant, err := s.repo.IsDescendant(*req.ParentID, categoryID)
+		if err != nil {
+			return err
+		}
+		if isDescendant {
+			return errors.New("category cannot be nested under itself")
+		}
+	}
+
+	return nil
+}
+
+// buildTree returns the categories under the parent with their subcategories nested
+func buildTree(categories []model.Category, parentID *int) []model.Category {
+	children := []model.Category{}
+	for _, category := range categories {
+		if !sameParent(category.ParentID, parentID) {
+			continue
+		}
+		category.Children = buildTree(categories, &category.ID)
+		children = append(children, category)
+	}
+	return children
+}
+
+// sameParent compares optional parent IDs
+func sameParent(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
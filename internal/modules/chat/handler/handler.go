@@ -3,6 +3,8 @@ package handler
 import (
 	"FurniSwap/internal/modules/chat/model"
 	"FurniSwap/internal/modules/chat/service"
+	"FurniSwap/pkg/storage"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -10,6 +12,17 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// Maximum number of images in a single message
+const maxMessageImages = 5
+
+// attachmentTypes are the content types attachments are served with; attachments stored
+// with any other type are served as downloads
+var attachmentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
 // Handler provides chat handlers
 type Handler struct {
 	service *service.Service
@@ -26,8 +39,13 @@ func NewHandler(service *service.Service) *Handler {
 func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
 	router.POST("/chats", h.InitiateChat)
 	router.GET("/chats", h.GetChats)
+	router.GET("/chats/ws", h.Connect)
+	router.GET("/chats/unread", h.GetUnreadCount)
 	router.GET("/chats/:id", h.GetChatMessages)
 	router.POST("/chats/:id/messages", h.SendMessage)
+	router.POST("/chats/:id/images", h.SendImageMessage)
+	router.GET("/chats/:id/attachments/:attachmentId", h.GetAttachment)
+	router.POST("/chats/:id/read", h.MarkChatRead)
 }
 
 // InitiateChat handles starting a new chat
@@ -58,6 +76,10 @@ func (h *Handler) InitiateChat(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot initiate chat with yourself"})
 			return
 		}
+		if err.Error() == "you cannot message this user" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot message this user"})
+			return
+		}
 		log.Printf("Error initiating chat: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error initiating chat"})
 		return
@@ -76,19 +98,18 @@ func (h *Handler) GetChats(c *gin.Context) {
 	}
 
 	// Parse pagination parameters
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	if page < 1 {
-		page = 1
-	}
-
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 	if limit < 1 || limit > 50 {
 		limit = 10
 	}
 
-	// Get chats
-	chats, err := h.service.GetUserChats(userID.(int), page, limit)
+	// Get chats after the cursor from the previous page
+	chats, err := h.service.GetUserChats(userID.(int), c.Query("cursor"), limit)
 	if err != nil {
+		if err.Error() == "invalid cursor" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
 		log.Printf("Error getting chats: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting chats"})
 		return
@@ -192,6 +213,10 @@ func (h *Handler) SendMessage(c *gin.Context) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this chat"})
 			return
 		}
+		if err.Error() == "you cannot message this user" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot message this user"})
+			return
+		}
 		log.Printf("Error sending message: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error sending message"})
 		return
@@ -199,3 +224,170 @@ func (h *Handler) SendMessage(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"id": messageID, "message": "Message sent successfully"})
 }
+
+// MarkChatRead handles marking a chat as read up to a message
+func (h *Handler) MarkChatRead(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse chat ID
+	chatID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	// Parse request body
+	var req model.MarkReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	marked, err := h.service.MarkChatRead(chatID, userID.(int), req.MessageID)
+	if err != nil {
+		if err.Error() == "you don't have access to this chat" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this chat"})
+			return
+		}
+		log.Printf("Error marking chat as read: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error marking chat as read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"marked_count": marked, "message": "Chat marked as read"})
+}
+
+// GetUnreadCount handles getting the total number of unread messages
+func (h *Handler) GetUnreadCount(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	count, err := h.service.GetUnreadCount(userID.(int))
+	if err != nil {
+		log.Printf("Error getting unread count: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting unread count"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread_count": count})
+}
+
+// SendImageMessage handles sending a message with image attachments in a chat
+func (h *Handler) SendImageMessage(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse chat ID
+	chatID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	// Get files from form
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["images"]) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No images provided"})
+		return
+	}
+
+	files := form.File["images"]
+	if len(files) > maxMessageImages {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many images in one message"})
+		return
+	}
+
+	// Send message; image types are verified by content, not by the client Content-Type
+	messageID, err := h.service.SendImageMessage(chatID, userID.(int), c.PostForm("content"), files)
+	if err != nil {
+		switch err.Error() {
+		case "you don't have access to this chat":
+			c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this chat"})
+		case "you cannot message this user":
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot message this user"})
+		case "invalid image file":
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File must be an image (JPEG, PNG, GIF or WebP)"})
+		case "image is too large":
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is too large"})
+		default:
+			log.Printf("Error sending image message: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error sending message"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": messageID, "message": "Message sent successfully"})
+}
+
+// GetAttachment handles downloading a message attachment for chat participants
+func (h *Handler) GetAttachment(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse chat ID and attachment ID
+	chatID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat ID"})
+		return
+	}
+
+	attachmentID, err := strconv.Atoi(c.Param("attachmentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID"})
+		return
+	}
+
+	attachment, err := h.service.GetAttachment(chatID, attachmentID, userID.(int))
+	if err != nil {
+		switch err.Error() {
+		case "you don't have access to this chat":
+			c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this chat"})
+		case "attachment not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+		default:
+			log.Printf("Error getting attachment: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting attachment"})
+		}
+		return
+	}
+
+	file, err := storage.Default.Open(attachment.FilePath)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment file not found"})
+			return
+		}
+		log.Printf("Error opening attachment: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting attachment"})
+		return
+	}
+	defer file.Close()
+
+	// Older attachments kept the type sent by the client, so only known image types are served inline
+	contentType := attachment.ContentType
+	if !attachmentTypes[contentType] {
+		contentType = "application/octet-stream"
+	}
+
+	// Attachments are private, so they must not be stored by shared caches
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, file.Size, contentType, file, nil)
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"FurniSwap/internal/modules/chat/hub"
+	"FurniSwap/internal/modules/chat/model"
+	"FurniSwap/pkg/config"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Time allowed to write an event to the client
+	writeWait = 10 * time.Second
+
+	// Time allowed to read the next pong from the client
+	pongWait = 60 * time.Second
+
+	// Send pings with this period, must be less than pongWait. The session is
+	// re-checked at the same period, so revoked sessions are disconnected.
+	pingPeriod = (pongWait * 9) / 10
+
+	// Maximum size of an event sent by the client
+	maxEventSize = 4096
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     checkOrigin,
+}
+
+// clientEvent represents an event sent by the client over the WebSocket
+type clientEvent struct {
+	Type      string `json:"type"`
+	ChatID    int    `json:"chat_id"`
+	MessageID int    `json:"message_id"`
+}
+
+// checkOrigin allows WebSocket handshakes only from the configured CORS origins
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	for _, allowed := range config.Config.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+
+	log.Printf("WebSocket connection from origin %s rejected", origin)
+	return false
+}
+
+// Connect handles upgrading to a WebSocket that streams chat events. Messages
+// newer than the last_message_id query parameter are replayed first.
+func (h *Handler) Connect(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	lastMessageID, err := strconv.Atoi(c.DefaultQuery("last_message_id", "0"))
+	if err != nil || lastMessageID < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid last message ID"})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader has already written an error response
+		log.Printf("Error upgrading chat connection: %v", err)
+		return
+	}
+
+	// Subscribe before loading missed messages so nothing written in between is lost
+	client := h.service.Subscribe(userID.(int))
+
+	missed := []model.Message{}
+	if lastMessageID > 0 {
+		missed, err = h.service.GetMessagesSince(userID.(int), lastMessageID)
+		if err != nil {
+			log.Printf("Error getting missed messages: %v", err)
+			h.service.Unsubscribe(client)
+			conn.Close()
+			return
+		}
+	}
+
+	go h.writeEvents(conn, client, c.GetInt("sessionID"), missed)
+	h.readEvents(conn, client)
+}
+
+// writeEvents replays missed messages and then streams hub events to the connection
+// until it closes or the session it was opened with ends
+func (h *Handler) writeEvents(conn *websocket.Conn, client *hub.Client, sessionID int, missed []model.Message) {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		conn.Close()
+	}()
+
+	replayed := make(map[int]struct{}, len(missed))
+	for i := range missed {
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		err := conn.WriteJSON(hub.Event{
+			Type:      hub.EventMessage,
+			ChatID:    missed[i].ChatID,
+			UserID:    missed[i].SenderID,
+			MessageID: missed[i].ID,
+			Message:   &missed[i],
+		})
+		if err != nil {
+			return
+		}
+		replayed[missed[i].ID] = struct{}{}
+	}
+
+	for {
+		select {
+		case event, ok := <-client.Send:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				// The hub closed the channel
+				conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+
+			// Skip messages that were already replayed
+			if _, ok := replayed[event.MessageID]; ok && event.Type == hub.EventMessage {
+				continue
+			}
+
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			active, err := h.service.SessionActive(client.UserID, sessionID)
+			if err == nil && !active {
+				conn.SetWriteDeadline(time.Now().Add(writeWait))
+				conn.WriteMessage(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session expired or revoked"))
+				return
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readEvents handles typing and read events from the client until the connection closes
+func (h *Handler) readEvents(conn *websocket.Conn, client *hub.Client) {
+	defer func() {
+		h.service.Unsubscribe(client)
+		conn.Close()
+	}()
+
+	conn.SetReadLimit(maxEventSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("Chat connection of user %d closed: %v", client.UserID, err)
+			}
+			return
+		}
+
+		var event clientEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			log.Printf("Invalid chat event from user %d: %v", client.UserID, err)
+			continue
+		}
+
+		switch event.Type {
+		case hub.EventTyping:
+			err = h.service.SendTyping(event.ChatID, client.UserID)
+		case hub.EventRead:
+			_, err = h.service.MarkChatRead(event.ChatID, client.UserID, event.MessageID)
+		default:
+			log.Printf("Unknown chat event type %q from user %d", event.Type, client.UserID)
+			continue
+		}
+
+		if err != nil {
+			log.Printf("Error handling %s event from user %d: %v", event.Type, client.UserID, err)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package hub
+
+import (
+	"FurniSwap/internal/modules/chat/model"
+	"log"
+	"sync"
+)
+
+// Event types pushed to connected clients
+const (
+	EventMessage = "message"
+	EventTyping  = "typing"
+	EventRead    = "read"
+)
+
+// sendBufferSize is the number of events buffered per client before it is
+// considered too slow and disconnected
+const sendBufferSize = 256
+
+// Event represents a real-time chat event
+type Event struct {
+	Type      string         `json:"type"`
+	ChatID    int            `json:"chat_id"`
+	UserID    int            `json:"user_id,omitempty"`
+	MessageID int            `json:"message_id,omitempty"`
+	Message   *model.Message `json:"message,omitempty"`
+}
+
+// Client is a single connection of a user subscribed to chat events
+type Client struct {
+	UserID int
+	Send   chan Event
+}
+
+// Hub fans out chat events to all connections of the involved users
+type Hub struct {
+	mu      sync.Mutex
+	clients map[int]map[*Client]struct{}
+}
+
+// NewHub creates a new in-process chat hub
+func NewHub() *Hub {
+	return &Hub{
+		clients: make(map[int]map[*Client]struct{}),
+	}
+}
+
+// Register subscribes a new connection of the user to events
+func (h *Hub) Register(userID int) *Client {
+	client := &Client{
+		UserID: userID,
+		Send:   make(chan Event, sendBufferSize),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.clients[userID] == nil {
+		h.clients[userID] = make(map[*Client]struct{})
+	}
+	h.clients[userID][client] = struct{}{}
+
+	return client
+}
+
+// Unregister removes a connection and closes its send channel
+func (h *Hub) Unregister(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(client)
+}
+
+// Publish sends an event to every connection of the given users. Clients that
+// can't keep up are disconnected; they resume from their last seen message on reconnect.
+func (h *Hub) Publish(userIDs []int, event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, userID := range userIDs {
+		for client := range h.clients[userID] {
+			select {
+			case client.Send <- event:
+			default:
+				log.Printf("Chat client of user %d is too slow, disconnecting", userID)
+				h.remove(client)
+			}
+		}
+	}
+}
+
+// remove deletes a client; the caller must hold the lock
+func (h *Hub) remove(client *Client) {
+	userClients, ok := h.clients[client.UserID]
+	if !ok {
+		return
+	}
+	if _, ok := userClients[client]; !ok {
+		return
+	}
+
+	delete(userClients, client)
+	close(client.Send)
+	if len(userClients) == 0 {
+		delete(h.clients, client.UserID)
+	}
+}
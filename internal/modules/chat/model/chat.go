@@ -1,9 +1,20 @@
 package model
 
 import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// Message types
+const (
+	MessageTypeText   = "text"
+	MessageTypeSystem = "system"
+	MessageTypeImage  = "image"
+)
+
 // Chat represents a chat between users
 type Chat struct {
 	ID            int       `db:"id" json:"id"`
@@ -11,21 +22,40 @@ type Chat struct {
 	User2ID       int       `db:"user2_id" json:"user2_id"`
 	ListingID     *int      `db:"listing_id" json:"listing_id,omitempty"`
 	LastMessageAt time.Time `db:"last_message_at" json:"last_message_at"`
+	LastSenderID  *int      `db:"last_message_user_id" json:"last_sender_id,omitempty"`
 	CreatedAt     time.Time `db:"created_at" json:"created_at"`
 	User1Name     string    `db:"user1_name" json:"user1_name,omitempty"`
 	User2Name     string    `db:"user2_name" json:"user2_name,omitempty"`
 	ListingTitle  string    `db:"listing_title" json:"listing_title,omitempty"`
 	LastMessage   string    `db:"last_message" json:"last_message,omitempty"`
+	UnreadCount   int       `db:"unread_count" json:"unread_count"`
 }
 
 // Message represents a chat message
 type Message struct {
-	ID         int       `db:"id" json:"id"`
-	ChatID     int       `db:"chat_id" json:"chat_id"`
-	SenderID   int       `db:"sender_id" json:"sender_id"`
-	Content    string    `db:"content" json:"content"`
-	CreatedAt  time.Time `db:"created_at" json:"created_at"`
-	SenderName string    `db:"sender_name" json:"sender_name,omitempty"`
+	ID          int          `db:"id" json:"id"`
+	ChatID      int          `db:"chat_id" json:"chat_id"`
+	SenderID    int          `db:"sender_id" json:"sender_id"`
+	Content     string       `db:"content" json:"content"`
+	Type        string       `db:"message_type" json:"type"`
+	IsRead      bool         `db:"is_read" json:"is_read"`
+	CreatedAt   time.Time    `db:"created_at" json:"created_at"`
+	SenderName  string       `db:"sender_name" json:"sender_name,omitempty"`
+	Attachments []Attachment `db:"-" json:"attachments"`
+}
+
+// Attachment represents an image attached to a chat message
+type Attachment struct {
+	ID          int       `db:"id" json:"id"`
+	MessageID   int       `db:"message_id" json:"message_id"`
+	FilePath    string    `db:"file_path" json:"-"`
+	FileName    string    `db:"file_name" json:"file_name"`
+	ContentType string    `db:"content_type" json:"content_type"`
+	Size        int64     `db:"size" json:"size"`
+	Width       int       `db:"width" json:"width"`
+	Height      int       `db:"height" json:"height"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	URL         string    `db:"-" json:"url"`
 }
 
 // InitiateChatRequest represents the data needed to start a chat
@@ -40,12 +70,53 @@ type SendMessageRequest struct {
 	Content string `json:"content" binding:"required"`
 }
 
-// ChatResponse represents a list of chats with pagination
+// MarkReadRequest represents the data needed to mark a chat as read
+type MarkReadRequest struct {
+	MessageID int `json:"message_id" binding:"required,min=1"`
+}
+
+// ChatCursor points at the last chat of a page in the chat list
+type ChatCursor struct {
+	LastMessageAt time.Time
+	ID            int
+}
+
+// Encode returns the opaque string form of the cursor
+func (c ChatCursor) Encode() string {
+	raw := strconv.FormatInt(c.LastMessageAt.UnixMicro(), 10) + ":" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseChatCursor decodes a cursor returned in ChatResponse.NextCursor
+func ParseChatCursor(value string) (*ChatCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 2 {
+		return nil, errors.New("invalid cursor")
+	}
+
+	micros, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	// Chat timestamps are stored without a time zone and read back as UTC
+	return &ChatCursor{LastMessageAt: time.UnixMicro(micros).UTC(), ID: id}, nil
+}
+
+// ChatResponse represents a page of chats ordered by last activity
 type ChatResponse struct {
-	Chats       []Chat `json:"chats"`
-	TotalCount  int    `json:"total_count"`
-	CurrentPage int    `json:"current_page"`
-	TotalPages  int    `json:"total_pages"`
+	Chats      []Chat `json:"chats"`
+	TotalCount int    `json:"total_count"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // MessageResponse represents a list of messages with pagination
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // Repository handles database operations for the chat module
@@ -31,7 +32,7 @@ func (r *Repository) GetChatByUsers(user1ID, user2ID int, listingID *int) (*mode
 	if listingID != nil {
 		err = r.db.Get(&chat, `
 			SELECT id, buyer_id as user1_id, seller_id as user2_id, listing_id,
-                   created_at, created_at as last_message_at
+                   created_at, last_message_at, last_message_user_id
 			FROM chats
 			WHERE (buyer_id = $1 AND seller_id = $2 AND listing_id = $3)
 			   OR (buyer_id = $2 AND seller_id = $1 AND listing_id = $3)
@@ -39,7 +40,7 @@ func (r *Repository) GetChatByUsers(user1ID, user2ID int, listingID *int) (*mode
 	} else {
 		err = r.db.Get(&chat, `
 			SELECT id, buyer_id as user1_id, seller_id as user2_id, listing_id,
-                   created_at, created_at as last_message_at
+                   created_at, last_message_at, last_message_user_id
 			FROM chats
 			WHERE ((buyer_id = $1 AND seller_id = $2) OR (buyer_id = $2 AND seller_id = $1))
 			AND listing_id IS NULL
@@ -61,8 +62,8 @@ func (r *Repository) GetChatByUsers(user1ID, user2ID int, listingID *int) (*mode
 func (r *Repository) CreateChat(buyerID, sellerID int, listingID *int) (int, error) {
 	var chatID int
 	err := r.db.QueryRow(`
-		INSERT INTO chats (buyer_id, seller_id, listing_id, created_at)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO chats (buyer_id, seller_id, listing_id, created_at, last_message_at)
+		VALUES ($1, $2, $3, $4, $4)
 		RETURNING id
 	`, buyerID, sellerID, listingID, time.Now()).Scan(&chatID)
 
@@ -74,28 +75,70 @@ func (r *Repository) CreateChat(buyerID, sellerID int, listingID *int) (int, err
 	return chatID, nil
 }
 
-// AddMessage adds a message to a chat
-func (r *Repository) AddMessage(chatID, senderID int, content string) (int, error) {
+// AddMessage adds a message of the given type to a chat
+func (r *Repository) AddMessage(chatID, senderID int, content, messageType string) (int, error) {
+	return r.AddMessageWithAttachments(chatID, senderID, content, messageType, nil)
+}
+
+// AddMessageWithAttachments adds a message with its attachments to a chat and
+// records it as the chat's last activity in the same transaction
+func (r *Repository) AddMessageWithAttachments(chatID, senderID int, content, messageType string, attachments []model.Attachment) (int, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		return 0, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
 	var messageID int
-	err := r.db.QueryRow(`
-		INSERT INTO messages (chat_id, user_id, content, created_at)
-		VALUES ($1, $2, $3, $4)
+	err = tx.QueryRow(`
+		INSERT INTO messages (chat_id, user_id, content, message_type, created_at)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id
-	`, chatID, senderID, content, time.Now()).Scan(&messageID)
+	`, chatID, senderID, content, messageType, now).Scan(&messageID)
 
 	if err != nil {
 		log.Printf("Error adding message: %v", err)
 		return 0, fmt.Errorf("error adding message: %w", err)
 	}
 
+	for _, attachment := range attachments {
+		_, err = tx.Exec(`
+			INSERT INTO message_attachments (message_id, file_path, file_name, content_type, size, width, height, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, messageID, attachment.FilePath, attachment.FileName, attachment.ContentType,
+			attachment.Size, attachment.Width, attachment.Height, now)
+
+		if err != nil {
+			log.Printf("Error adding message attachment: %v", err)
+			return 0, fmt.Errorf("error adding message attachment: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE chats SET last_message_at = $1, last_message_user_id = $2
+		WHERE id = $3
+	`, now, senderID, chatID)
+
+	if err != nil {
+		log.Printf("Error updating chat last message: %v", err)
+		return 0, fmt.Errorf("error updating chat last message: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+
 	return messageID, nil
 }
 
-// GetUserChats gets a user's chats with pagination
-func (r *Repository) GetUserChats(userID, page, limit int) (*model.ChatResponse, error) {
-	// Calculate offset
-	offset := (page - 1) * limit
-
+// GetUserChats gets a page of a user's chats ordered by last activity. The page
+// starts after the cursor chat if one is given; limit+1 rows are fetched to
+// detect whether there is a next page.
+func (r *Repository) GetUserChats(userID int, cursor *model.ChatCursor, limit int) (*model.ChatResponse, error) {
 	// Get total count
 	var totalCount int
 	err := r.db.Get(&totalCount, `
@@ -107,44 +150,54 @@ func (r *Repository) GetUserChats(userID, page, limit int) (*model.ChatResponse,
 		return nil, fmt.Errorf("error getting chat count: %w", err)
 	}
 
-	// Calculate total pages
-	totalPages := int(math.Ceil(float64(totalCount) / float64(limit)))
-
-	// Get chats
-	var chats []model.Chat
-	err = r.db.Select(&chats, `
-		SELECT c.id, 
-			   c.buyer_id as user1_id, 
-			   c.seller_id as user2_id, 
+	query := `
+		SELECT c.id,
+			   c.buyer_id as user1_id,
+			   c.seller_id as user2_id,
 			   c.listing_id,
 			   c.created_at,
-			   c.created_at as last_message_at,
+			   c.last_message_at,
+			   c.last_message_user_id,
 			   u1.name || ' ' || COALESCE(u1.last_name, '') as user1_name,
 			   u2.name || ' ' || COALESCE(u2.last_name, '') as user2_name,
 			   l.title as listing_title,
-			   COALESCE((SELECT content FROM messages 
-				WHERE chat_id = c.id 
-				ORDER BY created_at DESC LIMIT 1), '') as last_message
+			   COALESCE((SELECT content FROM messages
+				WHERE chat_id = c.id
+				ORDER BY id DESC LIMIT 1), '') as last_message,
+			   (SELECT COUNT(*) FROM messages
+				WHERE chat_id = c.id AND user_id <> $1 AND is_read = false) as unread_count
 		FROM chats c
 		JOIN users u1 ON c.buyer_id = u1.id
 		JOIN users u2 ON c.seller_id = u2.id
 		LEFT JOIN listings l ON c.listing_id = l.id
-		WHERE c.buyer_id = $1 OR c.seller_id = $1
-		ORDER BY c.created_at DESC
-		LIMIT $2 OFFSET $3
-	`, userID, limit, offset)
+		WHERE (c.buyer_id = $1 OR c.seller_id = $1)
+	`
+	args := []interface{}{userID, limit + 1}
+	if cursor != nil {
+		query += " AND (c.last_message_at, c.id) < ($3::timestamp, $4::int)"
+		args = append(args, cursor.LastMessageAt, cursor.ID)
+	}
+	query += " ORDER BY c.last_message_at DESC, c.id DESC LIMIT $2"
 
+	// Get chats
+	chats := []model.Chat{}
+	err = r.db.Select(&chats, query, args...)
 	if err != nil {
 		log.Printf("Error getting chats: %v", err)
 		return nil, fmt.Errorf("error getting chats: %w", err)
 	}
 
-	return &model.ChatResponse{
-		Chats:       chats,
-		TotalCount:  totalCount,
-		CurrentPage: page,
-		TotalPages:  totalPages,
-	}, nil
+	response := &model.ChatResponse{
+		Chats:      chats,
+		TotalCount: totalCount,
+	}
+	if len(chats) > limit {
+		response.Chats = chats[:limit]
+		last := response.Chats[limit-1]
+		response.NextCursor = model.ChatCursor{LastMessageAt: last.LastMessageAt, ID: last.ID}.Encode()
+	}
+
+	return response, nil
 }
 
 // GetChatMessages gets messages for a chat with pagination
@@ -166,7 +219,7 @@ func (r *Repository) GetChatMessages(chatID, page, limit int) (*model.MessageRes
 	// Get messages
 	var messages []model.Message
 	err = r.db.Select(&messages, `
-		SELECT m.id, m.chat_id, m.user_id as sender_id, m.content, m.created_at,
+		SELECT m.id, m.chat_id, m.user_id as sender_id, m.content, m.message_type, m.is_read, m.created_at,
 			   u.name || ' ' || COALESCE(u.last_name, '') as sender_name
 		FROM messages m
 		JOIN users u ON m.user_id = u.id
@@ -180,6 +233,10 @@ func (r *Repository) GetChatMessages(chatID, page, limit int) (*model.MessageRes
 		return nil, fmt.Errorf("error getting messages: %w", err)
 	}
 
+	if err = r.loadAttachments(messages); err != nil {
+		return nil, err
+	}
+
 	return &model.MessageResponse{
 		Messages:    messages,
 		TotalCount:  totalCount,
@@ -193,7 +250,7 @@ func (r *Repository) GetChatByID(chatID int) (*model.Chat, error) {
 	var chat model.Chat
 	err := r.db.Get(&chat, `
 		SELECT c.id, c.buyer_id as user1_id, c.seller_id as user2_id, c.listing_id,
-			   c.created_at, c.created_at as last_message_at,
+			   c.created_at, c.last_message_at, c.last_message_user_id,
 			   u1.name || ' ' || COALESCE(u1.last_name, '') as user1_name,
 			   u2.name || ' ' || COALESCE(u2.last_name, '') as user2_name,
 			   l.title as listing_title
@@ -227,3 +284,162 @@ func (r *Repository) CheckChatAccess(chatID, userID int) (bool, error) {
 
 	return count > 0, nil
 }
+
+// GetMessageByID retrieves a single message with its sender name
+func (r *Repository) GetMessageByID(messageID int) (*model.Message, error) {
+	var message model.Message
+	err := r.db.Get(&message, `
+		SELECT m.id, m.chat_id, m.user_id as sender_id, m.content, m.message_type, m.is_read, m.created_at,
+			   u.name || ' ' || COALESCE(u.last_name, '') as sender_name
+		FROM messages m
+		JOIN users u ON m.user_id = u.id
+		WHERE m.id = $1
+	`, messageID)
+
+	if err != nil {
+		log.Printf("Error getting message by ID: %v", err)
+		return nil, fmt.Errorf("error getting message: %w", err)
+	}
+
+	messages := []model.Message{message}
+	if err = r.loadAttachments(messages); err != nil {
+		return nil, err
+	}
+
+	return &messages[0], nil
+}
+
+// GetMessagesSince gets messages newer than lastMessageID from all chats of a user
+func (r *Repository) GetMessagesSince(userID, lastMessageID, limit int) ([]model.Message, error) {
+	messages := []model.Message{}
+	err := r.db.Select(&messages, `
+		SELECT m.id, m.chat_id, m.user_id as sender_id, m.content, m.message_type, m.is_read, m.created_at,
+			   u.name || ' ' || COALESCE(u.last_name, '') as sender_name
+		FROM messages m
+		JOIN chats c ON m.chat_id = c.id
+		JOIN users u ON m.user_id = u.id
+		WHERE (c.buyer_id = $1 OR c.seller_id = $1) AND m.id > $2
+		ORDER BY m.id ASC
+		LIMIT $3
+	`, userID, lastMessageID, limit)
+
+	if err != nil {
+		log.Printf("Error getting messages since %d: %v", lastMessageID, err)
+		return nil, fmt.Errorf("error getting messages: %w", err)
+	}
+
+	if err = r.loadAttachments(messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// MarkChatRead marks the other participant's messages in a chat as read up to
+// and including the given message and returns the number of updated messages
+func (r *Repository) MarkChatRead(chatID, userID, upToMessageID int) (int, error) {
+	result, err := r.db.Exec(`
+		UPDATE messages SET is_read = true
+		WHERE chat_id = $1 AND user_id <> $2 AND id <= $3 AND is_read = false
+	`, chatID, userID, upToMessageID)
+
+	if err != nil {
+		log.Printf("Error marking chat as read: %v", err)
+		return 0, fmt.Errorf("error marking chat as read: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return int(rows), nil
+}
+
+// SessionActive checks that a login session of a verified user is neither revoked nor expired
+func (r *Repository) SessionActive(userID, sessionID int) (bool, error) {
+	var active bool
+	err := r.db.Get(&active, `
+		SELECT EXISTS(
+			SELECT 1 FROM sessions s
+			JOIN users u ON u.id = s.user_id
+			WHERE s.id = $1 AND s.user_id = $2 AND s.revoked_at IS NULL AND s.expires_at > NOW()
+				AND u.is_verified = true
+		)
+	`, sessionID, userID)
+	if err != nil {
+		log.Printf("Error checking session: %v", err)
+		return false, fmt.Errorf("error checking session: %w", err)
+	}
+	return active, nil
+}
+
+// GetUnreadCount gets the total number of unread messages across all chats of a user
+func (r *Repository) GetUnreadCount(userID int) (int, error) {
+	var count int
+	err := r.db.Get(&count, `
+		SELECT COUNT(*) FROM messages m
+		JOIN chats c ON m.chat_id = c.id
+		WHERE (c.buyer_id = $1 OR c.seller_id = $1) AND m.user_id <> $1 AND m.is_read = false
+	`, userID)
+
+	if err != nil {
+		log.Printf("Error getting unread count: %v", err)
+		return 0, fmt.Errorf("error getting unread count: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetAttachment retrieves an attachment of a message in the given chat
+func (r *Repository) GetAttachment(chatID, attachmentID int) (*model.Attachment, error) {
+	var attachment model.Attachment
+	err := r.db.Get(&attachment, `
+		SELECT a.id, a.message_id, a.file_path, a.file_name, a.content_type, a.size, a.width, a.height, a.created_at
+		FROM message_attachments a
+		JOIN messages m ON a.message_id = m.id
+		WHERE a.id = $1 AND m.chat_id = $2
+	`, attachmentID, chatID)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Attachment doesn't exist in this chat
+		}
+		log.Printf("Error getting attachment: %v", err)
+		return nil, fmt.Errorf("error getting attachment: %w", err)
+	}
+
+	return &attachment, nil
+}
+
+// loadAttachments fills in the attachments of the given messages
+func (r *Repository) loadAttachments(messages []model.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	messageIDs := make([]int, len(messages))
+	byMessage := make(map[int]*model.Message, len(messages))
+	for i := range messages {
+		messages[i].Attachments = []model.Attachment{} // Initialize with empty slice to avoid null in JSON
+		messageIDs[i] = messages[i].ID
+		byMessage[messages[i].ID] = &messages[i]
+	}
+
+	var attachments []model.Attachment
+	err := r.db.Select(&attachments, `
+		SELECT id, message_id, file_path, file_name, content_type, size, width, height, created_at
+		FROM message_attachments
+		WHERE message_id = ANY($1)
+		ORDER BY id
+	`, pq.Array(messageIDs))
+
+	if err != nil {
+		log.Printf("Error getting message attachments: %v", err)
+		return fmt.Errorf("error getting message attachments: %w", err)
+	}
+
+	for _, attachment := range attachments {
+		message := byMessage[attachment.MessageID]
+		attachment.URL = fmt.Sprintf("/api/chats/%d/attachments/%d", message.ChatID, attachment.ID)
+		message.Attachments = append(message.Attachments, attachment)
+	}
+
+	return nil
+}
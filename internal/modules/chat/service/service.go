@@ -1,21 +1,41 @@
 package service
 
 import (
+	"FurniSwap/internal/modules/chat/hub"
 	"FurniSwap/internal/modules/chat/model"
 	"FurniSwap/internal/modules/chat/repository"
+	moderationRepo "FurniSwap/internal/modules/moderation/repository"
+	"FurniSwap/pkg/utils"
 	"errors"
 	"fmt"
+	"log"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
 )
 
+// resumeLimit caps the number of missed messages replayed to a reconnecting client
+const resumeLimit = 500
+
+// attachmentRendition is the name of the single stored size of an image attachment
+const attachmentRendition = "attachment"
+
+// attachmentRenditions are the sizes image attachments are stored in
+var attachmentRenditions = []utils.Rendition{{Name: attachmentRendition, MaxSize: 2048}}
+
 // Service provides chat operations
 type Service struct {
-	repo *repository.Repository
+	repo      *repository.Repository
+	hub       *hub.Hub
+	blockRepo *moderationRepo.Repository
 }
 
 // NewService creates a new chat service
-func NewService(repo *repository.Repository) *Service {
+func NewService(repo *repository.Repository, hub *hub.Hub, blockRepo *moderationRepo.Repository) *Service {
 	return &Service{
-		repo: repo,
+		repo:      repo,
+		hub:       hub,
+		blockRepo: blockRepo,
 	}
 }
 
@@ -29,6 +49,11 @@ func (s *Service) InitiateChat(userID int, req model.InitiateChatRequest) (int,
 	// Validate recipient user exists (to be implemented)
 	// This would check for the existence of the recipient in the user database
 
+	// Don't allow chats between users who blocked each other
+	if err := s.checkNotBlocked(userID, req.RecipientID); err != nil {
+		return 0, err
+	}
+
 	// Check if chat already exists
 	existingChat, err := s.repo.GetChatByUsers(userID, req.RecipientID, req.ListingID)
 	if err != nil {
@@ -47,44 +72,151 @@ func (s *Service) InitiateChat(userID int, req model.InitiateChatRequest) (int,
 	}
 
 	// Add initial message
-	_, err = s.repo.AddMessage(chatID, userID, req.Message)
+	messageID, err := s.repo.AddMessage(chatID, userID, req.Message, model.MessageTypeText)
 	if err != nil {
 		return 0, fmt.Errorf("error adding message: %w", err)
 	}
 
+	s.publishMessage(messageID)
+
 	return chatID, nil
 }
 
 // SendMessage sends a message in a chat
 func (s *Service) SendMessage(chatID, userID int, req model.SendMessageRequest) (int, error) {
+	if err := s.checkCanSend(chatID, userID); err != nil {
+		return 0, err
+	}
+
+	// Add message
+	messageID, err := s.repo.AddMessage(chatID, userID, req.Content, model.MessageTypeText)
+	if err != nil {
+		return 0, fmt.Errorf("error adding message: %w", err)
+	}
+
+	s.publishMessage(messageID)
+
+	return messageID, nil
+}
+
+// SendImageMessage sends a message with image attachments and an optional caption in a chat
+func (s *Service) SendImageMessage(chatID, userID int, content string, files []*multipart.FileHeader) (int, error) {
+	if err := s.checkCanSend(chatID, userID); err != nil {
+		return 0, err
+	}
+
+	// Store images in the private folder so they are only served to chat participants.
+	// They are verified by content and re-encoded, which drops metadata such as GPS location.
+	folder := fmt.Sprintf("%s/chats/%d", utils.PrivateUploadsFolder, chatID)
+	attachments := make([]model.Attachment, 0, len(files))
+	for _, file := range files {
+		renditions, err := utils.ProcessImageUpload(file, folder, attachmentRenditions)
+		if err != nil {
+			deleteAttachmentFiles(attachments)
+			switch {
+			case errors.Is(err, utils.ErrInvalidImage):
+				return 0, errors.New("invalid image file")
+			case errors.Is(err, utils.ErrImageTooLarge):
+				return 0, errors.New("image is too large")
+			}
+			return 0, fmt.Errorf("error uploading attachment: %w", err)
+		}
+
+		stored := renditions[attachmentRendition]
+		attachments = append(attachments, model.Attachment{
+			FilePath:    stored.Path,
+			FileName:    strings.TrimSuffix(file.Filename, filepath.Ext(file.Filename)) + filepath.Ext(stored.Path),
+			ContentType: stored.ContentType,
+			Size:        stored.Size,
+			Width:       stored.Width,
+			Height:      stored.Height,
+		})
+	}
+
+	messageID, err := s.repo.AddMessageWithAttachments(chatID, userID, content, model.MessageTypeImage, attachments)
+	if err != nil {
+		// If there's an error adding to the database, delete the uploaded files
+		deleteAttachmentFiles(attachments)
+		return 0, fmt.Errorf("error adding message: %w", err)
+	}
+
+	s.publishMessage(messageID)
+
+	return messageID, nil
+}
+
+// GetAttachment gets an attachment of a chat message if the user is a chat participant
+func (s *Service) GetAttachment(chatID, attachmentID, userID int) (*model.Attachment, error) {
 	// Check if user has access to the chat
 	hasAccess, err := s.repo.CheckChatAccess(chatID, userID)
 	if err != nil {
-		return 0, fmt.Errorf("error checking chat access: %w", err)
+		return nil, fmt.Errorf("error checking chat access: %w", err)
 	}
 
 	if !hasAccess {
-		return 0, errors.New("you don't have access to this chat")
+		return nil, errors.New("you don't have access to this chat")
 	}
 
-	// Add message
-	messageID, err := s.repo.AddMessage(chatID, userID, req.Content)
+	attachment, err := s.repo.GetAttachment(chatID, attachmentID)
 	if err != nil {
-		return 0, fmt.Errorf("error adding message: %w", err)
+		return nil, err
+	}
+	if attachment == nil {
+		return nil, errors.New("attachment not found")
 	}
 
-	return messageID, nil
+	return attachment, nil
 }
 
-// GetUserChats gets all chats for a user
-func (s *Service) GetUserChats(userID, page, limit int) (*model.ChatResponse, error) {
-	if page < 1 {
-		page = 1
+// PostSystemMessage posts a system message on behalf of the sender into the
+// chat between the buyer and the seller about a listing, creating the chat if needed.
+// Like other messages, it is refused if either user has blocked the other.
+func (s *Service) PostSystemMessage(senderID, buyerID, sellerID int, listingID *int, content string) (int, error) {
+	if err := s.checkNotBlocked(buyerID, sellerID); err != nil {
+		return 0, err
+	}
+
+	existingChat, err := s.repo.GetChatByUsers(buyerID, sellerID, listingID)
+	if err != nil {
+		return 0, fmt.Errorf("error checking existing chat: %w", err)
 	}
+
+	var chatID int
+	if existingChat != nil {
+		chatID = existingChat.ID
+	} else {
+		chatID, err = s.repo.CreateChat(buyerID, sellerID, listingID)
+		if err != nil {
+			return 0, fmt.Errorf("error creating chat: %w", err)
+		}
+	}
+
+	messageID, err := s.repo.AddMessage(chatID, senderID, content, model.MessageTypeSystem)
+	if err != nil {
+		return 0, fmt.Errorf("error adding system message: %w", err)
+	}
+
+	s.publishMessage(messageID)
+
+	return chatID, nil
+}
+
+// GetUserChats gets a page of the user's chats, most recently active first
+func (s *Service) GetUserChats(userID int, cursor string, limit int) (*model.ChatResponse, error) {
 	if limit < 1 || limit > 50 {
 		limit = 10
 	}
-	return s.repo.GetUserChats(userID, page, limit)
+
+	var after *model.ChatCursor
+	if cursor != "" {
+		var err error
+		after, err = model.ParseChatCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.repo.GetUserChats(userID, after, limit)
 }
 
 // GetChatMessages gets all messages in a chat
@@ -122,3 +254,142 @@ func (s *Service) GetChatByID(chatID, userID int) (*model.Chat, error) {
 
 	return s.repo.GetChatByID(chatID)
 }
+
+// Subscribe registers a new real-time connection of the user
+func (s *Service) Subscribe(userID int) *hub.Client {
+	return s.hub.Register(userID)
+}
+
+// Unsubscribe removes a real-time connection
+func (s *Service) Unsubscribe(client *hub.Client) {
+	s.hub.Unregister(client)
+}
+
+// SessionActive checks that the login session a connection was opened with is still valid
+func (s *Service) SessionActive(userID, sessionID int) (bool, error) {
+	return s.repo.SessionActive(userID, sessionID)
+}
+
+// GetMessagesSince gets the messages a reconnecting user missed after lastMessageID
+func (s *Service) GetMessagesSince(userID, lastMessageID int) ([]model.Message, error) {
+	return s.repo.GetMessagesSince(userID, lastMessageID, resumeLimit)
+}
+
+// SendTyping notifies the other chat participant that the user is typing
+func (s *Service) SendTyping(chatID, userID int) error {
+	chat, err := s.GetChatByID(chatID, userID)
+	if err != nil {
+		return err
+	}
+
+	// Blocked users don't see each other typing
+	if err := s.checkChatNotBlocked(chat, userID); err != nil {
+		return err
+	}
+
+	s.publishToPeer(chat, userID, hub.Event{Type: hub.EventTyping, ChatID: chatID, UserID: userID})
+	return nil
+}
+
+// MarkChatRead marks the chat as read by the user up to the given message and
+// sends a read receipt to the other participant unless either has blocked the other
+func (s *Service) MarkChatRead(chatID, userID, messageID int) (int, error) {
+	chat, err := s.GetChatByID(chatID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	marked, err := s.repo.MarkChatRead(chatID, userID, messageID)
+	if err != nil {
+		return 0, err
+	}
+
+	// The chat is still marked read for the user, but blocked users get no read receipts
+	if err := s.checkChatNotBlocked(chat, userID); err != nil {
+		return marked, nil
+	}
+
+	s.publishToPeer(chat, userID, hub.Event{Type: hub.EventRead, ChatID: chatID, UserID: userID, MessageID: messageID})
+	return marked, nil
+}
+
+// GetUnreadCount gets the total number of unread messages of a user
+func (s *Service) GetUnreadCount(userID int) (int, error) {
+	return s.repo.GetUnreadCount(userID)
+}
+
+// checkCanSend checks that the user is a chat participant and neither
+// participant has blocked the other
+func (s *Service) checkCanSend(chatID, userID int) error {
+	chat, err := s.GetChatByID(chatID, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.checkChatNotBlocked(chat, userID)
+}
+
+// checkChatNotBlocked returns an error if either participant of a chat has blocked the other
+func (s *Service) checkChatNotBlocked(chat *model.Chat, userID int) error {
+	peerID := chat.User1ID
+	if peerID == userID {
+		peerID = chat.User2ID
+	}
+
+	return s.checkNotBlocked(userID, peerID)
+}
+
+// checkNotBlocked returns an error if either user has blocked the other
+func (s *Service) checkNotBlocked(userID, otherID int) error {
+	blocked, err := s.blockRepo.IsBlockedBetween(userID, otherID)
+	if err != nil {
+		return fmt.Errorf("error checking user blocks: %w", err)
+	}
+
+	if blocked {
+		return errors.New("you cannot message this user")
+	}
+
+	return nil
+}
+
+// publishToPeer sends an event from a chat participant to the other participant
+func (s *Service) publishToPeer(chat *model.Chat, userID int, event hub.Event) {
+	peerID := chat.User1ID
+	if peerID == userID {
+		peerID = chat.User2ID
+	}
+
+	s.hub.Publish([]int{peerID}, event)
+}
+
+// publishMessage pushes a newly written message to both chat participants.
+// Delivery is best effort: clients that miss it resume from their last seen message.
+func (s *Service) publishMessage(messageID int) {
+	message, err := s.repo.GetMessageByID(messageID)
+	if err != nil {
+		log.Printf("Error loading message %d for delivery: %v", messageID, err)
+		return
+	}
+
+	chat, err := s.repo.GetChatByID(message.ChatID)
+	if err != nil {
+		log.Printf("Error loading chat %d for delivery: %v", message.ChatID, err)
+		return
+	}
+
+	s.hub.Publish([]int{chat.User1ID, chat.User2ID}, hub.Event{
+		Type:      hub.EventMessage,
+		ChatID:    message.ChatID,
+		UserID:    message.SenderID,
+		MessageID: message.ID,
+		Message:   message,
+	})
+}
+
+// deleteAttachmentFiles removes already uploaded attachment files
+func deleteAttachmentFiles(attachments []model.Attachment) {
+	for _, attachment := range attachments {
+		_ = utils.DeleteFile(attachment.FilePath)
+	}
+}
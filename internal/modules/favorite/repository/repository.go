@@ -129,11 +129,12 @@ func (r *Repository) GetFavorites(userID, page, limit int) (*model.FavoriteRespo
 		}
 
 		// Get images for the listing
-		err = r.db.Select(&listing.Images, "SELECT * FROM listing_images WHERE listing_id = $1 ORDER BY is_main DESC, created_at ASC", listing.ID)
+		err = r.db.Select(&listing.Images, "SELECT * FROM listing_images WHERE listing_id = $1 ORDER BY position, id", listing.ID)
 		if err != nil {
 			log.Printf("Error getting images for listing %d: %v", listing.ID, err)
 			// Continue without images if there's an error
 		}
+		listingModel.SetImageURLs(listing.Images)
 
 		favorites[i].Listing = &listing
 	}
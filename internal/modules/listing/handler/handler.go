@@ -3,7 +3,11 @@ package handler
 import (
 	"FurniSwap/internal/modules/listing/model"
 	"FurniSwap/internal/modules/listing/service"
+	"FurniSwap/pkg/utils"
+	"errors"
+	"fmt"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 
@@ -25,15 +29,22 @@ func NewHandler(service *service.Service) *Handler {
 // RegisterPublicRoutes registers public listing routes (no auth required)
 func (h *Handler) RegisterPublicRoutes(router *gin.RouterGroup) {
 	router.GET("", h.GetListings)
+	router.GET("/attributes", h.GetAttributes)
 	router.GET("/:id", h.GetListing)
 }
 
+// RegisterReferenceRoutes registers public reference data routes (no auth required)
+func (h *Handler) RegisterReferenceRoutes(router *gin.RouterGroup) {
+	router.GET("/conditions", h.GetConditions)
+}
+
 // RegisterProtectedRoutes registers protected listing routes (auth required)
 func (h *Handler) RegisterProtectedRoutes(router *gin.RouterGroup) {
 	router.POST("/listings", h.CreateListing)
 	router.PUT("/listings/:id", h.UpdateListing)
 	router.DELETE("/listings/:id", h.DeleteListing)
 	router.POST("/listings/:id/images", h.UploadListingImage)
+	router.PUT("/listings/:id/images/order", h.ReorderListingImages)
 	router.DELETE("/listings/:id/images/:imageId", h.DeleteListingImage)
 	router.PUT("/listings/:id/images/:imageId/main", h.SetMainImage)
 	router.GET("/listings/my", h.GetUserListings)
@@ -67,6 +78,11 @@ func (h *Handler) GetListings(c *gin.Context) {
 		return
 	}
 
+	// Get user ID from context if the request is authenticated (set by optional auth middleware)
+	if userID, exists := c.Get("userID"); exists {
+		filter.ViewerID = userID.(int)
+	}
+
 	// Check if there's a search query
 	search := c.Query("search")
 	var response *model.ListingResponse
@@ -81,14 +97,48 @@ func (h *Handler) GetListings(c *gin.Context) {
 	}
 
 	if err != nil {
-		log.Printf("Error getting listings: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting listings"})
+		switch err.Error() {
+		case "invalid near parameter":
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid near parameter, expected lat,lon"})
+		case "invalid condition":
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid condition"})
+		case "location is required":
+			c.JSON(http.StatusBadRequest, gin.H{"error": "near is required to filter or sort by distance"})
+		default:
+			log.Printf("Error getting listings: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting listings"})
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// GetAttributes handles getting the furniture attributes of a category, or all
+// attributes if no category_id is given
+func (h *Handler) GetAttributes(c *gin.Context) {
+	categoryID, err := strconv.Atoi(c.DefaultQuery("category_id", "0"))
+	if err != nil || categoryID < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	attributes, err := h.service.GetAttributeDefinitions(categoryID)
+	if err != nil {
+		log.Printf("Error getting attributes: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting attributes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, attributes)
+}
+
+// GetConditions handles getting the listing conditions; labels are in the lang
+// query parameter language (ru or en), Russian by default
+func (h *Handler) GetConditions(c *gin.Context) {
+	c.JSON(http.StatusOK, h.service.GetConditions(c.DefaultQuery("lang", model.DefaultLanguage)))
+}
+
 // GetListing handles getting a single listing
 func (h *Handler) GetListing(c *gin.Context) {
 	// Parse listing ID
@@ -128,6 +178,19 @@ func (h *Handler) CreateListing(c *gin.Context) {
 	// Create listing
 	listingID, err := h.service.CreateListing(userID.(int), req)
 	if err != nil {
+		if err.Error() == "invalid condition" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid condition"})
+			return
+		}
+		if err.Error() == "latitude and longitude must be set together" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Latitude and longitude must be set together"})
+			return
+		}
+		var attributeErr *model.AttributeError
+		if errors.As(err, &attributeErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": attributeErr.Error()})
+			return
+		}
 		log.Printf("Error creating listing: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating listing"})
 		return
@@ -170,6 +233,19 @@ func (h *Handler) UpdateListing(c *gin.Context) {
 	// Update listing
 	err = h.service.UpdateListing(listingID, userID.(int), req)
 	if err != nil {
+		if err.Error() == "invalid condition" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid condition"})
+			return
+		}
+		if err.Error() == "latitude and longitude must be set together" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Latitude and longitude must be set together"})
+			return
+		}
+		var attributeErr *model.AttributeError
+		if errors.As(err, &attributeErr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": attributeErr.Error()})
+			return
+		}
 		if err.Error() == "listing not found or does not belong to the user" {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Listing not found or you don't have permission to update it"})
 			return
@@ -237,39 +313,39 @@ func (h *Handler) UploadListingImage(c *gin.Context) {
 		return
 	}
 
-	// Check if the request has a file or an image URL
-	var imageID int
+	// Check if the request has files or an image URL
+	var imageIDs []int
 	var imageErr error
 
 	if url := c.PostForm("image_url"); url != "" {
 		// Use the provided image URL
+		var imageID int
 		imageID, imageErr = h.service.AddListingImageURL(listingID, userID.(int), url)
+		imageIDs = []int{imageID}
 	} else {
-		// Get file from form for backwards compatibility
-		file, err := c.FormFile("image")
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No image URL or file provided"})
-			return
+		// Files come in "images", several at once, or in "image" for backwards compatibility
+		var files []*multipart.FileHeader
+		if form, err := c.MultipartForm(); err == nil {
+			files = append(form.File["images"], form.File["image"]...)
 		}
-
-		// Check file type
-		contentType := file.Header.Get("Content-Type")
-		if contentType != "image/jpeg" && contentType != "image/png" && contentType != "image/gif" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "File must be an image (JPEG, PNG, or GIF)"})
+		if len(files) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No image URL or file provided"})
 			return
 		}
 
-		// Upload image
-		imageID, imageErr = h.service.UploadListingImage(listingID, userID.(int), file)
+		// Upload images; their type is verified by content, not by the client Content-Type
+		imageIDs, imageErr = h.service.UploadListingImages(listingID, userID.(int), files)
 	}
 
 	if imageErr != nil {
-		if imageErr.Error() == "listing does not belong to the user" {
+		switch imageErr.Error() {
+		case "listing does not belong to the user":
 			c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to upload images to this listing"})
-			return
+		case "too many images":
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A listing can have at most %d images", model.MaxImages)})
+		default:
+			h.handleImageError(c, imageErr, "Error adding image")
 		}
-		log.Printf("Error adding image: %v", imageErr)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error adding image"})
 		return
 	}
 
@@ -277,7 +353,7 @@ func (h *Handler) UploadListingImage(c *gin.Context) {
 	listing, err := h.service.GetListing(listingID)
 	if err != nil {
 		log.Printf("Error getting updated listing: %v", err)
-		c.JSON(http.StatusOK, gin.H{"id": imageID, "message": "Image added successfully"})
+		c.JSON(http.StatusOK, gin.H{"ids": imageIDs, "message": "Images added successfully"})
 		return
 	}
 
@@ -329,6 +405,54 @@ func (h *Handler) DeleteListingImage(c *gin.Context) {
 	c.JSON(http.StatusOK, listing)
 }
 
+// ReorderListingImages handles setting the order of the images of a listing
+func (h *Handler) ReorderListingImages(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse listing ID
+	listingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid listing ID"})
+		return
+	}
+
+	// Parse request body
+	var req model.ReorderImagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	err = h.service.ReorderListingImages(listingID, userID.(int), req.ImageIDs)
+	if err != nil {
+		switch err.Error() {
+		case "listing not found or does not belong to the user":
+			c.JSON(http.StatusForbidden, gin.H{"error": "Listing not found or you don't have permission to modify it"})
+		case "image order must list every image of the listing once":
+			c.JSON(http.StatusBadRequest, gin.H{"error": "image_ids must list every image of the listing exactly once"})
+		default:
+			log.Printf("Error reordering images: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reordering images"})
+		}
+		return
+	}
+
+	// Get the updated listing
+	listing, err := h.service.GetListing(listingID)
+	if err != nil {
+		log.Printf("Error getting updated listing: %v", err)
+		c.JSON(http.StatusOK, gin.H{"message": "Images reordered successfully"})
+		return
+	}
+
+	c.JSON(http.StatusOK, listing)
+}
+
 // SetMainImage handles setting an image as the main image for a listing
 func (h *Handler) SetMainImage(c *gin.Context) {
 	// Get user ID from context (set by auth middleware)
@@ -396,3 +520,22 @@ func (h *Handler) GetUserListings(c *gin.Context) {
 		"count":    len(listings),
 	})
 }
+
+// handleImageError maps image upload and download errors to HTTP responses
+func (h *Handler) handleImageError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, utils.ErrInvalidImage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File must be an image (JPEG, PNG, GIF or WebP)"})
+	case errors.Is(err, utils.ErrImageTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is too large"})
+	case errors.Is(err, utils.ErrInvalidImageURL):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image URL, must start with http:// or https://"})
+	case errors.Is(err, utils.ErrImageURLBlocked):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image URL must point to a public host"})
+	case errors.Is(err, utils.ErrImageDownload):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not download the image"})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
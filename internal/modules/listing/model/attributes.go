@@ -0,0 +1,256 @@
+package model
+
+import "fmt"
+
+// Attribute names, matching the listing columns
+const (
+	AttributeWidth            = "width_cm"
+	AttributeDepth            = "depth_cm"
+	AttributeHeight           = "height_cm"
+	AttributeWeight           = "weight_kg"
+	AttributeMaterial         = "material"
+	AttributeColor            = "color"
+	AttributeStyle            = "style"
+	AttributeAssemblyRequired = "assembly_required"
+)
+
+// Attribute value types
+const (
+	AttributeTypeNumber  = "number"
+	AttributeTypeEnum    = "enum"
+	AttributeTypeBoolean = "boolean"
+)
+
+// Attributes represents the structured characteristics of a piece of furniture
+type Attributes struct {
+	WidthCm          *float64 `db:"width_cm" json:"width_cm,omitempty"`
+	DepthCm          *float64 `db:"depth_cm" json:"depth_cm,omitempty"`
+	HeightCm         *float64 `db:"height_cm" json:"height_cm,omitempty"`
+	WeightKg         *float64 `db:"weight_kg" json:"weight_kg,omitempty"`
+	Material         *string  `db:"material" json:"material,omitempty"`
+	Color            *string  `db:"color" json:"color,omitempty"`
+	Style            *string  `db:"style" json:"style,omitempty"`
+	AssemblyRequired *bool    `db:"assembly_required" json:"assembly_required,omitempty"`
+}
+
+// AttributeDefinition describes an attribute and the values it accepts
+type AttributeDefinition struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Unit     string   `json:"unit,omitempty"`
+	Min      float64  `json:"min,omitempty"`
+	Max      float64  `json:"max,omitempty"`
+	Values   []string `json:"values,omitempty"`
+	Required bool     `json:"required"`
+}
+
+// AttributeDefinitions lists all supported attributes
+var AttributeDefinitions = []AttributeDefinition{
+	{Name: AttributeWidth, Type: AttributeTypeNumber, Unit: "cm", Min: 1, Max: 1000},
+	{Name: AttributeDepth, Type: AttributeTypeNumber, Unit: "cm", Min: 1, Max: 1000},
+	{Name: AttributeHeight, Type: AttributeTypeNumber, Unit: "cm", Min: 1, Max: 1000},
+	{Name: AttributeWeight, Type: AttributeTypeNumber, Unit: "kg", Min: 0.1, Max: 1000},
+	{Name: AttributeMaterial, Type: AttributeTypeEnum, Values: []string{
+		"wood", "chipboard", "mdf", "metal", "glass", "plastic", "fabric", "leather", "eco_leather", "rattan", "stone", "other",
+	}},
+	{Name: AttributeColor, Type: AttributeTypeEnum, Values: []string{
+		"white", "black", "gray", "beige", "brown", "red", "orange", "yellow", "green", "blue", "purple", "pink", "multicolor", "other",
+	}},
+	{Name: AttributeStyle, Type: AttributeTypeEnum, Values: []string{
+		"modern", "classic", "scandinavian", "loft", "minimalism", "provence", "vintage", "other",
+	}},
+	{Name: AttributeAssemblyRequired, Type: AttributeTypeBoolean},
+}
+
+// CategoryAttribute represents an attribute in the schema of a category
+type CategoryAttribute struct {
+	Attribute string `db:"attribute" json:"attribute"`
+	Required  bool   `db:"required" json:"required"`
+}
+
+// AttributeFilter represents the attribute filter criteria for listings. Enum
+// filters accept several comma-separated values.
+type AttributeFilter struct {
+	MinWidth         *float64 `form:"min_width" binding:"omitempty,min=0"`
+	MaxWidth         *float64 `form:"max_width" binding:"omitempty,min=0"`
+	MinDepth         *float64 `form:"min_depth" binding:"omitempty,min=0"`
+	MaxDepth         *float64 `form:"max_depth" binding:"omitempty,min=0"`
+	MinHeight        *float64 `form:"min_height" binding:"omitempty,min=0"`
+	MaxHeight        *float64 `form:"max_height" binding:"omitempty,min=0"`
+	MinWeight        *float64 `form:"min_weight" binding:"omitempty,min=0"`
+	MaxWeight        *float64 `form:"max_weight" binding:"omitempty,min=0"`
+	Material         string   `form:"material"`
+	Color            string   `form:"color"`
+	Style            string   `form:"style"`
+	AssemblyRequired *bool    `form:"assembly_required"`
+}
+
+// AttributeError is returned when listing attributes don't match the category schema
+type AttributeError struct {
+	Message string
+}
+
+func (e *AttributeError) Error() string {
+	return e.Message
+}
+
+// Values returns the set attributes by name
+func (a Attributes) Values() map[string]interface{} {
+	values := make(map[string]interface{})
+	for name, value := range map[string]*float64{
+		AttributeWidth:  a.WidthCm,
+		AttributeDepth:  a.DepthCm,
+		AttributeHeight: a.HeightCm,
+		AttributeWeight: a.WeightKg,
+	} {
+		if value != nil {
+			values[name] = *value
+		}
+	}
+	for name, value := range map[string]*string{
+		AttributeMaterial: a.Material,
+		AttributeColor:    a.Color,
+		AttributeStyle:    a.Style,
+	} {
+		if value != nil {
+			values[name] = *value
+		}
+	}
+	if a.AssemblyRequired != nil {
+		values[AttributeAssemblyRequired] = *a.AssemblyRequired
+	}
+	return values
+}
+
+// Merge returns the attributes with the set values of update applied
+func (a Attributes) Merge(update Attributes) Attributes {
+	if update.WidthCm != nil {
+		a.WidthCm = update.WidthCm
+	}
+	if update.DepthCm != nil {
+		a.DepthCm = update.DepthCm
+	}
+	if update.HeightCm != nil {
+		a.HeightCm = update.HeightCm
+	}
+	if update.WeightKg != nil {
+		a.WeightKg = update.WeightKg
+	}
+	if update.Material != nil {
+		a.Material = update.Material
+	}
+	if update.Color != nil {
+		a.Color = update.Color
+	}
+	if update.Style != nil {
+		a.Style = update.Style
+	}
+	if update.AssemblyRequired != nil {
+		a.AssemblyRequired = update.AssemblyRequired
+	}
+	return a
+}
+
+// Only returns the attributes keeping just the named ones
+func (a Attributes) Only(names map[string]bool) Attributes {
+	var result Attributes
+	if names[AttributeWidth] {
+		result.WidthCm = a.WidthCm
+	}
+	if names[AttributeDepth] {
+		result.DepthCm = a.DepthCm
+	}
+	if names[AttributeHeight] {
+		result.HeightCm = a.HeightCm
+	}
+	if names[AttributeWeight] {
+		result.WeightKg = a.WeightKg
+	}
+	if names[AttributeMaterial] {
+		result.Material = a.Material
+	}
+	if names[AttributeColor] {
+		result.Color = a.Color
+	}
+	if names[AttributeStyle] {
+		result.Style = a.Style
+	}
+	if names[AttributeAssemblyRequired] {
+		result.AssemblyRequired = a.AssemblyRequired
+	}
+	return result
+}
+
+// Validate checks the attributes against a category schema; an empty schema allows
+// every attribute as optional
+func (a Attributes) Validate(schema []CategoryAttribute) error {
+	allowed := make(map[string]bool)
+	required := make(map[string]bool)
+	for _, attribute := range schema {
+		allowed[attribute.Attribute] = true
+		if attribute.Required {
+			required[attribute.Attribute] = true
+		}
+	}
+
+	values := a.Values()
+	for _, definition := range AttributeDefinitions {
+		value, ok := values[definition.Name]
+		if !ok {
+			if required[definition.Name] {
+				return &AttributeError{fmt.Sprintf("%s is required for this category", definition.Name)}
+			}
+			continue
+		}
+
+		if len(schema) > 0 && !allowed[definition.Name] {
+			return &AttributeError{fmt.Sprintf("%s is not applicable to this category", definition.Name)}
+		}
+
+		switch definition.Type {
+		case AttributeTypeNumber:
+			number := value.(float64)
+			if number < definition.Min || number > definition.Max {
+				return &AttributeError{fmt.Sprintf("%s must be between %g and %g", definition.Name, definition.Min, definition.Max)}
+			}
+		case AttributeTypeEnum:
+			if !containsValue(definition.Values, value.(string)) {
+				return &AttributeError{fmt.Sprintf("%s has an invalid value", definition.Name)}
+			}
+		}
+	}
+
+	return nil
+}
+
+// SchemaDefinitions returns the definitions of the attributes in a category schema;
+// an empty schema allows every attribute
+func SchemaDefinitions(schema []CategoryAttribute) []AttributeDefinition {
+	if len(schema) == 0 {
+		return AttributeDefinitions
+	}
+
+	required := make(map[string]bool)
+	for _, attribute := range schema {
+		required[attribute.Attribute] = attribute.Required
+	}
+
+	definitions := []AttributeDefinition{}
+	for _, definition := range AttributeDefinitions {
+		if isRequired, ok := required[definition.Name]; ok {
+			definition.Required = isRequired
+			definitions = append(definitions, definition)
+		}
+	}
+	return definitions
+}
+
+// containsValue checks if a value is in the list
+func containsValue(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package model
+
+import "strings"
+
+// Listing conditions
+const (
+	ConditionNew  = "new"
+	ConditionGood = "good"
+	ConditionFair = "fair"
+	ConditionPoor = "poor"
+)
+
+// DefaultLanguage is the language of condition labels when none is requested
+const DefaultLanguage = "ru"
+
+// ConditionInfo describes a listing condition with its localized labels
+type ConditionInfo struct {
+	Value  string            `json:"value"`
+	Label  string            `json:"label"`
+	Labels map[string]string `json:"labels"`
+}
+
+// Conditions lists the listing conditions from best to worst
+var Conditions = []ConditionInfo{
+	{Value: ConditionNew, Labels: map[string]string{"ru": "Новое", "en": "New"}},
+	{Value: ConditionGood, Labels: map[string]string{"ru": "Хорошее", "en": "Good"}},
+	{Value: ConditionFair, Labels: map[string]string{"ru": "Среднее", "en": "Fair"}},
+	{Value: ConditionPoor, Labels: map[string]string{"ru": "Плохое", "en": "Poor"}},
+}
+
+// conditionAliases maps other known spellings and common synonyms to the canonical
+// conditions; keep in sync with migrations/add_listing_conditions.sql
+var conditionAliases = map[string]string{
+	"новое":              ConditionNew,
+	"новый":              ConditionNew,
+	"новая":              ConditionNew,
+	"brand new":          ConditionNew,
+	"новое в упаковке":   ConditionNew,
+	"хорошее":            ConditionGood,
+	"хороший":            ConditionGood,
+	"хорошая":            ConditionGood,
+	"excellent":          ConditionGood,
+	"отличное":           ConditionGood,
+	"отличный":           ConditionGood,
+	"отличная":           ConditionGood,
+	"like new":           ConditionGood,
+	"как новое":          ConditionGood,
+	"как новый":          ConditionGood,
+	"как новая":          ConditionGood,
+	"среднее":            ConditionFair,
+	"средний":            ConditionFair,
+	"средняя":            ConditionFair,
+	"used":               ConditionFair,
+	"б/у":                ConditionFair,
+	"бу":                 ConditionFair,
+	"удовлетворительное": ConditionFair,
+	"плохое":             ConditionPoor,
+	"плохой":             ConditionPoor,
+	"плохая":             ConditionPoor,
+	"needs repair":       ConditionPoor,
+	"требует ремонта":    ConditionPoor,
+}
+
+// NormalizeCondition returns the canonical condition for a value or one of its
+// known spellings, and false if the value is not a condition
+func NormalizeCondition(value string) (string, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for _, condition := range Conditions {
+		if condition.Value == value {
+			return value, true
+		}
+	}
+
+	condition, ok := conditionAliases[value]
+	return condition, ok
+}
+
+// LocalizedConditions returns the conditions with labels in the given language,
+// falling back to the default language
+func LocalizedConditions(language string) []ConditionInfo {
+	conditions := make([]ConditionInfo, len(Conditions))
+	for i, condition := range Conditions {
+		condition.Label = condition.Labels[language]
+		if condition.Label == "" {
+			condition.Label = condition.Labels[DefaultLanguage]
+		}
+		conditions[i] = condition
+	}
+	return conditions
+}
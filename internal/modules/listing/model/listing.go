@@ -1,6 +1,8 @@
 package model
 
 import (
+	"FurniSwap/pkg/geo"
+	"FurniSwap/pkg/utils"
 	"time"
 )
 
@@ -17,17 +19,93 @@ type Listing struct {
 	Status      string    `db:"status" json:"status"`
 	CreatedAt   time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+	Latitude    *float64  `db:"latitude" json:"latitude,omitempty"`
+	Longitude   *float64  `db:"longitude" json:"longitude,omitempty"`
 	Images      []Image   `json:"images,omitempty"`
 	UserName    string    `db:"user_name" json:"user_name,omitempty"`
+
+	// Structured furniture characteristics
+	Attributes
+
+	// Distance in kilometers from the requested location, only set when searching near a location
+	Distance *float64 `db:"distance" json:"distance_km,omitempty"`
+
+	// HTML-escaped text with search matches wrapped in <mark> tags, only set in search results
+	TitleHighlight       string `db:"title_highlight" json:"title_highlight,omitempty"`
+	DescriptionHighlight string `db:"description_highlight" json:"description_highlight,omitempty"`
 }
 
 // Image represents an image for a listing
 type Image struct {
-	ID        int       `db:"id" json:"id"`
-	ListingID int       `db:"listing_id" json:"listing_id"`
-	ImagePath string    `db:"image_path" json:"image_path"`
-	IsMain    bool      `db:"is_main" json:"is_main"`
-	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	ID            int       `db:"id" json:"id"`
+	ListingID     int       `db:"listing_id" json:"listing_id"`
+	ImagePath     string    `db:"image_path" json:"image_path"`
+	IsMain        bool      `db:"is_main" json:"is_main"`
+	Position      int       `db:"position" json:"position"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+	ThumbnailPath *string   `db:"thumbnail_path" json:"-"`
+	CardPath      *string   `db:"card_path" json:"-"`
+	URLs          ImageURLs `db:"-" json:"urls"`
+}
+
+// MaxImages is the maximum number of images of a listing
+const MaxImages = 10
+
+// ReorderImagesRequest represents the new order of all images of a listing; the first becomes the main image
+type ReorderImagesRequest struct {
+	ImageIDs []int `json:"image_ids" binding:"required,min=1"`
+}
+
+// ImageURLs represents the URLs of the renditions of an image
+type ImageURLs struct {
+	Thumbnail string `json:"thumbnail"`
+	Card      string `json:"card"`
+	Full      string `json:"full"`
+}
+
+// Image rendition names
+const (
+	RenditionThumbnail = "thumbnail"
+	RenditionCard      = "card"
+	RenditionFull      = "full"
+)
+
+// ImageRenditions are the sizes listing images are stored in, from largest to smallest
+var ImageRenditions = []utils.Rendition{
+	{Name: RenditionFull, MaxSize: 1600},
+	{Name: RenditionCard, MaxSize: 600},
+	{Name: RenditionThumbnail, MaxSize: 200},
+}
+
+// FilePaths returns the paths of the stored files of the image; external URLs have none
+func (i Image) FilePaths() map[string]string {
+	paths := make(map[string]string)
+	if utils.IsExternalURL(i.ImagePath) {
+		return paths
+	}
+	paths[RenditionFull] = i.ImagePath
+	if i.CardPath != nil {
+		paths[RenditionCard] = *i.CardPath
+	}
+	if i.ThumbnailPath != nil {
+		paths[RenditionThumbnail] = *i.ThumbnailPath
+	}
+	return paths
+}
+
+// SetImageURLs fills the rendition URLs of the images; images without renditions,
+// such as external URLs, use the original image for every size
+func SetImageURLs(images []Image) {
+	for i := range images {
+		full := utils.FileURL(images[i].ImagePath)
+		images[i].URLs = ImageURLs{Thumbnail: full, Card: full, Full: full}
+		if images[i].CardPath != nil {
+			images[i].URLs.Card = utils.FileURL(*images[i].CardPath)
+		}
+		if images[i].ThumbnailPath != nil {
+			images[i].URLs.Thumbnail = utils.FileURL(*images[i].ThumbnailPath)
+		}
+	}
 }
 
 // Category represents a furniture category
@@ -41,20 +119,31 @@ type CreateListingRequest struct {
 	Title       string  `json:"title" binding:"required"`
 	Description string  `json:"description" binding:"required"`
 	Price       float64 `json:"price" binding:"required,min=0"`
-	Condition   string  `json:"condition" binding:"required"`
+	Condition   string  `json:"condition" binding:"required"` // One of Conditions or a known spelling of it
 	City        string  `json:"city" binding:"required"`
 	CategoryID  int     `json:"category_id" binding:"required"`
+
+	// Optional coordinates; when omitted they are taken from the city
+	Latitude  *float64 `json:"latitude" binding:"omitempty,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" binding:"omitempty,min=-180,max=180"`
+
+	// Attributes allowed by the category schema
+	Attributes
 }
 
 // UpdateListingRequest represents the data needed to update a listing
 type UpdateListingRequest struct {
-	Title       string  `json:"title"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price" binding:"min=0"`
-	Condition   string  `json:"condition"`
-	City        string  `json:"city"`
-	CategoryID  int     `json:"category_id"`
-	Status      string  `json:"status"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Price       float64  `json:"price" binding:"min=0"`
+	Condition   string   `json:"condition"`
+	City        string   `json:"city"`
+	CategoryID  int      `json:"category_id"`
+	Latitude    *float64 `json:"latitude" binding:"omitempty,min=-90,max=90"`
+	Longitude   *float64 `json:"longitude" binding:"omitempty,min=-180,max=180"`
+
+	// Attributes to change; the others keep their values
+	Attributes
 }
 
 // ListingFilter represents the filter criteria for listings
@@ -64,9 +153,19 @@ type ListingFilter struct {
 	Condition  string   `form:"condition"`
 	MinPrice   *float64 `form:"min_price"`
 	MaxPrice   *float64 `form:"max_price"`
-	SortBy     string   `form:"sort_by" binding:"omitempty,oneof=date price -date -price"`
+	Near       string   `form:"near"` // "lat,lon"
+	RadiusKm   *float64 `form:"radius_km" binding:"omitempty,gt=0,max=5000"`
+	SortBy     string   `form:"sort_by" binding:"omitempty,oneof=date price -date -price relevance distance"`
 	Page       int      `form:"page,default=1" binding:"min=1"`
 	Limit      int      `form:"limit,default=10" binding:"min=1,max=50"`
+	Facets     bool     `form:"facets"`
+	ViewerID   int      `form:"-"` // Authenticated user, whose blocked users' listings are hidden
+
+	// Furniture attribute filters
+	AttributeFilter
+
+	// Location parsed from Near, or the viewer's location; distances are measured from it
+	Location *geo.Location `form:"-"`
 }
 
 // ListingResponse represents a listing response with pagination
@@ -75,4 +174,34 @@ type ListingResponse struct {
 	TotalCount  int       `json:"total_count"`
 	CurrentPage int       `json:"current_page"`
 	TotalPages  int       `json:"total_pages"`
+	Facets      *Facets   `json:"facets,omitempty"`
+}
+
+// Facets represents listing counts per filter value. Each facet is counted under
+// all filters except its own, so every option shows how many listings selecting it gives.
+type Facets struct {
+	Categories []CategoryFacet `json:"categories"`
+	Cities     []FacetValue    `json:"cities"`
+	Conditions []FacetValue    `json:"conditions"`
+	Prices     []PriceBucket   `json:"prices"`
+}
+
+// CategoryFacet represents the number of listings in a category and its subcategories
+type CategoryFacet struct {
+	ID    int    `db:"id" json:"id"`
+	Name  string `db:"name" json:"name"`
+	Count int    `db:"count" json:"count"`
+}
+
+// FacetValue represents the number of listings with a field value
+type FacetValue struct {
+	Value string `db:"value" json:"value"`
+	Count int    `db:"count" json:"count"`
+}
+
+// PriceBucket represents the number of listings in a price range [Min, Max)
+type PriceBucket struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max,omitempty"`
+	Count int      `json:"count"`
 }
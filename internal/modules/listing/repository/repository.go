@@ -1,14 +1,19 @@
 package repository
 
 import (
+	categoryRepo "FurniSwap/internal/modules/category/repository"
 	"FurniSwap/internal/modules/listing/model"
+	"FurniSwap/pkg/geo"
 	"fmt"
+	"html"
 	"log"
 	"math"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // Repository handles database operations for the listing module
@@ -25,12 +30,27 @@ func NewRepository(db *sqlx.DB) *Repository {
 
 // CreateListing creates a new listing
 func (r *Repository) CreateListing(userID int, req model.CreateListingRequest) (int, error) {
+	// Use the given coordinates or fall back to the city's
+	latitude, longitude := req.Latitude, req.Longitude
+	if latitude == nil || longitude == nil {
+		location, err := geo.CityLocation(r.db, req.City)
+		if err != nil {
+			return 0, err
+		}
+		if location != nil {
+			latitude, longitude = &location.Latitude, &location.Longitude
+		}
+	}
+
 	var listingID int
 	err := r.db.QueryRow(`
-		INSERT INTO listings (user_id, title, description, price, condition, city, category_id, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO listings (user_id, title, description, price, condition, city, category_id, status, latitude, longitude,
+			width_cm, depth_cm, height_cm, weight_kg, material, color, style, assembly_required, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
 		RETURNING id
-	`, userID, req.Title, req.Description, req.Price, req.Condition, req.City, req.CategoryID, "active", time.Now(), time.Now()).Scan(&listingID)
+	`, userID, req.Title, req.Description, req.Price, req.Condition, req.City, req.CategoryID, "active", latitude, longitude,
+		req.WidthCm, req.DepthCm, req.HeightCm, req.WeightKg, req.Material, req.Color, req.Style, req.AssemblyRequired,
+		time.Now(), time.Now()).Scan(&listingID)
 
 	if err != nil {
 		log.Printf("Error creating listing: %v", err)
@@ -40,27 +60,32 @@ func (r *Repository) CreateListing(userID int, req model.CreateListingRequest) (
 	return listingID, nil
 }
 
-// UpdateListing updates an existing listing
-func (r *Repository) UpdateListing(listingID, userID int, req model.UpdateListingRequest) error {
-	// First check if the listing belongs to the user
-	var count int
-	err := r.db.Get(&count, "SELECT COUNT(*) FROM listings WHERE id = $1 AND user_id = $2", listingID, userID)
+// UpdateListing updates an existing listing, replacing its attributes with the given ones.
+// The status is left alone: it only changes through purchases, swaps and offers.
+func (r *Repository) UpdateListing(listingID, userID int, req model.UpdateListingRequest, attributes model.Attributes) error {
+	tx, err := r.db.Beginx()
 	if err != nil {
-		log.Printf("Error checking listing ownership: %v", err)
-		return fmt.Errorf("error checking listing ownership: %w", err)
-	}
-
-	if count == 0 {
-		return fmt.Errorf("listing not found or does not belong to the user")
+		log.Printf("Error beginning transaction: %v", err)
+		return fmt.Errorf("error beginning transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	// Get current listing data
-	var current model.Listing
-	err = r.db.Get(&current, "SELECT * FROM listings WHERE id = $1", listingID)
+	// Lock the listing so the edit doesn't interleave with a purchase or swap, and check it belongs to the user
+	var current []model.Listing
+	err = tx.Select(&current, "SELECT * FROM listings WHERE id = $1 AND user_id = $2 FOR UPDATE", listingID, userID)
 	if err != nil {
 		log.Printf("Error getting current listing data: %v", err)
 		return fmt.Errorf("error getting current listing data: %w", err)
 	}
+	if len(current) == 0 {
+		return fmt.Errorf("listing not found or does not belong to the user")
+	}
+	return r.updateListing(tx, &current[0], req, attributes)
+}
+
+// updateListing writes the provided fields of a locked listing
+func (r *Repository) updateListing(tx *sqlx.Tx, current *model.Listing, req model.UpdateListingRequest, attributes model.Attributes) error {
+	listingID := current.ID
 
 	// Update only provided fields
 	title := current.Title
@@ -93,23 +118,42 @@ func (r *Repository) UpdateListing(listingID, userID int, req model.UpdateListin
 		categoryID = req.CategoryID
 	}
 
-	status := current.Status
-	if req.Status != "" {
-		status = req.Status
+	// Use the given coordinates, or the new city's when the city changes
+	latitude, longitude := current.Latitude, current.Longitude
+	if req.Latitude != nil && req.Longitude != nil {
+		latitude, longitude = req.Latitude, req.Longitude
+	} else if city != current.City {
+		location, err := geo.CityLocation(r.db, city)
+		if err != nil {
+			return err
+		}
+		latitude, longitude = nil, nil
+		if location != nil {
+			latitude, longitude = &location.Latitude, &location.Longitude
+		}
 	}
 
 	// Update the listing
-	_, err = r.db.Exec(`
+	_, err := tx.Exec(`
 		UPDATE listings
-		SET title = $1, description = $2, price = $3, condition = $4, city = $5, category_id = $6, status = $7, updated_at = $8
-		WHERE id = $9
-	`, title, description, price, condition, city, categoryID, status, time.Now(), listingID)
+		SET title = $1, description = $2, price = $3, condition = $4, city = $5, category_id = $6,
+			latitude = $7, longitude = $8, width_cm = $9, depth_cm = $10, height_cm = $11, weight_kg = $12,
+			material = $13, color = $14, style = $15, assembly_required = $16, updated_at = $17
+		WHERE id = $18
+	`, title, description, price, condition, city, categoryID, latitude, longitude,
+		attributes.WidthCm, attributes.DepthCm, attributes.HeightCm, attributes.WeightKg,
+		attributes.Material, attributes.Color, attributes.Style, attributes.AssemblyRequired, time.Now(), listingID)
 
 	if err != nil {
 		log.Printf("Error updating listing: %v", err)
 		return fmt.Errorf("error updating listing: %w", err)
 	}
 
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
 	return nil
 }
 
@@ -154,11 +198,12 @@ func (r *Repository) GetListing(listingID int) (*model.Listing, error) {
 
 	// Get images for the listing
 	listing.Images = []model.Image{} // Initialize with empty slice to avoid null in JSON
-	err = r.db.Select(&listing.Images, "SELECT * FROM listing_images WHERE listing_id = $1 ORDER BY is_main DESC, created_at ASC", listingID)
+	err = r.db.Select(&listing.Images, "SELECT * FROM listing_images WHERE listing_id = $1 ORDER BY position, id", listingID)
 	if err != nil {
 		log.Printf("Error getting listing images: %v", err)
 		// Continue without images if there's an error
 	}
+	model.SetImageURLs(listing.Images)
 
 	return &listing, nil
 }
@@ -166,16 +211,17 @@ func (r *Repository) GetListing(listingID int) (*model.Listing, error) {
 // GetListings gets listings with filtering and pagination
 func (r *Repository) GetListings(filter model.ListingFilter) (*model.ListingResponse, error) {
 	// Build the query with filters
-	query := "SELECT l.*, COALESCE(u.name, '') as user_name FROM listings l LEFT JOIN users u ON l.user_id = u.id WHERE l.status = 'active'"
+	columns := "l.*, COALESCE(u.name, '') as user_name"
+	query := "FROM listings l LEFT JOIN users u ON l.user_id = u.id WHERE l.status = 'active'"
 	countQuery := "SELECT COUNT(*) FROM listings l WHERE l.status = 'active'"
 	var args []interface{}
 	var countArgs []interface{}
 	argIndex := 1
 
-	// Apply category filter
+	// Apply category filter, including subcategories
 	if filter.CategoryID != nil && *filter.CategoryID > 0 {
-		query += fmt.Sprintf(" AND l.category_id = $%d", argIndex)
-		countQuery += fmt.Sprintf(" AND l.category_id = $%d", argIndex)
+		query += categoryCondition(argIndex)
+		countQuery += categoryCondition(argIndex)
 		args = append(args, *filter.CategoryID)
 		countArgs = append(countArgs, *filter.CategoryID)
 		argIndex++
@@ -217,6 +263,43 @@ func (r *Repository) GetListings(filter model.ListingFilter) (*model.ListingResp
 		argIndex++
 	}
 
+	// Apply furniture attribute filters
+	if condition, attributeArgs := attributeConditions(filter.AttributeFilter, argIndex); condition != "" {
+		query += condition
+		countQuery += condition
+		args = append(args, attributeArgs...)
+		countArgs = append(countArgs, attributeArgs...)
+		argIndex += len(attributeArgs)
+	}
+
+	// Hide listings of users blocked by the viewer
+	if filter.ViewerID > 0 {
+		query += fmt.Sprintf(" AND l.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = $%d)", argIndex)
+		countQuery += fmt.Sprintf(" AND l.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = $%d)", argIndex)
+		args = append(args, filter.ViewerID)
+		countArgs = append(countArgs, filter.ViewerID)
+		argIndex++
+	}
+
+	// Apply location filter; it goes last as the count query only needs its parameters with a radius
+	if filter.Location != nil {
+		distance := geo.DistanceSQL("l.latitude", "l.longitude", argIndex, argIndex+1)
+		columns += ", " + distance + " as distance"
+		args = append(args, filter.Location.Latitude, filter.Location.Longitude)
+
+		argIndex += 2
+
+		if filter.RadiusKm != nil {
+			condition, radiusArgs := radiusFilter(distance, argIndex, filter.Location, *filter.RadiusKm)
+			query += condition
+			countQuery += condition
+			args = append(args, radiusArgs...)
+			countArgs = append(countArgs, filter.Location.Latitude, filter.Location.Longitude)
+			countArgs = append(countArgs, radiusArgs...)
+			argIndex += len(radiusArgs)
+		}
+	}
+
 	// Apply sorting
 	switch filter.SortBy {
 	case "date":
@@ -227,6 +310,8 @@ func (r *Repository) GetListings(filter model.ListingFilter) (*model.ListingResp
 		query += " ORDER BY l.price ASC"
 	case "-price":
 		query += " ORDER BY l.price DESC"
+	case "distance":
+		query += " ORDER BY distance ASC NULLS LAST, l.created_at DESC"
 	default:
 		query += " ORDER BY l.created_at DESC" // Default sort by newest
 	}
@@ -236,6 +321,7 @@ func (r *Repository) GetListings(filter model.ListingFilter) (*model.ListingResp
 	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argIndex, argIndex+1)
 	args = append(args, filter.Limit, offset)
 	argIndex += 2
+	query = "SELECT " + columns + " " + query
 
 	// Get total count
 	var totalCount int
@@ -259,99 +345,138 @@ func (r *Repository) GetListings(filter model.ListingFilter) (*model.ListingResp
 	// Get images for each listing
 	for i := range listings {
 		listings[i].Images = []model.Image{} // Initialize with empty slice to avoid null in JSON
-		err = r.db.Select(&listings[i].Images, "SELECT * FROM listing_images WHERE listing_id = $1 ORDER BY is_main DESC, created_at ASC", listings[i].ID)
+		err = r.db.Select(&listings[i].Images, "SELECT * FROM listing_images WHERE listing_id = $1 ORDER BY position, id", listings[i].ID)
 		if err != nil {
 			log.Printf("Error getting images for listing %d: %v", listings[i].ID, err)
 			// Continue without images if there's an error
 		}
+		model.SetImageURLs(listings[i].Images)
 	}
 
-	return &model.ListingResponse{
+	response := &model.ListingResponse{
 		Listings:    listings,
 		TotalCount:  totalCount,
 		CurrentPage: filter.Page,
 		TotalPages:  totalPages,
-	}, nil
+	}
+
+	// Get facet counts if requested
+	if filter.Facets {
+		if response.Facets, err = r.getFacets("", filter); err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
 }
 
-// AddImage adds an image to a listing
-func (r *Repository) AddImage(listingID int, imagePath string) (int, error) {
-	var imageID int
-	err := r.db.QueryRow(`
-		INSERT INTO listing_images (listing_id, image_path, is_main, created_at)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id
-	`, listingID, imagePath, false, time.Now()).Scan(&imageID)
+// AddImages adds images, given as the paths of their stored renditions, after the other
+// images of a listing in one transaction. The listing is locked so that concurrent uploads
+// can't exceed model.MaxImages or take the same positions.
+func (r *Repository) AddImages(listingID, userID int, images []map[string]string) ([]int, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		return nil, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
 
+	// Lock the listing so its images can't change while adding
+	var owned []int
+	err = tx.Select(&owned, "SELECT id FROM listings WHERE id = $1 AND user_id = $2 FOR UPDATE", listingID, userID)
 	if err != nil {
-		log.Printf("Error adding image: %v", err)
-		return 0, fmt.Errorf("error adding image: %w", err)
+		log.Printf("Error checking listing ownership: %v", err)
+		return nil, fmt.Errorf("error checking listing ownership: %w", err)
+	}
+	if len(owned) == 0 {
+		return nil, fmt.Errorf("listing does not belong to the user")
 	}
 
-	// If this is the first image, make it the main image
-	var count int
-	err = r.db.Get(&count, "SELECT COUNT(*) FROM listing_images WHERE listing_id = $1", listingID)
+	var current struct {
+		Count        int `db:"count"`
+		NextPosition int `db:"next_position"`
+	}
+	err = tx.Get(&current, `
+		SELECT COUNT(*) AS count, COALESCE(MAX(position) + 1, 0) AS next_position
+		FROM listing_images WHERE listing_id = $1
+	`, listingID)
 	if err != nil {
 		log.Printf("Error counting images: %v", err)
-		return imageID, nil // Return imageID anyway
+		return nil, fmt.Errorf("error counting images: %w", err)
+	}
+	if current.Count+len(images) > model.MaxImages {
+		return nil, fmt.Errorf("too many images")
 	}
 
-	if count == 1 {
-		_, err = r.db.Exec("UPDATE listing_images SET is_main = true WHERE id = $1", imageID)
+	imageIDs := make([]int, 0, len(images))
+	for i, paths := range images {
+		// The first image of a listing becomes its main image
+		var imageID int
+		err = tx.Get(&imageID, `
+			INSERT INTO listing_images (listing_id, image_path, thumbnail_path, card_path, is_main, position, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id
+		`, listingID, paths[model.RenditionFull], paths[model.RenditionThumbnail], paths[model.RenditionCard],
+			current.Count == 0 && i == 0, current.NextPosition+i, time.Now())
 		if err != nil {
-			log.Printf("Error setting main image: %v", err)
-			return imageID, nil // Return imageID anyway
+			log.Printf("Error adding image: %v", err)
+			return nil, fmt.Errorf("error adding image: %w", err)
 		}
+		imageIDs = append(imageIDs, imageID)
 	}
 
-	return imageID, nil
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return imageIDs, nil
 }
 
 // DeleteImage deletes an image from a listing
 func (r *Repository) DeleteImage(imageID, listingID, userID int) error {
-	// First check if the listing belongs to the user
-	var count int
-	err := r.db.Get(&count, `
-		SELECT COUNT(*) FROM listing_images li
-		JOIN listings l ON li.listing_id = l.id
-		WHERE li.id = $1 AND li.listing_id = $2 AND l.user_id = $3
-	`, imageID, listingID, userID)
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the listing so its images can't change while deleting
+	var owned []int
+	err = tx.Select(&owned, "SELECT id FROM listings WHERE id = $1 AND user_id = $2 FOR UPDATE", listingID, userID)
 	if err != nil {
 		log.Printf("Error checking image ownership: %v", err)
 		return fmt.Errorf("error checking image ownership: %w", err)
 	}
-
-	if count == 0 {
+	if len(owned) == 0 {
 		return fmt.Errorf("image not found or does not belong to the user's listing")
 	}
 
-	// Get image path for deletion
-	var imagePath string
-	var isMain bool
-	err = r.db.QueryRow("SELECT image_path, is_main FROM listing_images WHERE id = $1", imageID).Scan(&imagePath, &isMain)
+	// Delete the image
+	result, err := tx.Exec("DELETE FROM listing_images WHERE id = $1 AND listing_id = $2", imageID, listingID)
 	if err != nil {
-		log.Printf("Error getting image path: %v", err)
-		return fmt.Errorf("error getting image path: %w", err)
+		log.Printf("Error deleting image: %v", err)
+		return fmt.Errorf("error deleting image: %w", err)
 	}
-
-	// Delete the image
-	_, err = r.db.Exec("DELETE FROM listing_images WHERE id = $1", imageID)
+	deleted, err := result.RowsAffected()
 	if err != nil {
 		log.Printf("Error deleting image: %v", err)
 		return fmt.Errorf("error deleting image: %w", err)
 	}
+	if deleted == 0 {
+		return fmt.Errorf("image not found or does not belong to the user's listing")
+	}
 
-	// If the deleted image was the main image, set another image as main
-	if isMain {
-		var newMainImageID int
-		err = r.db.QueryRow("SELECT id FROM listing_images WHERE listing_id = $1 ORDER BY created_at ASC LIMIT 1", listingID).Scan(&newMainImageID)
-		if err == nil {
-			_, err = r.db.Exec("UPDATE listing_images SET is_main = true WHERE id = $1", newMainImageID)
-			if err != nil {
-				log.Printf("Error setting new main image: %v", err)
-				// Not a critical error, continue
-			}
-		}
+	// Close the gap in positions; if the main image was deleted, the next one becomes main
+	if err = renumberImages(tx, listingID, 0); err != nil {
+		log.Printf("Error renumbering images: %v", err)
+		return fmt.Errorf("error renumbering images: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return fmt.Errorf("error committing transaction: %w", err)
 	}
 
 	return nil
@@ -388,17 +513,8 @@ func (r *Repository) SetMainImage(imageID, listingID, userID int) error {
 		}
 	}()
 
-	// Reset all images for this listing to not be main
-	_, err = tx.Exec("UPDATE listing_images SET is_main = false WHERE listing_id = $1", listingID)
-	if err != nil {
-		tx.Rollback()
-		log.Printf("Error resetting main images: %v", err)
-		return fmt.Errorf("error resetting main images: %w", err)
-	}
-
-	// Set the selected image as main
-	_, err = tx.Exec("UPDATE listing_images SET is_main = true WHERE id = $1", imageID)
-	if err != nil {
+	// Move the selected image to the front, which makes it the main image
+	if err = renumberImages(tx, listingID, imageID); err != nil {
 		tx.Rollback()
 		log.Printf("Error setting main image: %v", err)
 		return fmt.Errorf("error setting main image: %w", err)
@@ -414,6 +530,159 @@ func (r *Repository) SetMainImage(imageID, listingID, userID int) error {
 	return nil
 }
 
+// ReorderImages sets the order of the images of a listing; imageIDs must contain every image
+// of the listing exactly once, and the first one becomes the main image
+func (r *Repository) ReorderImages(listingID, userID int, imageIDs []int) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the listing so its images can't change while reordering
+	var owned []int
+	err = tx.Select(&owned, "SELECT id FROM listings WHERE id = $1 AND user_id = $2 FOR UPDATE", listingID, userID)
+	if err != nil {
+		log.Printf("Error checking listing ownership: %v", err)
+		return fmt.Errorf("error checking listing ownership: %w", err)
+	}
+	if len(owned) == 0 {
+		return fmt.Errorf("listing not found or does not belong to the user")
+	}
+
+	var currentIDs []int
+	err = tx.Select(&currentIDs, "SELECT id FROM listing_images WHERE listing_id = $1", listingID)
+	if err != nil {
+		log.Printf("Error getting listing images: %v", err)
+		return fmt.Errorf("error getting listing images: %w", err)
+	}
+	if !sameImageSet(currentIDs, imageIDs) {
+		return fmt.Errorf("image order must list every image of the listing once")
+	}
+
+	_, err = tx.Exec(`
+		UPDATE listing_images li
+		SET position = o.ord - 1, is_main = (o.ord = 1)
+		FROM unnest($1::int[]) WITH ORDINALITY AS o(id, ord)
+		WHERE li.id = o.id AND li.listing_id = $2
+	`, pq.Array(imageIDs), listingID)
+	if err != nil {
+		log.Printf("Error reordering images: %v", err)
+		return fmt.Errorf("error reordering images: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetURLImages gets the listing images that are still external URLs
+func (r *Repository) GetURLImages() ([]model.Image, error) {
+	images := []model.Image{}
+	err := r.db.Select(&images, `
+		SELECT * FROM listing_images
+		WHERE image_path LIKE 'http://%' OR image_path LIKE 'https://%'
+		ORDER BY id
+	`)
+	if err != nil {
+		log.Printf("Error getting URL images: %v", err)
+		return nil, fmt.Errorf("error getting URL images: %w", err)
+	}
+	return images, nil
+}
+
+// ReplaceURLImage replaces the URL of a listing image with stored renditions, unless the
+// image has been deleted in the meantime; it reports whether the image was replaced
+func (r *Repository) ReplaceURLImage(imageID int, url string, paths map[string]string) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE listing_images SET image_path = $1, thumbnail_path = $2, card_path = $3
+		WHERE id = $4 AND image_path = $5
+	`, paths[model.RenditionFull], paths[model.RenditionThumbnail], paths[model.RenditionCard], imageID, url)
+	if err != nil {
+		log.Printf("Error replacing URL image: %v", err)
+		return false, fmt.Errorf("error replacing URL image: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error replacing URL image: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// DeleteURLImage deletes a listing image that is still the given external URL and
+// renumbers the remaining images of the listing
+func (r *Repository) DeleteURLImage(imageID, listingID int, url string) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the listing so its images can't change while deleting
+	if _, err = tx.Exec("SELECT id FROM listings WHERE id = $1 FOR UPDATE", listingID); err != nil {
+		log.Printf("Error locking listing: %v", err)
+		return fmt.Errorf("error locking listing: %w", err)
+	}
+
+	_, err = tx.Exec("DELETE FROM listing_images WHERE id = $1 AND listing_id = $2 AND image_path = $3", imageID, listingID, url)
+	if err != nil {
+		log.Printf("Error deleting image: %v", err)
+		return fmt.Errorf("error deleting image: %w", err)
+	}
+
+	if err = renumberImages(tx, listingID, 0); err != nil {
+		log.Printf("Error renumbering images: %v", err)
+		return fmt.Errorf("error renumbering images: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// renumberImages numbers the positions of the images of a listing from 0 without gaps and
+// makes the first one main; the image with firstImageID, if not 0, is moved to the front
+func renumberImages(db sqlx.Execer, listingID, firstImageID int) error {
+	_, err := db.Exec(`
+		UPDATE listing_images li
+		SET position = o.rn - 1, is_main = (o.rn = 1)
+		FROM (
+			SELECT id, ROW_NUMBER() OVER (ORDER BY id = $2 DESC, position, id) AS rn
+			FROM listing_images
+			WHERE listing_id = $1
+		) o
+		WHERE li.id = o.id
+	`, listingID, firstImageID)
+	return err
+}
+
+// sameImageSet checks if the ordered image IDs are exactly the current ones, without duplicates
+func sameImageSet(currentIDs, orderedIDs []int) bool {
+	if len(currentIDs) != len(orderedIDs) {
+		return false
+	}
+
+	remaining := make(map[int]bool, len(currentIDs))
+	for _, id := range currentIDs {
+		remaining[id] = true
+	}
+	for _, id := range orderedIDs {
+		if !remaining[id] {
+			return false
+		}
+		delete(remaining, id)
+	}
+	return true
+}
+
 // GetUserListings gets all listings for a user
 func (r *Repository) GetUserListings(userID int) ([]model.Listing, error) {
 	var listings []model.Listing
@@ -432,54 +701,94 @@ func (r *Repository) GetUserListings(userID int) ([]model.Listing, error) {
 	// Get images for each listing
 	for i := range listings {
 		listings[i].Images = []model.Image{} // Initialize with empty slice to avoid null in JSON
-		err = r.db.Select(&listings[i].Images, "SELECT * FROM listing_images WHERE listing_id = $1 ORDER BY is_main DESC, created_at ASC", listings[i].ID)
+		err = r.db.Select(&listings[i].Images, "SELECT * FROM listing_images WHERE listing_id = $1 ORDER BY position, id", listings[i].ID)
 		if err != nil {
 			log.Printf("Error getting images for listing %d: %v", listings[i].ID, err)
 			// Continue without images if there's an error
 		}
+		model.SetImageURLs(listings[i].Images)
 	}
 
 	return listings, nil
 }
 
-// SearchListings searches for listings by keyword in title or description
-func (r *Repository) SearchListings(keyword string, filter model.ListingFilter) (*model.ListingResponse, error) {
-	// Split the keyword into words for better search
-	words := strings.Fields(keyword)
-	if len(words) == 0 {
-		return r.GetListings(filter) // If no search term, return regular listings
+// GetUserLocation gets the saved location of a user, or nil if the user has none
+func (r *Repository) GetUserLocation(userID int) (*geo.Location, error) {
+	var location struct {
+		Latitude  *float64 `db:"latitude"`
+		Longitude *float64 `db:"longitude"`
+	}
+	err := r.db.Get(&location, "SELECT latitude, longitude FROM users WHERE id = $1", userID)
+	if err != nil {
+		log.Printf("Error getting user location: %v", err)
+		return nil, fmt.Errorf("error getting user location: %w", err)
 	}
 
-	// Build the query with filters
-	query := "SELECT l.*, COALESCE(u.name, '') as user_name FROM listings l LEFT JOIN users u ON l.user_id = u.id WHERE l.status = 'active' OR l.status = 'active'"
-	countQuery := "SELECT COUNT(*) FROM listings l WHERE l.status = 'active'"
-	var args []interface{}
-	var countArgs []interface{}
-	argIndex := 1
-
-	// Add title and description search conditions
-	titleConditions := make([]string, len(words))
-	descConditions := make([]string, len(words))
+	if location.Latitude == nil || location.Longitude == nil {
+		return nil, nil
+	}
 
-	for i, word := range words {
-		titleConditions[i] = fmt.Sprintf("l.title ILIKE $%d", argIndex)
-		args = append(args, "%"+word+"%")
-		countArgs = append(countArgs, "%"+word+"%")
-		argIndex++
+	return &geo.Location{Latitude: *location.Latitude, Longitude: *location.Longitude}, nil
+}
 
-		descConditions[i] = fmt.Sprintf("l.description ILIKE $%d", argIndex)
-		args = append(args, "%"+word+"%")
-		countArgs = append(countArgs, "%"+word+"%")
-		argIndex++
+// GetCategoryAttributes gets the attribute schema of a category; subcategories
+// without their own schema use the schema of the nearest ancestor that has one
+func (r *Repository) GetCategoryAttributes(categoryID int) ([]model.CategoryAttribute, error) {
+	attributes := []model.CategoryAttribute{}
+	err := r.db.Select(&attributes, `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, 0 AS depth FROM categories WHERE id = $1
+			UNION ALL
+			SELECT c.id, c.parent_id, a.depth + 1 FROM categories c JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT ca.attribute, ca.required
+		FROM category_attributes ca
+		JOIN ancestors a ON ca.category_id = a.id
+		WHERE a.depth = (
+			SELECT MIN(a2.depth) FROM ancestors a2
+			JOIN category_attributes ca2 ON ca2.category_id = a2.id
+		)
+	`, categoryID)
+	if err != nil {
+		log.Printf("Error getting category attributes: %v", err)
+		return nil, fmt.Errorf("error getting category attributes: %w", err)
 	}
 
-	query += " AND (" + strings.Join(titleConditions, " OR ") + " OR " + strings.Join(descConditions, " OR ") + ")"
-	countQuery += " AND (" + strings.Join(titleConditions, " OR ") + " OR " + strings.Join(descConditions, " OR ") + ")"
+	return attributes, nil
+}
+
+// SearchVector is the full-text search document of a listing "l" (title weighted
+// above description); it must match the expression of the listings_search_idx index
+const SearchVector = "(setweight(to_tsvector('russian', COALESCE(l.title, '')), 'A') || " +
+	"setweight(to_tsvector('russian', COALESCE(l.description, '')), 'B'))"
+
+// searchQuery parses the search text (parameter $1) into a full-text query
+const searchQuery = "websearch_to_tsquery('russian', $1)"
 
-	// Apply category filter
+// SearchListings searches for listings by keyword in title or description using
+// full-text search with Russian morphology and highlights the matches
+func (r *Repository) SearchListings(keyword string, filter model.ListingFilter) (*model.ListingResponse, error) {
+	if strings.TrimSpace(keyword) == "" {
+		return r.GetListings(filter) // If no search term, return regular listings
+	}
+
+	// Build the query with filters; matches are delimited with markers unique to this
+	// query, so the user text can be escaped before they are turned into <mark> tags
+	startSel, stopSel := highlightMarkers()
+	columns := "l.*, COALESCE(u.name, '') as user_name, " +
+		"ts_headline('russian', l.title, " + searchQuery + ", 'HighlightAll=true, StartSel=" + startSel + ", StopSel=" + stopSel + "') as title_highlight, " +
+		"ts_headline('russian', l.description, " + searchQuery + ", 'MaxWords=35, MinWords=15, MaxFragments=2, StartSel=" + startSel + ", StopSel=" + stopSel + "') as description_highlight"
+	query := "FROM listings l LEFT JOIN users u ON l.user_id = u.id " +
+		"WHERE l.status = 'active' AND " + SearchVector + " @@ " + searchQuery
+	countQuery := "SELECT COUNT(*) FROM listings l WHERE l.status = 'active' AND " + SearchVector + " @@ " + searchQuery
+	args := []interface{}{keyword}
+	countArgs := []interface{}{keyword}
+	argIndex := 2
+
+	// Apply category filter, including subcategories
 	if filter.CategoryID != nil && *filter.CategoryID > 0 {
-		query += fmt.Sprintf(" AND l.category_id = $%d", argIndex)
-		countQuery += fmt.Sprintf(" AND l.category_id = $%d", argIndex)
+		query += categoryCondition(argIndex)
+		countQuery += categoryCondition(argIndex)
 		args = append(args, *filter.CategoryID)
 		countArgs = append(countArgs, *filter.CategoryID)
 		argIndex++
@@ -521,6 +830,43 @@ func (r *Repository) SearchListings(keyword string, filter model.ListingFilter)
 		argIndex++
 	}
 
+	// Apply furniture attribute filters
+	if condition, attributeArgs := attributeConditions(filter.AttributeFilter, argIndex); condition != "" {
+		query += condition
+		countQuery += condition
+		args = append(args, attributeArgs...)
+		countArgs = append(countArgs, attributeArgs...)
+		argIndex += len(attributeArgs)
+	}
+
+	// Hide listings of users blocked by the viewer
+	if filter.ViewerID > 0 {
+		query += fmt.Sprintf(" AND l.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = $%d)", argIndex)
+		countQuery += fmt.Sprintf(" AND l.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = $%d)", argIndex)
+		args = append(args, filter.ViewerID)
+		countArgs = append(countArgs, filter.ViewerID)
+		argIndex++
+	}
+
+	// Apply location filter; it goes last as the count query only needs its parameters with a radius
+	if filter.Location != nil {
+		distance := geo.DistanceSQL("l.latitude", "l.longitude", argIndex, argIndex+1)
+		columns += ", " + distance + " as distance"
+		args = append(args, filter.Location.Latitude, filter.Location.Longitude)
+
+		argIndex += 2
+
+		if filter.RadiusKm != nil {
+			condition, radiusArgs := radiusFilter(distance, argIndex, filter.Location, *filter.RadiusKm)
+			query += condition
+			countQuery += condition
+			args = append(args, radiusArgs...)
+			countArgs = append(countArgs, filter.Location.Latitude, filter.Location.Longitude)
+			countArgs = append(countArgs, radiusArgs...)
+			argIndex += len(radiusArgs)
+		}
+	}
+
 	// Apply sorting
 	switch filter.SortBy {
 	case "date":
@@ -531,8 +877,10 @@ func (r *Repository) SearchListings(keyword string, filter model.ListingFilter)
 		query += " ORDER BY l.price ASC"
 	case "-price":
 		query += " ORDER BY l.price DESC"
+	case "distance":
+		query += " ORDER BY distance ASC NULLS LAST, l.created_at DESC"
 	default:
-		query += " ORDER BY l.created_at DESC" // Default sort by newest
+		query += " ORDER BY ts_rank_cd(" + SearchVector + ", " + searchQuery + ") DESC, l.created_at DESC" // Default sort by relevance
 	}
 
 	// Apply pagination
@@ -540,6 +888,7 @@ func (r *Repository) SearchListings(keyword string, filter model.ListingFilter)
 	query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argIndex, argIndex+1)
 	args = append(args, filter.Limit, offset)
 	argIndex += 2
+	query = "SELECT " + columns + " " + query
 
 	// Get total count
 	var totalCount int
@@ -562,18 +911,278 @@ func (r *Repository) SearchListings(keyword string, filter model.ListingFilter)
 
 	// Get images for each listing
 	for i := range listings {
+		listings[i].TitleHighlight = highlightHTML(listings[i].TitleHighlight, startSel, stopSel)
+		listings[i].DescriptionHighlight = highlightHTML(listings[i].DescriptionHighlight, startSel, stopSel)
+
 		listings[i].Images = []model.Image{} // Initialize with empty slice to avoid null in JSON
-		err = r.db.Select(&listings[i].Images, "SELECT * FROM listing_images WHERE listing_id = $1 ORDER BY is_main DESC, created_at ASC", listings[i].ID)
+		err = r.db.Select(&listings[i].Images, "SELECT * FROM listing_images WHERE listing_id = $1 ORDER BY position, id", listings[i].ID)
 		if err != nil {
 			log.Printf("Error getting images for listing %d: %v", listings[i].ID, err)
 			// Continue without images if there's an error
 		}
+		model.SetImageURLs(listings[i].Images)
 	}
 
-	return &model.ListingResponse{
+	response := &model.ListingResponse{
 		Listings:    listings,
 		TotalCount:  totalCount,
 		CurrentPage: filter.Page,
 		TotalPages:  totalPages,
-	}, nil
+	}
+
+	// Get facet counts if requested
+	if filter.Facets {
+		if response.Facets, err = r.getFacets(keyword, filter); err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// highlightMarkers returns random start and stop markers for search matches; they are
+// alphanumeric, so they are safe in ts_headline options and unchanged by HTML escaping
+func highlightMarkers() (string, string) {
+	id := strings.ReplaceAll(uuid.New().String(), "-", "")
+	return "hlstart" + id, "hlstop" + id
+}
+
+// highlightHTML escapes a ts_headline result and turns its match markers into <mark> tags
+func highlightHTML(headline, startSel, stopSel string) string {
+	escaped := html.EscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, startSel, "<mark>")
+	return strings.ReplaceAll(escaped, stopSel, "</mark>")
+}
+
+// categoryCondition limits listings to the category given by parameter argIndex and its subcategories
+func categoryCondition(argIndex int) string {
+	return " AND l.category_id IN " + categoryRepo.DescendantsQuery(fmt.Sprintf("$%d", argIndex))
+}
+
+// attributeConditions builds the conditions of the furniture attribute filters,
+// numbering parameters from argIndex
+func attributeConditions(filter model.AttributeFilter, argIndex int) (string, []interface{}) {
+	var condition string
+	var args []interface{}
+
+	ranges := []struct {
+		column string
+		min    *float64
+		max    *float64
+	}{
+		{model.AttributeWidth, filter.MinWidth, filter.MaxWidth},
+		{model.AttributeDepth, filter.MinDepth, filter.MaxDepth},
+		{model.AttributeHeight, filter.MinHeight, filter.MaxHeight},
+		{model.AttributeWeight, filter.MinWeight, filter.MaxWeight},
+	}
+	for _, r := range ranges {
+		if r.min != nil {
+			condition += fmt.Sprintf(" AND l.%s >= $%d", r.column, argIndex+len(args))
+			args = append(args, *r.min)
+		}
+		if r.max != nil {
+			condition += fmt.Sprintf(" AND l.%s <= $%d", r.column, argIndex+len(args))
+			args = append(args, *r.max)
+		}
+	}
+
+	enums := []struct {
+		column string
+		values string
+	}{
+		{model.AttributeMaterial, filter.Material},
+		{model.AttributeColor, filter.Color},
+		{model.AttributeStyle, filter.Style},
+	}
+	for _, e := range enums {
+		if e.values == "" {
+			continue
+		}
+		values := strings.Split(e.values, ",")
+		for i := range values {
+			values[i] = strings.TrimSpace(values[i])
+		}
+		condition += fmt.Sprintf(" AND l.%s = ANY($%d)", e.column, argIndex+len(args))
+		args = append(args, pq.Array(values))
+	}
+
+	if filter.AssemblyRequired != nil {
+		condition += fmt.Sprintf(" AND l.assembly_required = $%d", argIndex+len(args))
+		args = append(args, *filter.AssemblyRequired)
+	}
+
+	return condition, args
+}
+
+// radiusFilter builds the condition limiting the distance to the radius, starting
+// at parameter argIndex. The latitude range lets the location index narrow the rows
+// before distances are computed.
+func radiusFilter(distance string, argIndex int, location *geo.Location, radiusKm float64) (string, []interface{}) {
+	latitudeDelta := radiusKm / geo.KmPerDegreeLatitude
+	condition := fmt.Sprintf(" AND l.latitude BETWEEN $%d AND $%d AND %s <= $%d", argIndex, argIndex+1, distance, argIndex+2)
+	return condition, []interface{}{location.Latitude - latitudeDelta, location.Latitude + latitudeDelta, radiusKm}
+}
+
+// priceBucketBounds are the lower bounds of the price facet ranges
+var priceBucketBounds = []float64{0, 5000, 10000, 20000, 50000, 100000}
+
+// Facet names used to leave a facet's own filter out of its counts
+const (
+	facetCategory  = "category"
+	facetCity      = "city"
+	facetCondition = "condition"
+	facetPrice     = "price"
+)
+
+// facetConditions builds the WHERE clause for active listings matching the
+// keyword and filter, leaving out the filter of the excluded facet
+func facetConditions(keyword string, filter model.ListingFilter, exclude string) (string, []interface{}) {
+	where := "l.status = 'active'"
+	var args []interface{}
+
+	// The keyword always goes first, as searchQuery refers to it as $1
+	if keyword != "" {
+		where += " AND " + SearchVector + " @@ " + searchQuery
+		args = append(args, keyword)
+	}
+
+	if exclude != facetCategory && filter.CategoryID != nil && *filter.CategoryID > 0 {
+		args = append(args, *filter.CategoryID)
+		where += categoryCondition(len(args))
+	}
+
+	if exclude != facetCity && filter.City != "" {
+		args = append(args, "%"+filter.City+"%")
+		where += fmt.Sprintf(" AND l.city ILIKE $%d", len(args))
+	}
+
+	if exclude != facetCondition && filter.Condition != "" {
+		args = append(args, filter.Condition)
+		where += fmt.Sprintf(" AND l.condition = $%d", len(args))
+	}
+
+	if exclude != facetPrice && filter.MinPrice != nil && *filter.MinPrice >= 0 {
+		args = append(args, *filter.MinPrice)
+		where += fmt.Sprintf(" AND l.price >= $%d", len(args))
+	}
+
+	if exclude != facetPrice && filter.MaxPrice != nil && *filter.MaxPrice > 0 {
+		args = append(args, *filter.MaxPrice)
+		where += fmt.Sprintf(" AND l.price <= $%d", len(args))
+	}
+
+	if condition, attributeArgs := attributeConditions(filter.AttributeFilter, len(args)+1); condition != "" {
+		where += condition
+		args = append(args, attributeArgs...)
+	}
+
+	if filter.ViewerID > 0 {
+		args = append(args, filter.ViewerID)
+		where += fmt.Sprintf(" AND l.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = $%d)", len(args))
+	}
+
+	if filter.Location != nil && filter.RadiusKm != nil {
+		args = append(args, filter.Location.Latitude, filter.Location.Longitude)
+		distance := geo.DistanceSQL("l.latitude", "l.longitude", len(args)-1, len(args))
+		condition, radiusArgs := radiusFilter(distance, len(args)+1, filter.Location, *filter.RadiusKm)
+		where += condition
+		args = append(args, radiusArgs...)
+	}
+
+	return where, args
+}
+
+// getFacets counts listings per category, city, condition and price range
+func (r *Repository) getFacets(keyword string, filter model.ListingFilter) (*model.Facets, error) {
+	facets := &model.Facets{
+		Categories: []model.CategoryFacet{},
+		Cities:     []model.FacetValue{},
+		Conditions: []model.FacetValue{},
+	}
+
+	// Categories; a listing counts towards its category and all of its ancestors, matching
+	// the category filter, which includes subcategories
+	where, args := facetConditions(keyword, filter, facetCategory)
+	err := r.db.Select(&facets.Categories, `
+		WITH RECURSIVE category_tree AS (
+			SELECT id, id AS root_id FROM categories
+			UNION ALL
+			SELECT c.id, t.root_id FROM categories c JOIN category_tree t ON c.parent_id = t.id
+		)
+		SELECT c.id, c.name, COUNT(*) as count
+		FROM listings l
+		JOIN category_tree t ON l.category_id = t.id
+		JOIN categories c ON t.root_id = c.id
+		WHERE `+where+`
+		GROUP BY c.id, c.name
+		ORDER BY count DESC, c.name
+	`, args...)
+	if err != nil {
+		log.Printf("Error getting category facets: %v", err)
+		return nil, fmt.Errorf("error getting category facets: %w", err)
+	}
+
+	// Cities
+	where, args = facetConditions(keyword, filter, facetCity)
+	err = r.db.Select(&facets.Cities, `
+		SELECT l.city as value, COUNT(*) as count
+		FROM listings l
+		WHERE `+where+`
+		GROUP BY l.city
+		ORDER BY count DESC, l.city
+		LIMIT 20
+	`, args...)
+	if err != nil {
+		log.Printf("Error getting city facets: %v", err)
+		return nil, fmt.Errorf("error getting city facets: %w", err)
+	}
+
+	// Conditions
+	where, args = facetConditions(keyword, filter, facetCondition)
+	err = r.db.Select(&facets.Conditions, `
+		SELECT l.condition as value, COUNT(*) as count
+		FROM listings l
+		WHERE `+where+`
+		GROUP BY l.condition
+		ORDER BY count DESC, l.condition
+	`, args...)
+	if err != nil {
+		log.Printf("Error getting condition facets: %v", err)
+		return nil, fmt.Errorf("error getting condition facets: %w", err)
+	}
+
+	// Price ranges; width_bucket returns the index of the range in priceBucketBounds
+	where, args = facetConditions(keyword, filter, facetPrice)
+	args = append(args, pq.Array(priceBucketBounds[1:]))
+	var buckets []struct {
+		Bucket int `db:"bucket"`
+		Count  int `db:"count"`
+	}
+	boundsParam := fmt.Sprintf("$%d", len(args))
+	err = r.db.Select(&buckets, `
+		SELECT width_bucket(l.price::float8, `+boundsParam+`::float8[]) as bucket, COUNT(*) as count
+		FROM listings l
+		WHERE `+where+`
+		GROUP BY bucket
+	`, args...)
+	if err != nil {
+		log.Printf("Error getting price facets: %v", err)
+		return nil, fmt.Errorf("error getting price facets: %w", err)
+	}
+
+	facets.Prices = make([]model.PriceBucket, len(priceBucketBounds))
+	for i, lower := range priceBucketBounds {
+		facets.Prices[i].Min = lower
+		if i+1 < len(priceBucketBounds) {
+			upper := priceBucketBounds[i+1]
+			facets.Prices[i].Max = &upper
+		}
+	}
+	for _, bucket := range buckets {
+		if bucket.Bucket >= 0 && bucket.Bucket < len(facets.Prices) {
+			facets.Prices[bucket.Bucket].Count = bucket.Count
+		}
+	}
+
+	return facets, nil
 }
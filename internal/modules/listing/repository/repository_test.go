@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHighlightHTMLEscapesUserText(t *testing.T) {
+	startSel, stopSel := highlightMarkers()
+	headline := "<script>alert(1)</script> " + startSel + "диван" + stopSel + " <img src=x onerror=alert(1)>"
+
+	got := highlightHTML(headline, startSel, stopSel)
+
+	want := "&lt;script&gt;alert(1)&lt;/script&gt; <mark>диван</mark> &lt;img src=x onerror=alert(1)&gt;"
+	if got != want {
+		t.Errorf("highlightHTML() = %q, want %q", got, want)
+	}
+}
+
+func TestHighlightHTMLIgnoresUserMarkTags(t *testing.T) {
+	startSel, stopSel := highlightMarkers()
+
+	got := highlightHTML("<mark>fake</mark> "+startSel+"стол"+stopSel, startSel, stopSel)
+
+	if strings.Count(got, "<mark>") != 1 || !strings.Contains(got, "&lt;mark&gt;fake&lt;/mark&gt;") {
+		t.Errorf("highlightHTML() = %q, want only the real match marked", got)
+	}
+}
+
+func TestHighlightMarkersAreUnique(t *testing.T) {
+	start1, _ := highlightMarkers()
+	start2, _ := highlightMarkers()
+	if start1 == start2 {
+		t.Errorf("highlightMarkers() returned the same marker twice: %q", start1)
+	}
+}
@@ -3,34 +3,111 @@ package service
 import (
 	"FurniSwap/internal/modules/listing/model"
 	"FurniSwap/internal/modules/listing/repository"
+	savedsearchService "FurniSwap/internal/modules/savedsearch/service"
+	"FurniSwap/pkg/geo"
 	"FurniSwap/pkg/utils"
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"mime/multipart"
-	"net/http"
-	"strings"
 )
 
 // Service provides listing operations
 type Service struct {
-	repo *repository.Repository
+	repo          *repository.Repository
+	savedSearches *savedsearchService.Service
 }
 
 // NewService creates a new listing service
-func NewService(repo *repository.Repository) *Service {
+func NewService(repo *repository.Repository, savedSearches *savedsearchService.Service) *Service {
 	return &Service{
-		repo: repo,
+		repo:          repo,
+		savedSearches: savedSearches,
 	}
 }
 
-// CreateListing creates a new listing
+// CreateListing creates a new listing and notifies matching saved searches
 func (s *Service) CreateListing(userID int, req model.CreateListingRequest) (int, error) {
-	return s.repo.CreateListing(userID, req)
+	condition, ok := model.NormalizeCondition(req.Condition)
+	if !ok {
+		return 0, errors.New("invalid condition")
+	}
+	req.Condition = condition
+
+	if _, err := geo.NewLocation(req.Latitude, req.Longitude); err != nil {
+		return 0, err
+	}
+
+	// Check the attributes against the category schema
+	schema, err := s.repo.GetCategoryAttributes(req.CategoryID)
+	if err != nil {
+		return 0, err
+	}
+	if err = req.Attributes.Validate(schema); err != nil {
+		return 0, err
+	}
+
+	listingID, err := s.repo.CreateListing(userID, req)
+	if err != nil {
+		return 0, err
+	}
+
+	s.savedSearches.ListingCreated(listingID)
+	return listingID, nil
 }
 
 // UpdateListing updates an existing listing
 func (s *Service) UpdateListing(listingID, userID int, req model.UpdateListingRequest) error {
-	return s.repo.UpdateListing(listingID, userID, req)
+	if req.Condition != "" {
+		condition, ok := model.NormalizeCondition(req.Condition)
+		if !ok {
+			return errors.New("invalid condition")
+		}
+		req.Condition = condition
+	}
+
+	if _, err := geo.NewLocation(req.Latitude, req.Longitude); err != nil {
+		return err
+	}
+
+	current, err := s.repo.GetListing(listingID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("listing not found or does not belong to the user")
+		}
+		return err
+	}
+	if current.UserID != userID {
+		return errors.New("listing not found or does not belong to the user")
+	}
+
+	categoryID := current.CategoryID
+	if req.CategoryID != 0 {
+		categoryID = req.CategoryID
+	}
+
+	schema, err := s.repo.GetCategoryAttributes(categoryID)
+	if err != nil {
+		return err
+	}
+
+	// Apply the changed attributes; when moving to another category, drop the ones its schema doesn't have
+	attributes := current.Attributes.Merge(req.Attributes)
+	if categoryID != current.CategoryID && len(schema) > 0 {
+		allowed := make(map[string]bool)
+		for _, attribute := range schema {
+			allowed[attribute.Attribute] = true
+		}
+		attributes = current.Attributes.Only(allowed).Merge(req.Attributes)
+	}
+
+	if err = attributes.Validate(schema); err != nil {
+		return err
+	}
+
+	return s.repo.UpdateListing(listingID, userID, req, attributes)
 }
 
 // DeleteListing deletes a listing
@@ -41,16 +118,9 @@ func (s *Service) DeleteListing(listingID, userID int) error {
 		log.Printf("Error getting listing for deletion: %v", err)
 		// Continue with deletion attempt anyway
 	} else {
-		// Delete all local image files (skip URLs)
+		// Delete all local image files with their renditions (URLs have none)
 		for _, image := range listing.Images {
-			// Only delete the file if it's a local image (not a URL)
-			if !strings.HasPrefix(image.ImagePath, "http://") && !strings.HasPrefix(image.ImagePath, "https://") {
-				err = utils.DeleteFile(image.ImagePath)
-				if err != nil {
-					log.Printf("Error deleting image file: %v", err)
-					// Continue deletion process anyway
-				}
-			}
+			utils.DeleteFiles(image.FilePaths())
 		}
 	}
 
@@ -65,9 +135,27 @@ func (s *Service) GetListing(listingID int) (*model.Listing, error) {
 
 // GetListings gets listings with filtering and pagination
 func (s *Service) GetListings(filter model.ListingFilter) (*model.ListingResponse, error) {
+	if err := s.normalizeFilter(&filter); err != nil {
+		return nil, err
+	}
+
 	return s.repo.GetListings(filter)
 }
 
+// GetAttributeDefinitions gets the attributes of a category, or all attributes if categoryID is 0
+func (s *Service) GetAttributeDefinitions(categoryID int) ([]model.AttributeDefinition, error) {
+	if categoryID == 0 {
+		return model.AttributeDefinitions, nil
+	}
+
+	schema, err := s.repo.GetCategoryAttributes(categoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	return model.SchemaDefinitions(schema), nil
+}
+
 // GetUserListings gets all listings for a user
 func (s *Service) GetUserListings(userID int) ([]model.Listing, error) {
 	return s.repo.GetUserListings(userID)
@@ -75,36 +163,166 @@ func (s *Service) GetUserListings(userID int) ([]model.Listing, error) {
 
 // SearchListings searches for listings by keyword
 func (s *Service) SearchListings(keyword string, filter model.ListingFilter) (*model.ListingResponse, error) {
+	if err := s.normalizeFilter(&filter); err != nil {
+		return nil, err
+	}
+
 	return s.repo.SearchListings(keyword, filter)
 }
 
+// normalizeFilter normalizes the condition filter and resolves the filter location
+func (s *Service) normalizeFilter(filter *model.ListingFilter) error {
+	if filter.Condition != "" {
+		condition, ok := model.NormalizeCondition(filter.Condition)
+		if !ok {
+			return errors.New("invalid condition")
+		}
+		filter.Condition = condition
+	}
+
+	return s.resolveLocation(filter)
+}
+
+// GetConditions gets the listing conditions with labels in the given language
+func (s *Service) GetConditions(language string) []model.ConditionInfo {
+	return model.LocalizedConditions(language)
+}
+
+// resolveLocation sets the filter location from the near parameter, falling back to
+// the viewer's saved location when the radius or distance sorting needs one
+func (s *Service) resolveLocation(filter *model.ListingFilter) error {
+	if filter.Near != "" {
+		location, err := geo.ParseLocation(filter.Near)
+		if err != nil {
+			return errors.New("invalid near parameter")
+		}
+		filter.Location = location
+		return nil
+	}
+
+	if filter.RadiusKm == nil && filter.SortBy != "distance" {
+		return nil
+	}
+
+	if filter.ViewerID > 0 {
+		location, err := s.repo.GetUserLocation(filter.ViewerID)
+		if err != nil {
+			return err
+		}
+		filter.Location = location
+	}
+
+	if filter.Location == nil {
+		return errors.New("location is required")
+	}
+
+	return nil
+}
+
 // UploadListingImage uploads an image for a listing
 func (s *Service) UploadListingImage(listingID, userID int, file *multipart.FileHeader) (int, error) {
-	// First check if the listing exists and belongs to the user
+	imageIDs, err := s.UploadListingImages(listingID, userID, []*multipart.FileHeader{file})
+	if err != nil {
+		return 0, err
+	}
+
+	return imageIDs[0], nil
+}
+
+// UploadListingImages uploads several images for a listing, in order. Either all images
+// are added or, if any of them is invalid or can't be added, none.
+func (s *Service) UploadListingImages(listingID, userID int, files []*multipart.FileHeader) ([]int, error) {
+	// First check if the listing exists, belongs to the user and has room for the images
+	if err := s.checkImageLimit(listingID, userID, len(files)); err != nil {
+		return nil, err
+	}
+
+	// Verify every image and store its renditions before adding any of them
+	folder := fmt.Sprintf("listings/%d", listingID)
+	stored := make([]map[string]string, 0, len(files))
+	for _, file := range files {
+		paths, err := utils.ProcessImage(file, folder, model.ImageRenditions)
+		if err != nil {
+			for _, paths := range stored {
+				utils.DeleteFiles(paths)
+			}
+			return nil, fmt.Errorf("error uploading image %s: %w", file.Filename, err)
+		}
+		stored = append(stored, paths)
+	}
+
+	return s.addImages(listingID, userID, stored)
+}
+
+// checkImageLimit checks that the listing belongs to the user and can take count more
+// images; it saves processing images that can't be added, and addImages checks again
+func (s *Service) checkImageLimit(listingID, userID, count int) error {
 	listing, err := s.repo.GetListing(listingID)
 	if err != nil {
-		return 0, fmt.Errorf("error getting listing: %w", err)
+		return fmt.Errorf("error getting listing: %w", err)
 	}
 
 	if listing.UserID != userID {
-		return 0, fmt.Errorf("listing does not belong to the user")
+		return fmt.Errorf("listing does not belong to the user")
 	}
 
-	// Upload the image
-	imagePath, err := utils.UploadFile(file, fmt.Sprintf("listings/%d", listingID))
+	if len(listing.Images)+count > model.MaxImages {
+		return fmt.Errorf("too many images")
+	}
+
+	return nil
+}
+
+// addImages adds stored image renditions to a listing, deleting the files if they can't be added
+func (s *Service) addImages(listingID, userID int, stored []map[string]string) ([]int, error) {
+	imageIDs, err := s.repo.AddImages(listingID, userID, stored)
+	if err != nil {
+		for _, paths := range stored {
+			utils.DeleteFiles(paths)
+		}
+		return nil, err
+	}
+
+	return imageIDs, nil
+}
+
+// MirrorURLImages downloads the listing images that are still external URLs, added before
+// image URLs were downloaded, and stores them like uploaded ones so they are no longer
+// hotlinked. Images whose URL is blocked or isn't an image are deleted; ones that fail to
+// download are retried on the next start.
+func (s *Service) MirrorURLImages(ctx context.Context) {
+	images, err := s.repo.GetURLImages()
 	if err != nil {
-		return 0, fmt.Errorf("error uploading image: %w", err)
+		return
 	}
 
-	// Add the image to the database
-	imageID, err := s.repo.AddImage(listingID, imagePath)
+	for _, image := range images {
+		if ctx.Err() != nil {
+			return
+		}
+		s.mirrorURLImage(image)
+	}
+}
+
+// mirrorURLImage replaces a URL image with stored renditions, or deletes it if it can't be copied
+func (s *Service) mirrorURLImage(image model.Image) {
+	data, err := utils.FetchImage(image.ImagePath)
+	var paths map[string]string
+	if err == nil {
+		paths, err = utils.ProcessImageData(data, fmt.Sprintf("listings/%d", image.ListingID), model.ImageRenditions)
+	}
 	if err != nil {
-		// If there's an error adding to the database, delete the uploaded file
-		_ = utils.DeleteFile(imagePath)
-		return 0, fmt.Errorf("error adding image to database: %w", err)
+		log.Printf("Error copying image %d from %s: %v", image.ID, image.ImagePath, err)
+		if utils.IsPermanentImageError(err) {
+			_ = s.repo.DeleteURLImage(image.ID, image.ListingID, image.ImagePath)
+		}
+		return
 	}
 
-	return imageID, nil
+	replaced, err := s.repo.ReplaceURLImage(image.ID, image.ImagePath, paths)
+	if err != nil || !replaced {
+		utils.DeleteFiles(paths)
+	}
 }
 
 // DeleteListingImage deletes an image from a listing
@@ -116,15 +334,15 @@ func (s *Service) DeleteListingImage(imageID, listingID, userID int) error {
 	}
 
 	// Find the image
-	var imagePath string
-	for _, image := range listing.Images {
-		if image.ID == imageID {
-			imagePath = image.ImagePath
+	var image *model.Image
+	for i := range listing.Images {
+		if listing.Images[i].ID == imageID {
+			image = &listing.Images[i]
 			break
 		}
 	}
 
-	if imagePath == "" {
+	if image == nil {
 		return fmt.Errorf("image not found")
 	}
 
@@ -134,15 +352,9 @@ func (s *Service) DeleteListingImage(imageID, listingID, userID int) error {
 		return err
 	}
 
-	// Only delete the file if it's a local image (not a URL)
-	if !strings.HasPrefix(imagePath, "http://") && !strings.HasPrefix(imagePath, "https://") {
-		// Delete image file
-		err = utils.DeleteFile(imagePath)
-		if err != nil {
-			log.Printf("Error deleting image file: %v", err)
-			// Continue anyway as the database entry is already deleted
-		}
-	}
+	// Delete the image files; URLs have none. Failures are only logged
+	// as the database entry is already deleted
+	utils.DeleteFiles(image.FilePaths())
 
 	return nil
 }
@@ -152,41 +364,33 @@ func (s *Service) SetMainImage(imageID, listingID, userID int) error {
 	return s.repo.SetMainImage(imageID, listingID, userID)
 }
 
-// AddListingImageURL adds an image URL to a listing
-func (s *Service) AddListingImageURL(listingID, userID int, imageURL string) (int, error) {
-	// First check if the listing exists and belongs to the user
-	listing, err := s.repo.GetListing(listingID)
-	if err != nil {
-		return 0, fmt.Errorf("error getting listing: %w", err)
-	}
-
-	if listing.UserID != userID {
-		return 0, fmt.Errorf("listing does not belong to the user")
-	}
+// ReorderListingImages sets the order of all images of a listing; the first becomes the main image
+func (s *Service) ReorderListingImages(listingID, userID int, imageIDs []int) error {
+	return s.repo.ReorderImages(listingID, userID, imageIDs)
+}
 
-	// Basic validation of the URL
-	if !strings.HasPrefix(imageURL, "http://") && !strings.HasPrefix(imageURL, "https://") {
-		return 0, fmt.Errorf("invalid image URL, must start with http:// or https://")
+// AddListingImageURL downloads an image from a URL and adds it to a listing like an uploaded one
+func (s *Service) AddListingImageURL(listingID, userID int, imageURL string) (int, error) {
+	// First check if the listing exists, belongs to the user and has room for the image
+	if err := s.checkImageLimit(listingID, userID, 1); err != nil {
+		return 0, err
 	}
 
-	// Optional: Verify the URL points to an image
-	// This is a basic check and could be improved
-	resp, err := http.Head(imageURL)
+	// Download a copy so the image doesn't depend on the remote host
+	data, err := utils.FetchImage(imageURL)
 	if err != nil {
-		return 0, fmt.Errorf("error validating image URL: %w", err)
+		return 0, err
 	}
-	defer resp.Body.Close()
 
-	contentType := resp.Header.Get("Content-Type")
-	if !strings.HasPrefix(contentType, "image/") {
-		return 0, fmt.Errorf("URL does not point to a valid image: %s", contentType)
+	paths, err := utils.ProcessImageData(data, fmt.Sprintf("listings/%d", listingID), model.ImageRenditions)
+	if err != nil {
+		return 0, fmt.Errorf("error storing image: %w", err)
 	}
 
-	// Add the image URL to the database
-	imageID, err := s.repo.AddImage(listingID, imageURL)
+	imageIDs, err := s.addImages(listingID, userID, []map[string]string{paths})
 	if err != nil {
-		return 0, fmt.Errorf("error adding image to database: %w", err)
+		return 0, err
 	}
 
-	return imageID, nil
+	return imageIDs[0], nil
 }
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"FurniSwap/internal/modules/moderation/model"
+	"FurniSwap/internal/modules/moderation/service"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler provides blocking and reporting handlers
+type Handler struct {
+	service *service.Service
+}
+
+// NewHandler creates a new moderation handler
+func NewHandler(service *service.Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers moderation routes to router
+func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/blocks", h.GetBlockedUsers)
+	router.POST("/users/:id/block", h.BlockUser)
+	router.DELETE("/users/:id/block", h.UnblockUser)
+	router.POST("/reports", h.ReportUser)
+}
+
+// GetBlockedUsers handles getting the user's block list
+func (h *Handler) GetBlockedUsers(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	users, err := h.service.GetBlockedUsers(userID.(int))
+	if err != nil {
+		log.Printf("Error getting blocked users: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting blocked users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// BlockUser handles blocking a user
+func (h *Handler) BlockUser(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse blocked user ID
+	blockedID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err = h.service.BlockUser(userID.(int), blockedID); err != nil {
+		h.handleModerationError(c, err, "Error blocking user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User blocked"})
+}
+
+// UnblockUser handles removing a user from the block list
+func (h *Handler) UnblockUser(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse blocked user ID
+	blockedID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err = h.service.UnblockUser(userID.(int), blockedID); err != nil {
+		h.handleModerationError(c, err, "Error unblocking user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unblocked"})
+}
+
+// ReportUser handles reporting a user to moderators
+func (h *Handler) ReportUser(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse request body
+	var req model.ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	reportID, err := h.service.ReportUser(userID.(int), req)
+	if err != nil {
+		h.handleModerationError(c, err, "Error reporting user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": reportID, "message": "Report submitted"})
+}
+
+// handleModerationError maps moderation service errors to HTTP responses
+func (h *Handler) handleModerationError(c *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "user not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case "user is not blocked":
+		c.JSON(http.StatusNotFound, gin.H{"error": "User is not blocked"})
+	case "chat not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
+	case "message not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+	case "you don't have access to this chat":
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this chat"})
+	case "you cannot block yourself":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot block yourself"})
+	case "you cannot report yourself":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot report yourself"})
+	case "the message was not sent by the reported user":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The message was not sent by the reported user"})
+	case "the reported user is not in this chat":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The reported user is not in this chat"})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+)
+
+// Report reasons
+const (
+	ReasonSpam  = "spam"
+	ReasonAbuse = "abuse"
+	ReasonFraud = "fraud"
+	ReasonOther = "other"
+)
+
+// Report statuses
+const (
+	ReportStatusOpen = "open"
+)
+
+// BlockedUser represents a user on the block list
+type BlockedUser struct {
+	UserID    int       `db:"user_id" json:"user_id"`
+	UserName  string    `db:"user_name" json:"user_name"`
+	Avatar    string    `db:"avatar" json:"avatar"`
+	BlockedAt time.Time `db:"blocked_at" json:"blocked_at"`
+}
+
+// Report represents a complaint about a user for moderators. The reported
+// message content is copied so it is preserved even if the message is deleted.
+type Report struct {
+	ID             int       `db:"id" json:"id"`
+	ReporterID     int       `db:"reporter_id" json:"reporter_id"`
+	ReportedUserID int       `db:"reported_user_id" json:"reported_user_id"`
+	ChatID         *int      `db:"chat_id" json:"chat_id,omitempty"`
+	MessageID      *int      `db:"message_id" json:"message_id,omitempty"`
+	MessageContent string    `db:"message_content" json:"message_content,omitempty"`
+	Reason         string    `db:"reason" json:"reason"`
+	Comment        string    `db:"comment" json:"comment,omitempty"`
+	Status         string    `db:"status" json:"status"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+}
+
+// ReportedMessage represents a chat message referenced by a report
+type ReportedMessage struct {
+	ChatID   int    `db:"chat_id"`
+	SenderID int    `db:"sender_id"`
+	Content  string `db:"content"`
+	BuyerID  int    `db:"buyer_id"`
+	SellerID int    `db:"seller_id"`
+}
+
+// ReportRequest represents the data needed to report a user
+type ReportRequest struct {
+	ReportedUserID int    `json:"reported_user_id" binding:"required"`
+	ChatID         *int   `json:"chat_id"`
+	MessageID      *int   `json:"message_id"`
+	Reason         string `json:"reason" binding:"required,oneof=spam abuse fraud other"`
+	Comment        string `json:"comment" binding:"max=1000"`
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"FurniSwap/internal/modules/moderation/model"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Repository handles database operations for the moderation module
+type Repository struct {
+	db *sqlx.DB
+}
+
+// NewRepository creates a new moderation repository
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// UserExists checks if a user exists
+func (r *Repository) UserExists(userID int) (bool, error) {
+	var exists bool
+	err := r.db.Get(&exists, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", userID)
+	if err != nil {
+		log.Printf("Error checking user existence: %v", err)
+		return false, fmt.Errorf("error checking user existence: %w", err)
+	}
+	return exists, nil
+}
+
+// BlockUser adds a user to the blocker's block list
+func (r *Repository) BlockUser(blockerID, blockedID int) error {
+	_, err := r.db.Exec(`
+		INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (blocker_id, blocked_id) DO NOTHING
+	`, blockerID, blockedID, time.Now())
+
+	if err != nil {
+		log.Printf("Error blocking user: %v", err)
+		return fmt.Errorf("error blocking user: %w", err)
+	}
+
+	return nil
+}
+
+// UnblockUser removes a user from the blocker's block list and reports whether it was there
+func (r *Repository) UnblockUser(blockerID, blockedID int) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2", blockerID, blockedID)
+	if err != nil {
+		log.Printf("Error unblocking user: %v", err)
+		return false, fmt.Errorf("error unblocking user: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// GetBlockedUsers gets the block list of a user
+func (r *Repository) GetBlockedUsers(userID int) ([]model.BlockedUser, error) {
+	users := []model.BlockedUser{}
+	err := r.db.Select(&users, `
+		SELECT u.id as user_id,
+			   u.name || ' ' || COALESCE(u.last_name, '') as user_name,
+			   COALESCE(u.avatar, '') as avatar,
+			   b.created_at as blocked_at
+		FROM user_blocks b
+		JOIN users u ON b.blocked_id = u.id
+		WHERE b.blocker_id = $1
+		ORDER BY b.created_at DESC
+	`, userID)
+
+	if err != nil {
+		log.Printf("Error getting blocked users: %v", err)
+		return nil, fmt.Errorf("error getting blocked users: %w", err)
+	}
+
+	return users, nil
+}
+
+// IsBlockedBetween checks if either of the two users has blocked the other
+func (r *Repository) IsBlockedBetween(user1ID, user2ID int) (bool, error) {
+	var blocked bool
+	err := r.db.Get(&blocked, `
+		SELECT EXISTS(SELECT 1 FROM user_blocks
+		              WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1))
+	`, user1ID, user2ID)
+
+	if err != nil {
+		log.Printf("Error checking user blocks: %v", err)
+		return false, fmt.Errorf("error checking user blocks: %w", err)
+	}
+
+	return blocked, nil
+}
+
+// GetChatParticipants gets the buyer and seller of a chat, returning nil if the chat doesn't exist
+func (r *Repository) GetChatParticipants(chatID int) ([]int, error) {
+	var chat struct {
+		BuyerID  int `db:"buyer_id"`
+		SellerID int `db:"seller_id"`
+	}
+	err := r.db.Get(&chat, "SELECT buyer_id, seller_id FROM chats WHERE id = $1", chatID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Chat doesn't exist
+		}
+		log.Printf("Error getting chat participants: %v", err)
+		return nil, fmt.Errorf("error getting chat participants: %w", err)
+	}
+
+	return []int{chat.BuyerID, chat.SellerID}, nil
+}
+
+// GetReportedMessage gets a chat message with its chat participants, returning nil if it doesn't exist
+func (r *Repository) GetReportedMessage(messageID int) (*model.ReportedMessage, error) {
+	var message model.ReportedMessage
+	err := r.db.Get(&message, `
+		SELECT m.chat_id, m.user_id as sender_id, m.content, c.buyer_id, c.seller_id
+		FROM messages m
+		JOIN chats c ON m.chat_id = c.id
+		WHERE m.id = $1
+	`, messageID)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Message doesn't exist
+		}
+		log.Printf("Error getting reported message: %v", err)
+		return nil, fmt.Errorf("error getting reported message: %w", err)
+	}
+
+	return &message, nil
+}
+
+// CreateReport saves a report for moderators
+func (r *Repository) CreateReport(report model.Report) (int, error) {
+	var reportID int
+	err := r.db.QueryRow(`
+		INSERT INTO user_reports (reporter_id, reported_user_id, chat_id, message_id, message_content, reason, comment, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, report.ReporterID, report.ReportedUserID, report.ChatID, report.MessageID, report.MessageContent,
+		report.Reason, report.Comment, model.ReportStatusOpen, time.Now()).Scan(&reportID)
+
+	if err != nil {
+		log.Printf("Error creating report: %v", err)
+		return 0, fmt.Errorf("error creating report: %w", err)
+	}
+
+	return reportID, nil
+}
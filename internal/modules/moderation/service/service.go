@@ -0,0 +1,132 @@
+package service
+
+import (
+	"FurniSwap/internal/modules/moderation/model"
+	"FurniSwap/internal/modules/moderation/repository"
+	"errors"
+)
+
+// Service provides blocking and reporting operations
+type Service struct {
+	repo *repository.Repository
+}
+
+// NewService creates a new moderation service
+func NewService(repo *repository.Repository) *Service {
+	return &Service{
+		repo: repo,
+	}
+}
+
+// BlockUser adds a user to the block list
+func (s *Service) BlockUser(userID, blockedID int) error {
+	if userID == blockedID {
+		return errors.New("you cannot block yourself")
+	}
+
+	if err := s.checkUserExists(blockedID); err != nil {
+		return err
+	}
+
+	return s.repo.BlockUser(userID, blockedID)
+}
+
+// UnblockUser removes a user from the block list
+func (s *Service) UnblockUser(userID, blockedID int) error {
+	removed, err := s.repo.UnblockUser(userID, blockedID)
+	if err != nil {
+		return err
+	}
+
+	if !removed {
+		return errors.New("user is not blocked")
+	}
+
+	return nil
+}
+
+// GetBlockedUsers gets the user's block list
+func (s *Service) GetBlockedUsers(userID int) ([]model.BlockedUser, error) {
+	return s.repo.GetBlockedUsers(userID)
+}
+
+// ReportUser files a report about a user. If a message or chat is given, the
+// reporter must be a participant and the reported user must be its author or
+// the other participant; the message content is captured with the report.
+func (s *Service) ReportUser(userID int, req model.ReportRequest) (int, error) {
+	if userID == req.ReportedUserID {
+		return 0, errors.New("you cannot report yourself")
+	}
+
+	if err := s.checkUserExists(req.ReportedUserID); err != nil {
+		return 0, err
+	}
+
+	report := model.Report{
+		ReporterID:     userID,
+		ReportedUserID: req.ReportedUserID,
+		ChatID:         req.ChatID,
+		MessageID:      req.MessageID,
+		Reason:         req.Reason,
+		Comment:        req.Comment,
+	}
+
+	if req.MessageID != nil {
+		message, err := s.repo.GetReportedMessage(*req.MessageID)
+		if err != nil {
+			return 0, err
+		}
+		if message == nil || (req.ChatID != nil && *req.ChatID != message.ChatID) {
+			return 0, errors.New("message not found")
+		}
+		if message.BuyerID != userID && message.SellerID != userID {
+			return 0, errors.New("you don't have access to this chat")
+		}
+		if message.SenderID != req.ReportedUserID {
+			return 0, errors.New("the message was not sent by the reported user")
+		}
+
+		report.ChatID = &message.ChatID
+		report.MessageContent = message.Content
+	} else if req.ChatID != nil {
+		participants, err := s.repo.GetChatParticipants(*req.ChatID)
+		if err != nil {
+			return 0, err
+		}
+		if participants == nil {
+			return 0, errors.New("chat not found")
+		}
+		if !contains(participants, userID) {
+			return 0, errors.New("you don't have access to this chat")
+		}
+		if !contains(participants, req.ReportedUserID) {
+			return 0, errors.New("the reported user is not in this chat")
+		}
+	}
+
+	return s.repo.CreateReport(report)
+}
+
+// checkUserExists returns an error if the user doesn't exist
+func (s *Service) checkUserExists(userID int) error {
+	exists, err := s.repo.UserExists(userID)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// contains checks if the slice contains the value
+func contains(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,267 @@
+package handler
+
+import (
+	"FurniSwap/internal/modules/offer/model"
+	"FurniSwap/internal/modules/offer/service"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler provides price offer handlers
+type Handler struct {
+	service *service.Service
+}
+
+// NewHandler creates a new offer handler
+func NewHandler(service *service.Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers offer routes to router
+func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
+	router.POST("/listings/:id/offers", h.MakeOffer)
+	router.GET("/offers", h.GetUserOffers)
+	router.GET("/offers/:id", h.GetOffer)
+	router.POST("/offers/:id/accept", h.AcceptOffer)
+	router.POST("/offers/:id/reject", h.RejectOffer)
+	router.POST("/offers/:id/counter", h.CounterOffer)
+	router.POST("/offers/:id/cancel", h.CancelOffer)
+}
+
+// MakeOffer handles making a price offer on a listing
+func (h *Handler) MakeOffer(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse listing ID
+	listingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid listing ID"})
+		return
+	}
+
+	// Parse request body
+	var req model.OfferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	offerID, err := h.service.MakeOffer(userID.(int), listingID, req)
+	if err != nil {
+		h.handleOfferError(c, err, "Error making offer")
+		return
+	}
+
+	h.respondWithOffer(c, offerID, userID.(int), "Offer sent")
+}
+
+// GetUserOffers handles getting the user's offers
+func (h *Handler) GetUserOffers(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+
+	// Get offers (role: incoming, outgoing or all)
+	offers, err := h.service.GetUserOffers(userID.(int), c.DefaultQuery("role", "all"), page, limit)
+	if err != nil {
+		log.Printf("Error getting offers: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting offers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, offers)
+}
+
+// GetOffer handles getting a single offer
+func (h *Handler) GetOffer(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse offer ID
+	offerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offer ID"})
+		return
+	}
+
+	offer, err := h.service.GetOfferByID(offerID, userID.(int))
+	if err != nil {
+		h.handleOfferError(c, err, "Error getting offer")
+		return
+	}
+
+	c.JSON(http.StatusOK, offer)
+}
+
+// AcceptOffer handles accepting an offer
+func (h *Handler) AcceptOffer(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse offer ID
+	offerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offer ID"})
+		return
+	}
+
+	if err = h.service.AcceptOffer(offerID, userID.(int)); err != nil {
+		h.handleOfferError(c, err, "Error accepting offer")
+		return
+	}
+
+	h.respondWithOffer(c, offerID, userID.(int), "Offer accepted")
+}
+
+// RejectOffer handles rejecting an offer
+func (h *Handler) RejectOffer(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse offer ID
+	offerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offer ID"})
+		return
+	}
+
+	if err = h.service.RejectOffer(offerID, userID.(int)); err != nil {
+		h.handleOfferError(c, err, "Error rejecting offer")
+		return
+	}
+
+	h.respondWithOffer(c, offerID, userID.(int), "Offer rejected")
+}
+
+// CounterOffer handles making a counter-offer
+func (h *Handler) CounterOffer(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse offer ID
+	offerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offer ID"})
+		return
+	}
+
+	// Parse request body
+	var req model.OfferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	newOfferID, err := h.service.CounterOffer(offerID, userID.(int), req)
+	if err != nil {
+		h.handleOfferError(c, err, "Error making counter-offer")
+		return
+	}
+
+	h.respondWithOffer(c, newOfferID, userID.(int), "Counter-offer sent")
+}
+
+// CancelOffer handles withdrawing an offer
+func (h *Handler) CancelOffer(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse offer ID
+	offerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offer ID"})
+		return
+	}
+
+	if err = h.service.CancelOffer(offerID, userID.(int)); err != nil {
+		h.handleOfferError(c, err, "Error cancelling offer")
+		return
+	}
+
+	h.respondWithOffer(c, offerID, userID.(int), "Offer cancelled")
+}
+
+// respondWithOffer writes the current state of an offer, falling back to a plain message
+func (h *Handler) respondWithOffer(c *gin.Context, offerID, userID int, message string) {
+	offer, err := h.service.GetOfferByID(offerID, userID)
+	if err != nil {
+		log.Printf("Error getting offer: %v", err)
+		c.JSON(http.StatusOK, gin.H{"id": offerID, "message": message})
+		return
+	}
+
+	c.JSON(http.StatusOK, offer)
+}
+
+// handleOfferError maps offer service errors to HTTP responses
+func (h *Handler) handleOfferError(c *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "listing not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Listing not found"})
+	case "offer not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
+	case "you don't have access to this offer":
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this offer"})
+	case "only the author can cancel the offer":
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can cancel the offer"})
+	case "you cannot make an offer on your own listing":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot make an offer on your own listing"})
+	case "listing is not available for offers":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Listing is not available for offers"})
+	case "you cannot respond to your own offer":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot respond to your own offer"})
+	case "you already have a pending offer for this listing":
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have a pending offer for this listing"})
+	case "offer is not pending":
+		c.JSON(http.StatusConflict, gin.H{"error": "Offer is no longer pending"})
+	case "you cannot make offers to this user":
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot make offers to this user"})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
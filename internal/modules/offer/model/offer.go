@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+)
+
+// Offer statuses
+const (
+	StatusPending   = "pending"
+	StatusAccepted  = "accepted"
+	StatusRejected  = "rejected"
+	StatusCountered = "countered"
+	StatusCancelled = "cancelled"
+	StatusExpired   = "expired"
+	StatusCompleted = "completed"
+)
+
+// Offer represents a price offer on a listing. Counter-offers are stored as new
+// offers pointing to the offer they replace.
+type Offer struct {
+	ID           int       `db:"id" json:"id"`
+	ListingID    int       `db:"listing_id" json:"listing_id"`
+	BuyerID      int       `db:"buyer_id" json:"buyer_id"`
+	SellerID     int       `db:"seller_id" json:"seller_id"`
+	AuthorID     int       `db:"author_id" json:"author_id"`
+	ParentID     *int      `db:"parent_id" json:"parent_id,omitempty"`
+	ChatID       *int      `db:"chat_id" json:"chat_id,omitempty"`
+	Amount       float64   `db:"amount" json:"amount"`
+	Status       string    `db:"status" json:"status"`
+	ExpiresAt    time.Time `db:"expires_at" json:"expires_at"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time `db:"updated_at" json:"updated_at"`
+	ListingTitle string    `db:"listing_title" json:"listing_title,omitempty"`
+	ListingPrice float64   `db:"listing_price" json:"listing_price"`
+	BuyerName    string    `db:"buyer_name" json:"buyer_name,omitempty"`
+	SellerName   string    `db:"seller_name" json:"seller_name,omitempty"`
+}
+
+// OfferResponse represents a list of offers with pagination
+type OfferResponse struct {
+	Offers      []Offer `json:"offers"`
+	TotalCount  int     `json:"total_count"`
+	CurrentPage int     `json:"current_page"`
+	TotalPages  int     `json:"total_pages"`
+}
+
+// OfferRequest represents the data needed to make an offer or a counter-offer
+type OfferRequest struct {
+	Amount float64 `json:"amount" binding:"required,gt=0"`
+}
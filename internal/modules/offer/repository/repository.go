@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"FurniSwap/internal/modules/offer/model"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrOfferNotPending is returned when an offer has already been answered or has expired
+var ErrOfferNotPending = errors.New("offer is not pending")
+
+// Repository handles database operations for the offer module
+type Repository struct {
+	db *sqlx.DB
+}
+
+// NewRepository creates a new offer repository
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+const offerSelect = `
+	SELECT o.id, o.listing_id, o.buyer_id, o.seller_id, o.author_id, o.parent_id, o.chat_id,
+		   o.amount, o.status, o.expires_at, o.created_at, o.updated_at,
+		   COALESCE(l.title, '') as listing_title, COALESCE(l.price, 0) as listing_price,
+		   u1.name || ' ' || COALESCE(u1.last_name, '') as buyer_name,
+		   u2.name || ' ' || COALESCE(u2.last_name, '') as seller_name
+	FROM price_offers o
+	JOIN users u1 ON o.buyer_id = u1.id
+	JOIN users u2 ON o.seller_id = u2.id
+	LEFT JOIN listings l ON o.listing_id = l.id
+`
+
+// CreateOffer creates a new pending offer. If parentID is set, the parent offer
+// is marked as countered in the same transaction.
+func (r *Repository) CreateOffer(listingID, buyerID, sellerID, authorID int, parentID *int, amount float64, expiresAt time.Time) (int, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		return 0, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if parentID != nil {
+		result, err := tx.Exec(`
+			UPDATE price_offers SET status = $1, updated_at = $2
+			WHERE id = $3 AND status = $4 AND expires_at > $2
+		`, model.StatusCountered, time.Now(), *parentID, model.StatusPending)
+		if err != nil {
+			log.Printf("Error marking offer %d as countered: %v", *parentID, err)
+			return 0, fmt.Errorf("error updating offer: %w", err)
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return 0, ErrOfferNotPending
+		}
+	}
+
+	var offerID int
+	err = tx.QueryRow(`
+		INSERT INTO price_offers (listing_id, buyer_id, seller_id, author_id, parent_id, amount, status, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+		RETURNING id
+	`, listingID, buyerID, sellerID, authorID, parentID, amount, model.StatusPending, expiresAt, time.Now()).Scan(&offerID)
+	if err != nil {
+		log.Printf("Error creating offer: %v", err)
+		return 0, fmt.Errorf("error creating offer: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return offerID, nil
+}
+
+// GetOfferByID gets an offer by ID
+func (r *Repository) GetOfferByID(offerID int) (*model.Offer, error) {
+	var offer model.Offer
+	err := r.db.Get(&offer, offerSelect+" WHERE o.id = $1", offerID)
+	if err != nil {
+		log.Printf("Error getting offer by ID: %v", err)
+		return nil, fmt.Errorf("error getting offer: %w", err)
+	}
+	return &offer, nil
+}
+
+// GetUserOffers gets offers where the user is the buyer ("outgoing"), the
+// seller ("incoming") or either of them (any other role)
+func (r *Repository) GetUserOffers(userID int, role string, page, limit int) (*model.OfferResponse, error) {
+	// Calculate offset
+	offset := (page - 1) * limit
+
+	condition := "(o.buyer_id = $1 OR o.seller_id = $1)"
+	switch role {
+	case "incoming":
+		condition = "o.seller_id = $1"
+	case "outgoing":
+		condition = "o.buyer_id = $1"
+	}
+
+	// Get total count
+	var totalCount int
+	err := r.db.Get(&totalCount, "SELECT COUNT(*) FROM price_offers o WHERE "+condition, userID)
+	if err != nil {
+		log.Printf("Error getting offers count: %v", err)
+		return nil, fmt.Errorf("error getting offers count: %w", err)
+	}
+
+	// Calculate total pages
+	totalPages := int(math.Ceil(float64(totalCount) / float64(limit)))
+
+	// Get offers
+	offers := []model.Offer{}
+	err = r.db.Select(&offers, offerSelect+" WHERE "+condition+" ORDER BY o.updated_at DESC LIMIT $2 OFFSET $3", userID, limit, offset)
+	if err != nil {
+		log.Printf("Error getting offers: %v", err)
+		return nil, fmt.Errorf("error getting offers: %w", err)
+	}
+
+	return &model.OfferResponse{
+		Offers:      offers,
+		TotalCount:  totalCount,
+		CurrentPage: page,
+		TotalPages:  totalPages,
+	}, nil
+}
+
+// HasPendingOffer checks if the buyer already has a pending offer for the listing
+func (r *Repository) HasPendingOffer(listingID, buyerID int) (bool, error) {
+	var exists bool
+	err := r.db.Get(&exists, `
+		SELECT EXISTS(SELECT 1 FROM price_offers
+		              WHERE listing_id = $1 AND buyer_id = $2 AND status = $3 AND expires_at > NOW())
+	`, listingID, buyerID, model.StatusPending)
+	if err != nil {
+		log.Printf("Error checking pending offers: %v", err)
+		return false, fmt.Errorf("error checking pending offers: %w", err)
+	}
+	return exists, nil
+}
+
+// UpdateOfferStatus moves a pending, not yet expired offer to a new status
+func (r *Repository) UpdateOfferStatus(offerID int, status string) error {
+	result, err := r.db.Exec(`
+		UPDATE price_offers SET status = $1, updated_at = $2
+		WHERE id = $3 AND status = $4 AND expires_at > $2
+	`, status, time.Now(), offerID, model.StatusPending)
+	if err != nil {
+		log.Printf("Error updating offer status: %v", err)
+		return fmt.Errorf("error updating offer status: %w", err)
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrOfferNotPending
+	}
+
+	return nil
+}
+
+// SetOfferChat links an offer to the chat its system messages were posted to
+func (r *Repository) SetOfferChat(offerID, chatID int) error {
+	_, err := r.db.Exec("UPDATE price_offers SET chat_id = $1 WHERE id = $2", chatID, offerID)
+	if err != nil {
+		log.Printf("Error setting offer chat: %v", err)
+		return fmt.Errorf("error setting offer chat: %w", err)
+	}
+	return nil
+}
+
+// ExpireOffers marks pending offers past their expiration time as expired
+func (r *Repository) ExpireOffers() error {
+	_, err := r.db.Exec(`
+		UPDATE price_offers SET status = $1, updated_at = NOW()
+		WHERE status = $2 AND expires_at <= NOW()
+	`, model.StatusExpired, model.StatusPending)
+	if err != nil {
+		log.Printf("Error expiring offers: %v", err)
+		return fmt.Errorf("error expiring offers: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	chatService "FurniSwap/internal/modules/chat/service"
+	listingRepo "FurniSwap/internal/modules/listing/repository"
+	moderationRepo "FurniSwap/internal/modules/moderation/repository"
+	"FurniSwap/internal/modules/offer/model"
+	offerRepo "FurniSwap/internal/modules/offer/repository"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Service provides price offer operations
+type Service struct {
+	repo        *offerRepo.Repository
+	listingRepo *listingRepo.Repository
+	chatService *chatService.Service
+	blockRepo   *moderationRepo.Repository
+	ttl         time.Duration
+}
+
+// NewService creates a new offer service. Pending offers expire after ttl.
+func NewService(repo *offerRepo.Repository, listingRepo *listingRepo.Repository, chatService *chatService.Service,
+	blockRepo *moderationRepo.Repository, ttl time.Duration) *Service {
+	return &Service{
+		repo:        repo,
+		listingRepo: listingRepo,
+		chatService: chatService,
+		blockRepo:   blockRepo,
+		ttl:         ttl,
+	}
+}
+
+// MakeOffer makes a price offer on a listing
+func (s *Service) MakeOffer(userID, listingID int, req model.OfferRequest) (int, error) {
+	// Get the listing
+	listing, err := s.listingRepo.GetListing(listingID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, errors.New("listing not found")
+		}
+		return 0, fmt.Errorf("error getting listing: %w", err)
+	}
+
+	if listing.UserID == userID {
+		return 0, errors.New("you cannot make an offer on your own listing")
+	}
+
+	if listing.Status != "active" {
+		log.Printf("Listing %d is not available for offers, status: %s", listingID, listing.Status)
+		return 0, errors.New("listing is not available for offers")
+	}
+
+	// Users who blocked each other can't reach each other through offers
+	if err = s.checkNotBlocked(userID, listing.UserID); err != nil {
+		return 0, err
+	}
+
+	// Only one open offer per buyer and listing
+	exists, err := s.repo.HasPendingOffer(listingID, userID)
+	if err != nil {
+		return 0, fmt.Errorf("error checking pending offers: %w", err)
+	}
+	if exists {
+		return 0, errors.New("you already have a pending offer for this listing")
+	}
+
+	offerID, err := s.repo.CreateOffer(listingID, userID, listing.UserID, userID, nil, req.Amount, time.Now().Add(s.ttl))
+	if err != nil {
+		return 0, fmt.Errorf("error creating offer: %w", err)
+	}
+
+	s.notifyChat(offerID, userID, fmt.Sprintf("Offer for \"%s\": %.2f (listed at %.2f)", listing.Title, req.Amount, listing.Price))
+
+	return offerID, nil
+}
+
+// CounterOffer replaces a pending offer with a new amount proposed by the other party
+func (s *Service) CounterOffer(offerID, userID int, req model.OfferRequest) (int, error) {
+	offer, err := s.getPendingOfferForResponse(offerID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = s.checkNotBlocked(offer.BuyerID, offer.SellerID); err != nil {
+		return 0, err
+	}
+
+	newOfferID, err := s.repo.CreateOffer(offer.ListingID, offer.BuyerID, offer.SellerID, userID, &offer.ID, req.Amount, time.Now().Add(s.ttl))
+	if err != nil {
+		if errors.Is(err, offerRepo.ErrOfferNotPending) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("error creating counter-offer: %w", err)
+	}
+
+	s.notifyChat(newOfferID, userID, fmt.Sprintf("Counter-offer for \"%s\": %.2f", offer.ListingTitle, req.Amount))
+
+	return newOfferID, nil
+}
+
+// AcceptOffer accepts a pending offer. The buyer can then buy the listing at the agreed price.
+func (s *Service) AcceptOffer(offerID, userID int) error {
+	offer, err := s.getPendingOfferForResponse(offerID, userID)
+	if err != nil {
+		return err
+	}
+
+	// Don't accept offers for listings that are gone already
+	listing, err := s.listingRepo.GetListing(offer.ListingID)
+	if err != nil {
+		return fmt.Errorf("error getting listing: %w", err)
+	}
+	if listing.Status != "active" {
+		return errors.New("listing is not available for offers")
+	}
+
+	if err = s.repo.UpdateOfferStatus(offerID, model.StatusAccepted); err != nil {
+		return err
+	}
+
+	s.notifyChat(offerID, userID, fmt.Sprintf("Offer accepted for \"%s\": %.2f", offer.ListingTitle, offer.Amount))
+
+	return nil
+}
+
+// RejectOffer rejects a pending offer
+func (s *Service) RejectOffer(offerID, userID int) error {
+	offer, err := s.getPendingOfferForResponse(offerID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err = s.repo.UpdateOfferStatus(offerID, model.StatusRejected); err != nil {
+		return err
+	}
+
+	s.notifyChat(offerID, userID, fmt.Sprintf("Offer rejected for \"%s\": %.2f", offer.ListingTitle, offer.Amount))
+
+	return nil
+}
+
+// CancelOffer withdraws a pending offer made by the user
+func (s *Service) CancelOffer(offerID, userID int) error {
+	offer, err := s.GetOfferByID(offerID, userID)
+	if err != nil {
+		return err
+	}
+
+	if offer.AuthorID != userID {
+		return errors.New("only the author can cancel the offer")
+	}
+
+	if offer.Status != model.StatusPending {
+		return offerRepo.ErrOfferNotPending
+	}
+
+	if err = s.repo.UpdateOfferStatus(offerID, model.StatusCancelled); err != nil {
+		return err
+	}
+
+	s.notifyChat(offerID, userID, fmt.Sprintf("Offer withdrawn for \"%s\": %.2f", offer.ListingTitle, offer.Amount))
+
+	return nil
+}
+
+// GetOfferByID gets an offer visible to the user
+func (s *Service) GetOfferByID(offerID, userID int) (*model.Offer, error) {
+	s.expireOffers()
+
+	offer, err := s.repo.GetOfferByID(offerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("offer not found")
+		}
+		return nil, fmt.Errorf("error getting offer: %w", err)
+	}
+
+	if offer.BuyerID != userID && offer.SellerID != userID {
+		return nil, errors.New("you don't have access to this offer")
+	}
+
+	return offer, nil
+}
+
+// GetUserOffers gets the user's offers
+func (s *Service) GetUserOffers(userID int, role string, page, limit int) (*model.OfferResponse, error) {
+	s.expireOffers()
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+	return s.repo.GetUserOffers(userID, role, page, limit)
+}
+
+// getPendingOfferForResponse gets a pending offer that the user is expected to answer
+func (s *Service) getPendingOfferForResponse(offerID, userID int) (*model.Offer, error) {
+	offer, err := s.GetOfferByID(offerID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if offer.Status != model.StatusPending {
+		return nil, offerRepo.ErrOfferNotPending
+	}
+
+	if offer.AuthorID == userID {
+		return nil, errors.New("you cannot respond to your own offer")
+	}
+
+	return offer, nil
+}
+
+// expireOffers marks stale pending offers as expired so they are reported correctly
+func (s *Service) expireOffers() {
+	if err := s.repo.ExpireOffers(); err != nil {
+		log.Printf("Error expiring offers: %v", err)
+	}
+}
+
+// checkNotBlocked returns an error if either user has blocked the other
+func (s *Service) checkNotBlocked(userID, otherID int) error {
+	blocked, err := s.blockRepo.IsBlockedBetween(userID, otherID)
+	if err != nil {
+		return fmt.Errorf("error checking user blocks: %w", err)
+	}
+
+	if blocked {
+		return errors.New("you cannot make offers to this user")
+	}
+
+	return nil
+}
+
+// notifyChat posts a system message from the sender about the offer into the
+// buyer-seller chat. Failures are logged and don't affect the offer itself.
+func (s *Service) notifyChat(offerID, senderID int, content string) {
+	offer, err := s.repo.GetOfferByID(offerID)
+	if err != nil {
+		log.Printf("Error getting offer %d for chat notification: %v", offerID, err)
+		return
+	}
+
+	chatID, err := s.chatService.PostSystemMessage(senderID, offer.BuyerID, offer.SellerID, &offer.ListingID, content)
+	if err != nil {
+		log.Printf("Error posting offer %d to chat: %v", offerID, err)
+		return
+	}
+
+	if offer.ChatID == nil || *offer.ChatID != chatID {
+		if err = s.repo.SetOfferChat(offerID, chatID); err != nil {
+			log.Printf("Error linking offer %d to chat %d: %v", offerID, chatID, err)
+		}
+	}
+}
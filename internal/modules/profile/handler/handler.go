@@ -3,10 +3,11 @@ package handler
 import (
 	"FurniSwap/internal/modules/profile/model"
 	"FurniSwap/internal/modules/profile/service"
+	"FurniSwap/pkg/storage"
+	"FurniSwap/pkg/utils"
+	"errors"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -32,6 +33,9 @@ func (h *Handler) RegisterRoutes(apiRouter *gin.RouterGroup) {
 		profile.PUT("", h.UpdateProfile)
 		profile.POST("/avatar", h.UploadAvatar)
 		profile.POST("/avatar/url", h.SetAvatarURL)
+		profile.PUT("/password", h.ChangePassword)
+		profile.GET("/sessions", h.GetSessions)
+		profile.DELETE("/sessions/:sessionId", h.RevokeSession)
 	}
 
 	// Add a public endpoint for getting user avatars
@@ -80,8 +84,11 @@ func (h *Handler) UpdateProfile(c *gin.Context) {
 	// Update profile
 	err := h.service.UpdateProfile(userID.(int), req)
 	if err != nil {
-		log.Printf("Error updating profile: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating profile"})
+		if err.Error() == "latitude and longitude must be set together" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Latitude and longitude must be set together"})
+			return
+		}
+		h.handleAvatarError(c, err, "Error updating profile")
 		return
 	}
 
@@ -112,18 +119,10 @@ func (h *Handler) UploadAvatar(c *gin.Context) {
 		return
 	}
 
-	// Check file type
-	contentType := file.Header.Get("Content-Type")
-	if contentType != "image/jpeg" && contentType != "image/png" && contentType != "image/gif" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File must be an image (JPEG, PNG, or GIF)"})
-		return
-	}
-
-	// Upload avatar
+	// Upload avatar; its type is verified by content, not by the client Content-Type
 	err = h.service.UploadAvatar(userID.(int), file)
 	if err != nil {
-		log.Printf("Error uploading avatar: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error uploading avatar"})
+		h.handleAvatarError(c, err, "Error uploading avatar")
 		return
 	}
 
@@ -161,23 +160,30 @@ func (h *Handler) GetUserAvatar(c *gin.Context) {
 		return
 	}
 
-	// Добавляем заголовок для кеширования на 1 день (86400 секунд)
-	c.Header("Cache-Control", "public, max-age=86400")
-
-	// If the avatar is a URL, redirect to it
+	// Legacy URL avatars are not loaded from third-party hosts; they are served once
+	// they have been copied to the storage
 	if h.service.IsAvatarURL(avatarPath) {
-		c.Redirect(http.StatusTemporaryRedirect, avatarPath)
+		c.JSON(http.StatusNotFound, gin.H{"error": "User has no avatar"})
 		return
 	}
 
-	// Otherwise, serve the file
-	fullPath := filepath.Join("uploads", avatarPath)
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Avatar file not found"})
+	// Добавляем заголовок для кеширования на 1 день (86400 секунд)
+	c.Header("Cache-Control", "public, max-age=86400")
+
+	// Serve the file from the storage
+	file, err := storage.Default.Open(avatarPath)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Avatar file not found"})
+			return
+		}
+		log.Printf("Error opening avatar: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting avatar"})
 		return
 	}
+	defer file.Close()
 
-	c.File(fullPath)
+	c.DataFromReader(http.StatusOK, file.Size, file.ContentType, file, nil)
 }
 
 // SetAvatarURL sets a URL as the user's avatar
@@ -201,8 +207,7 @@ func (h *Handler) SetAvatarURL(c *gin.Context) {
 	// Set avatar URL
 	err := h.service.SetAvatarURL(userID.(int), req.URL)
 	if err != nil {
-		log.Printf("Error setting avatar URL: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error setting avatar URL"})
+		h.handleAvatarError(c, err, "Error setting avatar URL")
 		return
 	}
 
@@ -216,3 +221,99 @@ func (h *Handler) SetAvatarURL(c *gin.Context) {
 
 	c.JSON(http.StatusOK, profile)
 }
+
+// ChangePassword handles changing the user's password
+func (h *Handler) ChangePassword(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req model.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	err := h.service.ChangePassword(userID.(int), c.GetInt("sessionID"), req)
+	if err != nil {
+		if err.Error() == "invalid current password" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid current password"})
+			return
+		}
+		log.Printf("Error changing password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error changing password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully; other sessions have been logged out"})
+}
+
+// GetSessions handles listing the user's active sessions
+func (h *Handler) GetSessions(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessions, err := h.service.GetSessions(userID.(int), c.GetInt("sessionID"))
+	if err != nil {
+		log.Printf("Error getting sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession handles ending one of the user's sessions
+func (h *Handler) RevokeSession(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	sessionID, err := strconv.Atoi(c.Param("sessionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	err = h.service.RevokeSession(userID.(int), sessionID)
+	if err != nil {
+		if err.Error() == "session not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		log.Printf("Error revoking session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// handleAvatarError maps avatar upload and download errors to HTTP responses
+func (h *Handler) handleAvatarError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, utils.ErrInvalidImage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Avatar must be an image (JPEG, PNG, GIF or WebP)"})
+	case errors.Is(err, utils.ErrImageTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is too large"})
+	case errors.Is(err, utils.ErrInvalidImageURL):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image URL, must start with http:// or https://"})
+	case errors.Is(err, utils.ErrImageURLBlocked):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image URL must point to a public host"})
+	case errors.Is(err, utils.ErrImageDownload):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Could not download the image"})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
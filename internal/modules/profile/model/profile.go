@@ -10,6 +10,8 @@ type Profile struct {
 	LastName  string    `db:"last_name" json:"last_name"`
 	City      string    `db:"city" json:"city"`
 	Avatar    string    `db:"avatar" json:"avatar"`
+	Latitude  *float64  `db:"latitude" json:"latitude"`
+	Longitude *float64  `db:"longitude" json:"longitude"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
 }
 
@@ -19,6 +21,16 @@ type UpdateProfileRequest struct {
 	LastName string `json:"last_name"`
 	City     string `json:"city"`
 	Avatar   string `json:"avatar"`
+
+	// Optional coordinates; when omitted they are taken from the city
+	Latitude  *float64 `json:"latitude" binding:"omitempty,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" binding:"omitempty,min=-180,max=180"`
+}
+
+// ChangePasswordRequest represents the data needed to change the user's password
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
 }
 
 // PublicProfile is a subset of profile information for public viewing
@@ -30,3 +42,21 @@ type PublicProfile struct {
 	Avatar    string    `json:"avatar"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// Session represents an active login session of the user
+type Session struct {
+	ID         int       `db:"id" json:"id"`
+	UserAgent  string    `db:"user_agent" json:"user_agent"`
+	IPAddress  string    `db:"ip_address" json:"ip_address"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	LastSeenAt time.Time `db:"last_used_at" json:"last_seen_at"`
+	ExpiresAt  time.Time `db:"expires_at" json:"expires_at"`
+	Current    bool      `db:"-" json:"current"` // Whether the request was made from this session
+}
+
+// URLAvatar is an avatar that is still an external URL, set before URL avatars were
+// downloaded and stored
+type URLAvatar struct {
+	UserID int    `db:"id"`
+	URL    string `db:"avatar"`
+}
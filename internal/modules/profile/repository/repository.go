@@ -2,6 +2,7 @@ package repository
 
 import (
 	"FurniSwap/internal/modules/profile/model"
+	"FurniSwap/pkg/geo"
 	"fmt"
 	"log"
 
@@ -24,7 +25,7 @@ func NewRepository(db *sqlx.DB) *Repository {
 func (r *Repository) GetProfileByID(userID int) (*model.Profile, error) {
 	var profile model.Profile
 	err := r.db.Get(&profile, `
-		SELECT id, email, name, last_name, city, avatar, created_at
+		SELECT id, email, name, last_name, city, avatar, latitude, longitude, created_at
 		FROM users 
 		WHERE id = $1
 	`, userID)
@@ -36,14 +37,19 @@ func (r *Repository) GetProfileByID(userID int) (*model.Profile, error) {
 }
 
 // UpdateProfile updates a user profile
-func (r *Repository) UpdateProfile(userID int, req model.UpdateProfileRequest) error {
+func (r *Repository) UpdateProfile(userID int, req model.UpdateProfileRequest, location *geo.Location) error {
+	var latitude, longitude *float64
+	if location != nil {
+		latitude, longitude = &location.Latitude, &location.Longitude
+	}
+
 	// Если задано поле аватара, добавляем его в обновление
 	if req.Avatar != "" {
 		_, err := r.db.Exec(`
 			UPDATE users 
-			SET name = $1, last_name = $2, city = $3, avatar = $4
-			WHERE id = $5
-		`, req.Name, req.LastName, req.City, req.Avatar, userID)
+			SET name = $1, last_name = $2, city = $3, avatar = $4, latitude = $5, longitude = $6
+			WHERE id = $7
+		`, req.Name, req.LastName, req.City, req.Avatar, latitude, longitude, userID)
 		if err != nil {
 			log.Printf("Error updating profile with avatar: %v", err)
 			return fmt.Errorf("error updating profile: %w", err)
@@ -52,9 +58,9 @@ func (r *Repository) UpdateProfile(userID int, req model.UpdateProfileRequest) e
 		// Обновление без изменения аватара
 		_, err := r.db.Exec(`
 			UPDATE users 
-			SET name = $1, last_name = $2, city = $3
-			WHERE id = $4
-		`, req.Name, req.LastName, req.City, userID)
+			SET name = $1, last_name = $2, city = $3, latitude = $4, longitude = $5
+			WHERE id = $6
+		`, req.Name, req.LastName, req.City, latitude, longitude, userID)
 		if err != nil {
 			log.Printf("Error updating profile: %v", err)
 			return fmt.Errorf("error updating profile: %w", err)
@@ -63,6 +69,11 @@ func (r *Repository) UpdateProfile(userID int, req model.UpdateProfileRequest) e
 	return nil
 }
 
+// GetCityLocation gets the coordinates of a city, or nil if the city is unknown
+func (r *Repository) GetCityLocation(city string) (*geo.Location, error) {
+	return geo.CityLocation(r.db, city)
+}
+
 // UpdateAvatar updates a user's avatar
 func (r *Repository) UpdateAvatar(userID int, avatarPath string) error {
 	_, err := r.db.Exec(`
@@ -87,3 +98,109 @@ func (r *Repository) GetAvatarPath(userID int) (string, error) {
 	}
 	return avatarPath, nil
 }
+
+// GetURLAvatars gets the avatars that are still external URLs
+func (r *Repository) GetURLAvatars() ([]model.URLAvatar, error) {
+	avatars := []model.URLAvatar{}
+	err := r.db.Select(&avatars, `
+		SELECT id, avatar FROM users
+		WHERE avatar LIKE 'http://%' OR avatar LIKE 'https://%'
+		ORDER BY id
+	`)
+	if err != nil {
+		log.Printf("Error getting URL avatars: %v", err)
+		return nil, fmt.Errorf("error getting URL avatars: %w", err)
+	}
+	return avatars, nil
+}
+
+// ReplaceURLAvatar replaces a user's URL avatar with a stored one, unless the user has
+// changed the avatar in the meantime; it reports whether the avatar was replaced
+func (r *Repository) ReplaceURLAvatar(userID int, url, avatarPath string) (bool, error) {
+	result, err := r.db.Exec("UPDATE users SET avatar = $1 WHERE id = $2 AND avatar = $3", avatarPath, userID, url)
+	if err != nil {
+		log.Printf("Error replacing URL avatar: %v", err)
+		return false, fmt.Errorf("error replacing URL avatar: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error replacing URL avatar: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// GetPasswordHash gets the password hash of a user
+func (r *Repository) GetPasswordHash(userID int) (string, error) {
+	var passwordHash string
+	err := r.db.Get(&passwordHash, "SELECT password_hash FROM users WHERE id = $1", userID)
+	if err != nil {
+		log.Printf("Error getting password hash: %v", err)
+		return "", fmt.Errorf("error getting password hash: %w", err)
+	}
+	return passwordHash, nil
+}
+
+// UpdatePassword sets a new password hash for a user and revokes all of the user's
+// sessions except the given one
+func (r *Repository) UpdatePassword(userID int, passwordHash string, keepSessionID int) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return fmt.Errorf("error updating password: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE users SET password_hash = $1 WHERE id = $2", passwordHash, userID)
+	if err != nil {
+		log.Printf("Error updating password: %v", err)
+		return fmt.Errorf("error updating password: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+	`, userID, keepSessionID)
+	if err != nil {
+		log.Printf("Error revoking other sessions: %v", err)
+		return fmt.Errorf("error updating password: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return fmt.Errorf("error updating password: %w", err)
+	}
+	return nil
+}
+
+// GetSessions gets the active sessions of a user, most recently used first
+func (r *Repository) GetSessions(userID int) ([]model.Session, error) {
+	sessions := []model.Session{}
+	err := r.db.Select(&sessions, `
+		SELECT id, user_agent, ip_address, created_at, last_used_at, expires_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		log.Printf("Error getting sessions: %v", err)
+		return nil, fmt.Errorf("error getting sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession revokes an active session of a user, reporting whether it was found
+func (r *Repository) RevokeSession(userID, sessionID int) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+	`, sessionID, userID)
+	if err != nil {
+		log.Printf("Error revoking session: %v", err)
+		return false, fmt.Errorf("error revoking session: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error revoking session: %w", err)
+	}
+	return rows > 0, nil
+}
@@ -3,15 +3,23 @@ package service
 import (
 	"FurniSwap/internal/modules/profile/model"
 	"FurniSwap/internal/modules/profile/repository"
+	"FurniSwap/pkg/geo"
 	"FurniSwap/pkg/utils"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"mime/multipart"
-	"net/http"
-	"strings"
-	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
+// avatarRendition is the name of the single stored size of an avatar
+const avatarRendition = "avatar"
+
+// avatarRenditions are the sizes avatars are stored in
+var avatarRenditions = []utils.Rendition{{Name: avatarRendition, MaxSize: 512}}
+
 // Service provides profile operations
 type Service struct {
 	repo *repository.Repository
@@ -31,185 +39,216 @@ func (s *Service) GetProfile(userID int) (*model.Profile, error) {
 
 // UpdateProfile updates a user profile
 func (s *Service) UpdateProfile(userID int, req model.UpdateProfileRequest) error {
-	// Если задан URL аватара, проверяем его и обрабатываем
-	if req.Avatar != "" && (strings.HasPrefix(req.Avatar, "http://") || strings.HasPrefix(req.Avatar, "https://")) {
-		log.Printf("Updating profile with avatar URL: %s", req.Avatar)
-
-		// Проверяем URL аватара по тем же правилам, что и в SetAvatarURL
-		// Базовая проверка на URL уже пройдена
-		urlLower := strings.ToLower(req.Avatar)
-		isImageByExtension := strings.HasSuffix(urlLower, ".jpg") ||
-			strings.HasSuffix(urlLower, ".jpeg") ||
-			strings.HasSuffix(urlLower, ".png") ||
-			strings.HasSuffix(urlLower, ".gif") ||
-			strings.HasSuffix(urlLower, ".webp") ||
-			strings.HasSuffix(urlLower, ".bmp") ||
-			strings.HasSuffix(urlLower, ".svg")
-
-		// Если это не явно изображение по расширению, проверяем URL мягко (без возврата ошибки)
-		if !isImageByExtension {
-			client := &http.Client{
-				Timeout: 10 * time.Second,
-				CheckRedirect: func(req *http.Request, via []*http.Request) error {
-					if len(via) >= 10 {
-						return fmt.Errorf("слишком много редиректов")
-					}
-					return nil
-				},
-			}
-
-			req, err := http.NewRequest("HEAD", req.Avatar, nil)
-			if err == nil {
-				req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
-
-				resp, err := client.Do(req)
-				if err == nil {
-					defer resp.Body.Close()
-
-					contentType := resp.Header.Get("Content-Type")
-					if contentType != "" && !strings.Contains(contentType, "image/") {
-						log.Printf("Warning: Avatar URL не имеет Content-Type изображения: %s", contentType)
-					}
-				}
-			}
+	// An avatar URL is downloaded and stored like an uploaded avatar; stored avatar
+	// paths can't be set directly, so any other value leaves the avatar unchanged
+	var previousAvatar string
+	if utils.IsExternalURL(req.Avatar) {
+		avatarPath, err := s.fetchAvatar(req.Avatar)
+		if err != nil {
+			return err
 		}
+		previousAvatar = s.currentAvatar(userID)
+		req.Avatar = avatarPath
+	} else {
+		req.Avatar = ""
+	}
 
-		// Удаляем старый локальный аватар, если он был
-		currentAvatar, err := s.repo.GetAvatarPath(userID)
-		if err == nil && currentAvatar != "" && !s.IsAvatarURL(currentAvatar) {
-			_ = utils.DeleteFile(currentAvatar)
+	location, err := s.resolveLocation(userID, req)
+	if err == nil {
+		// Обновляем профиль
+		err = s.repo.UpdateProfile(userID, req, location)
+	}
+	if err != nil {
+		if req.Avatar != "" {
+			_ = utils.DeleteFile(req.Avatar)
 		}
+		return err
 	}
 
-	// Обновляем профиль
-	return s.repo.UpdateProfile(userID, req)
+	s.deleteAvatarFile(previousAvatar)
+	return nil
+}
+
+// resolveLocation returns the given coordinates, the current ones if the city is
+// unchanged, or the coordinates of the new city
+func (s *Service) resolveLocation(userID int, req model.UpdateProfileRequest) (*geo.Location, error) {
+	location, err := geo.NewLocation(req.Latitude, req.Longitude)
+	if err != nil || location != nil {
+		return location, err
+	}
+
+	current, err := s.repo.GetProfileByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if current.City == req.City {
+		return geo.NewLocation(current.Latitude, current.Longitude)
+	}
+
+	return s.repo.GetCityLocation(req.City)
 }
 
 // UploadAvatar uploads a user avatar
 func (s *Service) UploadAvatar(userID int, file *multipart.FileHeader) error {
-	// Check if user has an existing avatar
-	currentAvatar, err := s.repo.GetAvatarPath(userID)
+	// Verify the image and store it without metadata
+	paths, err := utils.ProcessImage(file, "avatars", avatarRenditions)
 	if err != nil {
-		log.Printf("Error getting current avatar: %v", err)
+		return fmt.Errorf("error uploading avatar: %w", err)
 	}
 
-	// If there's an existing avatar and it's not a URL, delete it
-	if currentAvatar != "" && !s.IsAvatarURL(currentAvatar) {
-		err = utils.DeleteFile(currentAvatar)
-		if err != nil {
-			log.Printf("Error deleting old avatar: %v", err)
-		}
+	return s.replaceAvatar(userID, paths[avatarRendition])
+}
+
+// SetAvatarURL downloads an image from a URL and sets it as the user's avatar
+func (s *Service) SetAvatarURL(userID int, url string) error {
+	avatarPath, err := s.fetchAvatar(url)
+	if err != nil {
+		return err
 	}
 
-	// Upload the new avatar
-	avatarPath, err := utils.UploadFile(file, "avatars")
+	return s.replaceAvatar(userID, avatarPath)
+}
+
+// fetchAvatar downloads an avatar image and stores a copy, returning its path
+func (s *Service) fetchAvatar(url string) (string, error) {
+	data, err := utils.FetchImage(url)
 	if err != nil {
-		return fmt.Errorf("error uploading avatar: %w", err)
+		return "", err
+	}
+
+	paths, err := utils.ProcessImageData(data, "avatars", avatarRenditions)
+	if err != nil {
+		return "", fmt.Errorf("error storing avatar: %w", err)
 	}
 
+	return paths[avatarRendition], nil
+}
+
+// replaceAvatar sets a stored image as the user's avatar, deleting the previous one
+func (s *Service) replaceAvatar(userID int, avatarPath string) error {
+	previousAvatar := s.currentAvatar(userID)
+
 	// Update the user's avatar in the database
-	err = s.repo.UpdateAvatar(userID, avatarPath)
+	err := s.repo.UpdateAvatar(userID, avatarPath)
 	if err != nil {
-		// If there's an error updating the database, try to clean up the uploaded file
+		// If there's an error updating the database, try to clean up the stored file
 		_ = utils.DeleteFile(avatarPath)
 		return fmt.Errorf("error updating avatar in database: %w", err)
 	}
 
+	s.deleteAvatarFile(previousAvatar)
 	return nil
 }
 
-// SetAvatarURL sets a URL as the user's avatar
-func (s *Service) SetAvatarURL(userID int, url string) error {
-	// Basic validation of the URL
-	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
-		return fmt.Errorf("invalid image URL, must start with http:// or https://")
-	}
-
-	// Проверка на наличие расширения файла в URL, характерного для изображений
-	urlLower := strings.ToLower(url)
-	isImageByExtension := strings.HasSuffix(urlLower, ".jpg") ||
-		strings.HasSuffix(urlLower, ".jpeg") ||
-		strings.HasSuffix(urlLower, ".png") ||
-		strings.HasSuffix(urlLower, ".gif") ||
-		strings.HasSuffix(urlLower, ".webp") ||
-		strings.HasSuffix(urlLower, ".bmp") ||
-		strings.HasSuffix(urlLower, ".svg")
-
-	// Если URL явно указывает на изображение по расширению, пропускаем проверку HEAD запросом
-	if !isImageByExtension {
-		// Проверка URL с помощью HEAD-запроса
-		client := &http.Client{
-			Timeout: 10 * time.Second,
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				// Разрешаем до 10 редиректов
-				if len(via) >= 10 {
-					return fmt.Errorf("слишком много редиректов")
-				}
-				return nil
-			},
-		}
+// currentAvatar gets the user's avatar path, logging errors
+func (s *Service) currentAvatar(userID int) string {
+	avatarPath, err := s.repo.GetAvatarPath(userID)
+	if err != nil {
+		log.Printf("Error getting current avatar: %v", err)
+	}
+	return avatarPath
+}
 
-		req, err := http.NewRequest("HEAD", url, nil)
-		if err != nil {
-			return fmt.Errorf("error creating request: %w", err)
+// deleteAvatarFile deletes the stored file of an avatar; legacy URL avatars have none
+func (s *Service) deleteAvatarFile(avatarPath string) {
+	if avatarPath != "" && !s.IsAvatarURL(avatarPath) {
+		if err := utils.DeleteFile(avatarPath); err != nil {
+			log.Printf("Error deleting old avatar: %v", err)
 		}
+	}
+}
 
-		// Добавление User-Agent для обхода блокировок
-		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+// IsAvatarURL checks if the avatar path is a URL; avatars set before URLs were
+// downloaded are one until MirrorURLAvatars has copied them
+func (s *Service) IsAvatarURL(avatarPath string) bool {
+	return utils.IsExternalURL(avatarPath)
+}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Printf("Error checking URL %s: %v", url, err)
-			// Если не смогли проверить URL, все равно попробуем его использовать
-			// потому что многие сайты блокируют HEAD-запросы
-		} else {
-			defer resp.Body.Close()
-
-			// Проверяем статус-код
-			if resp.StatusCode >= 400 {
-				return fmt.Errorf("URL недоступен, статус: %d", resp.StatusCode)
-			}
-
-			// Проверяем Content-Type только если он есть
-			contentType := resp.Header.Get("Content-Type")
-			if contentType != "" && !strings.Contains(contentType, "image/") {
-				log.Printf("Warning: URL не имеет Content-Type изображения: %s, но попробуем использовать", contentType)
-				// Не возвращаем ошибку, т.к. контент может быть изображением, несмотря на неправильный Content-Type
-			}
+// MirrorURLAvatars downloads the avatars that are still external URLs and stores them
+// like uploaded ones, so they are no longer loaded from third-party hosts. Avatars whose
+// URL is blocked or isn't an image are removed; ones that fail to download are retried
+// on the next start.
+func (s *Service) MirrorURLAvatars(ctx context.Context) {
+	avatars, err := s.repo.GetURLAvatars()
+	if err != nil {
+		return
+	}
+
+	for _, avatar := range avatars {
+		if ctx.Err() != nil {
+			return
 		}
+		s.mirrorURLAvatar(avatar)
 	}
+}
 
-	// Check if user has an existing avatar
-	currentAvatar, err := s.repo.GetAvatarPath(userID)
+// mirrorURLAvatar replaces a URL avatar with a stored copy, or removes it if it can't be copied
+func (s *Service) mirrorURLAvatar(avatar model.URLAvatar) {
+	avatarPath, err := s.fetchAvatar(avatar.URL)
 	if err != nil {
-		log.Printf("Error getting current avatar: %v", err)
+		log.Printf("Error copying avatar of user %d from %s: %v", avatar.UserID, avatar.URL, err)
+		if !utils.IsPermanentImageError(err) {
+			return
+		}
+		avatarPath = ""
 	}
 
-	// If there's an existing avatar and it's not a URL, delete it
-	if currentAvatar != "" && !s.IsAvatarURL(currentAvatar) {
-		err = utils.DeleteFile(currentAvatar)
-		if err != nil {
-			log.Printf("Error deleting old avatar: %v", err)
-		}
+	replaced, err := s.repo.ReplaceURLAvatar(avatar.UserID, avatar.URL, avatarPath)
+	if (err != nil || !replaced) && avatarPath != "" {
+		_ = utils.DeleteFile(avatarPath)
 	}
+}
 
-	// Update the user's avatar in the database
-	err = s.repo.UpdateAvatar(userID, url)
+// GetAvatarPath gets the avatar path for a user
+func (s *Service) GetAvatarPath(userID int) (string, error) {
+	return s.repo.GetAvatarPath(userID)
+}
+
+// ChangePassword changes the user's password after checking the current one and ends
+// all other sessions of the user
+func (s *Service) ChangePassword(userID, currentSessionID int, req model.ChangePasswordRequest) error {
+	passwordHash, err := s.repo.GetPasswordHash(userID)
 	if err != nil {
-		return fmt.Errorf("error updating avatar URL in database: %w", err)
+		return err
 	}
 
-	return nil
+	err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.OldPassword))
+	if err != nil {
+		return errors.New("invalid current password")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("error hashing password: %w", err)
+	}
+
+	return s.repo.UpdatePassword(userID, string(hashedPassword), currentSessionID)
 }
 
-// IsAvatarURL checks if the avatar path is a URL
-func (s *Service) IsAvatarURL(avatarPath string) bool {
-	return strings.HasPrefix(avatarPath, "http://") || strings.HasPrefix(avatarPath, "https://")
+// GetSessions gets the active sessions of a user, marking the one in use
+func (s *Service) GetSessions(userID, currentSessionID int) ([]model.Session, error) {
+	sessions, err := s.repo.GetSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
 }
 
-// GetAvatarPath gets the avatar path for a user
-func (s *Service) GetAvatarPath(userID int) (string, error) {
-	return s.repo.GetAvatarPath(userID)
+// RevokeSession ends one of the user's sessions; its tokens stop working immediately
+func (s *Service) RevokeSession(userID, sessionID int) error {
+	revoked, err := s.repo.RevokeSession(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return errors.New("session not found")
+	}
+	return nil
 }
 
 // GetPublicProfile converts a profile to a public profile
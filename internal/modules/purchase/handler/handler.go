@@ -26,6 +26,12 @@ func NewHandler(service *service.Service) *Handler {
 func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
 	router.POST("/listings/:id/buy", h.BuyListing)
 	router.GET("/purchases", h.GetUserPurchases)
+	router.GET("/purchases/:id", h.GetPurchase)
+	router.POST("/purchases/:id/confirm", h.changeStatus(model.StatusConfirmed))
+	router.POST("/purchases/:id/hand-over", h.changeStatus(model.StatusHandedOver))
+	router.POST("/purchases/:id/complete", h.changeStatus(model.StatusCompleted))
+	router.POST("/purchases/:id/cancel", h.changeStatus(model.StatusCancelled))
+	router.POST("/purchases/:id/dispute", h.changeStatus(model.StatusDisputed))
 	router.GET("/sales", h.GetUserSales)
 }
 
@@ -45,9 +51,21 @@ func (h *Handler) BuyListing(c *gin.Context) {
 		return
 	}
 
+	// Parse optional request body with an accepted offer to buy at
+	var body struct {
+		OfferID *int `json:"offer_id"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+			return
+		}
+	}
+
 	// Create buy request
 	buyReq := model.BuyRequest{
 		ListingID: listingID,
+		OfferID:   body.OfferID,
 	}
 
 	// Buy listing
@@ -57,14 +75,22 @@ func (h *Handler) BuyListing(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Listing not found"})
 			return
 		}
-		if err.Error() == "listing is not available for purchase" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Listing is not available for purchase"})
+		if err.Error() == "listing is already sold" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Listing is already sold"})
 			return
 		}
 		if err.Error() == "you cannot buy your own listing" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot buy your own listing"})
 			return
 		}
+		if err.Error() == "offer not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Offer not found"})
+			return
+		}
+		if err.Error() == "offer is not accepted for this listing" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Offer is not accepted for this listing"})
+			return
+		}
 		log.Printf("Error buying listing: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error buying listing"})
 		return
@@ -142,3 +168,92 @@ func (h *Handler) GetUserSales(c *gin.Context) {
 
 	c.JSON(http.StatusOK, sales)
 }
+
+// GetPurchase handles getting a single purchase for its buyer or seller
+func (h *Handler) GetPurchase(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse purchase ID
+	purchaseID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase ID"})
+		return
+	}
+
+	purchase, err := h.service.GetPurchase(purchaseID, userID.(int))
+	if err != nil {
+		if err.Error() == "purchase not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Purchase not found"})
+			return
+		}
+		if err.Error() == "you don't have access to this purchase" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this purchase"})
+			return
+		}
+		log.Printf("Error getting purchase: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting purchase"})
+		return
+	}
+
+	c.JSON(http.StatusOK, purchase)
+}
+
+// changeStatus returns a handler moving a purchase to the given status
+func (h *Handler) changeStatus(toStatus string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get user ID from context (set by auth middleware)
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		// Parse purchase ID
+		purchaseID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchase ID"})
+			return
+		}
+
+		// Parse optional comment (e.g. cancellation or dispute reason)
+		var req model.StatusChangeRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+				return
+			}
+		}
+
+		err = h.service.ChangeStatus(purchaseID, userID.(int), toStatus, req.Comment)
+		if err != nil {
+			switch err.Error() {
+			case "purchase not found":
+				c.JSON(http.StatusNotFound, gin.H{"error": "Purchase not found"})
+			case "you don't have access to this purchase":
+				c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this purchase"})
+			case "you are not allowed to perform this action":
+				c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to perform this action"})
+			case "status transition is not allowed":
+				c.JSON(http.StatusConflict, gin.H{"error": "Status transition is not allowed"})
+			default:
+				log.Printf("Error changing purchase status: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error changing purchase status"})
+			}
+			return
+		}
+
+		purchase, err := h.service.GetPurchase(purchaseID, userID.(int))
+		if err != nil {
+			log.Printf("Error getting purchase: %v", err)
+			c.JSON(http.StatusOK, gin.H{"id": purchaseID, "status": toStatus})
+			return
+		}
+
+		c.JSON(http.StatusOK, purchase)
+	}
+}
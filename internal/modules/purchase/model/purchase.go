@@ -5,19 +5,38 @@ import (
 	"time"
 )
 
+// Purchase (order) statuses
+const (
+	StatusRequested  = "requested"
+	StatusConfirmed  = "confirmed"
+	StatusHandedOver = "handed_over"
+	StatusCompleted  = "completed"
+	StatusCancelled  = "cancelled"
+	StatusDisputed   = "disputed"
+)
+
 // Purchase represents a purchase transaction
 type Purchase struct {
-	ID         int            `db:"id" json:"id"`
-	UserID     int            `db:"user_id" json:"user_id"`
-	ListingID  int            `db:"listing_id" json:"listing_id"`
-	SellerID   int            `db:"seller_id" json:"seller_id"`
-	Price      float64        `db:"price" json:"price"`
-	Status     string         `db:"status" json:"status"`
-	CreatedAt  time.Time      `db:"created_at" json:"created_at"`
-	UpdatedAt  time.Time      `db:"updated_at" json:"updated_at"`
-	Listing    *model.Listing `json:"listing,omitempty"`
-	SellerName string         `db:"seller_name" json:"seller_name,omitempty"`
-	BuyerName  string         `db:"buyer_name" json:"buyer_name,omitempty"`
+	ID           int            `db:"id" json:"id"`
+	UserID       int            `db:"user_id" json:"user_id"`
+	ListingID    int            `db:"listing_id" json:"listing_id"`
+	SellerID     int            `db:"seller_id" json:"seller_id"`
+	Price        float64        `db:"price" json:"price"`
+	Status       string         `db:"status" json:"status"`
+	Kind         string         `db:"kind" json:"kind"`
+	SwapID       *int           `db:"swap_id" json:"swap_id,omitempty"`
+	OfferID      *int           `db:"offer_id" json:"offer_id,omitempty"`
+	Comment      string         `db:"status_comment" json:"status_comment,omitempty"`
+	CreatedAt    time.Time      `db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time      `db:"updated_at" json:"updated_at"`
+	ConfirmedAt  *time.Time     `db:"confirmed_at" json:"confirmed_at,omitempty"`
+	HandedOverAt *time.Time     `db:"handed_over_at" json:"handed_over_at,omitempty"`
+	CompletedAt  *time.Time     `db:"completed_at" json:"completed_at,omitempty"`
+	CancelledAt  *time.Time     `db:"cancelled_at" json:"cancelled_at,omitempty"`
+	DisputedAt   *time.Time     `db:"disputed_at" json:"disputed_at,omitempty"`
+	Listing      *model.Listing `json:"listing,omitempty"`
+	SellerName   string         `db:"seller_name" json:"seller_name,omitempty"`
+	BuyerName    string         `db:"buyer_name" json:"buyer_name,omitempty"`
 }
 
 // PurchaseResponse represents a list of purchases with pagination
@@ -30,5 +49,11 @@ type PurchaseResponse struct {
 
 // BuyRequest represents the data needed to buy a listing
 type BuyRequest struct {
-	ListingID int `json:"listing_id" binding:"required"`
+	ListingID int  `json:"listing_id" binding:"required"`
+	OfferID   *int `json:"offer_id"` // accepted price offer to buy at, if any
+}
+
+// StatusChangeRequest represents an optional comment for an order status change
+type StatusChangeRequest struct {
+	Comment string `json:"comment"`
 }
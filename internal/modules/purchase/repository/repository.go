@@ -2,12 +2,24 @@ package repository
 
 import (
 	"FurniSwap/internal/modules/purchase/model"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"math"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Errors returned by CreatePurchase
+var (
+	ErrListingNotFound    = errors.New("listing not found")
+	ErrListingAlreadySold = errors.New("listing is already sold")
+	ErrOwnListing         = errors.New("you cannot buy your own listing")
+	ErrOfferNotFound      = errors.New("offer not found")
+	ErrOfferNotAccepted   = errors.New("offer is not accepted for this listing")
 )
 
 // Repository handles database operations for the purchase module
@@ -22,20 +34,112 @@ func NewRepository(db *sqlx.DB) *Repository {
 	}
 }
 
-// CreatePurchase creates a new purchase
-func (r *Repository) CreatePurchase(userID, listingID, sellerID int, price float64) (int, error) {
+// statusTimestampColumns maps order statuses to the column recording when they were reached
+var statusTimestampColumns = map[string]string{
+	model.StatusConfirmed:  "confirmed_at",
+	model.StatusHandedOver: "handed_over_at",
+	model.StatusCompleted:  "completed_at",
+	model.StatusCancelled:  "cancelled_at",
+	model.StatusDisputed:   "disputed_at",
+}
+
+// CreatePurchase creates a purchase request for an active listing and reserves
+// the listing in a single transaction. The listing row is locked, so concurrent
+// buyers are serialized and all but the first get ErrListingAlreadySold. If an
+// accepted offer is given, its agreed price is used and the offer is completed.
+func (r *Repository) CreatePurchase(buyerID, listingID int, offerID *int) (int, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		return 0, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the listing
+	var listing struct {
+		UserID int     `db:"user_id"`
+		Price  float64 `db:"price"`
+		Status string  `db:"status"`
+	}
+	err = tx.Get(&listing, "SELECT user_id, price, status FROM listings WHERE id = $1 FOR UPDATE", listingID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrListingNotFound
+		}
+		log.Printf("Error locking listing %d: %v", listingID, err)
+		return 0, fmt.Errorf("error locking listing: %w", err)
+	}
+
+	if listing.UserID == buyerID {
+		return 0, ErrOwnListing
+	}
+
+	if listing.Status != "active" {
+		log.Printf("Listing %d is not available, status: %s", listingID, listing.Status)
+		return 0, ErrListingAlreadySold
+	}
+
+	// Use the agreed price of an accepted offer instead of the list price
+	price := listing.Price
+	if offerID != nil {
+		var offer struct {
+			ListingID int     `db:"listing_id"`
+			BuyerID   int     `db:"buyer_id"`
+			Amount    float64 `db:"amount"`
+			Status    string  `db:"status"`
+		}
+		err = tx.Get(&offer, "SELECT listing_id, buyer_id, amount, status FROM price_offers WHERE id = $1 FOR UPDATE", *offerID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return 0, ErrOfferNotFound
+			}
+			log.Printf("Error locking offer %d: %v", *offerID, err)
+			return 0, fmt.Errorf("error locking offer: %w", err)
+		}
+
+		if offer.ListingID != listingID || offer.BuyerID != buyerID || offer.Status != "accepted" {
+			log.Printf("Offer %d cannot be used by user %d for listing %d, status: %s", *offerID, buyerID, listingID, offer.Status)
+			return 0, ErrOfferNotAccepted
+		}
+
+		_, err = tx.Exec("UPDATE price_offers SET status = 'completed', updated_at = $1 WHERE id = $2", time.Now(), *offerID)
+		if err != nil {
+			log.Printf("Error completing offer %d: %v", *offerID, err)
+			return 0, fmt.Errorf("error completing offer: %w", err)
+		}
+
+		price = offer.Amount
+	}
+
+	// Create purchase record
 	var purchaseID int
-	err := r.db.QueryRow(`
-		INSERT INTO purchases (buyer_id, listing_id, seller_id, price, purchased_at)
-		VALUES ($1, $2, $3, $4, $5)
+	err = tx.QueryRow(`
+		INSERT INTO purchases (buyer_id, listing_id, seller_id, price, offer_id, status, purchased_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
 		RETURNING id
-	`, userID, listingID, sellerID, price, time.Now()).Scan(&purchaseID)
-
+	`, buyerID, listingID, listing.UserID, price, offerID, model.StatusRequested, time.Now()).Scan(&purchaseID)
 	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			// An open purchase for this listing already exists
+			return 0, ErrListingAlreadySold
+		}
 		log.Printf("Error creating purchase: %v", err)
 		return 0, fmt.Errorf("error creating purchase: %w", err)
 	}
 
+	// Reserve the listing until the order is completed or cancelled
+	_, err = tx.Exec("UPDATE listings SET status = 'reserved', updated_at = $1 WHERE id = $2", time.Now(), listingID)
+	if err != nil {
+		log.Printf("Error reserving listing %d: %v", listingID, err)
+		return 0, fmt.Errorf("error reserving listing: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+
 	return purchaseID, nil
 }
 
@@ -43,8 +147,10 @@ func (r *Repository) CreatePurchase(userID, listingID, sellerID int, price float
 func (r *Repository) GetPurchaseByID(purchaseID int) (*model.Purchase, error) {
 	var purchase model.Purchase
 	err := r.db.Get(&purchase, `
-		SELECT p.id, p.buyer_id as user_id, p.listing_id, p.seller_id, p.price, 
-		       p.purchased_at as created_at, p.purchased_at as updated_at, 
+		SELECT p.id, p.buyer_id as user_id, p.listing_id, p.seller_id, p.price, p.kind, p.swap_id, p.offer_id,
+		       p.status, COALESCE(p.status_comment, '') as status_comment,
+		       p.purchased_at as created_at, p.updated_at,
+		       p.confirmed_at, p.handed_over_at, p.completed_at, p.cancelled_at, p.disputed_at,
 			   u1.name || ' ' || COALESCE(u1.last_name, '') as buyer_name,
 			   u2.name || ' ' || COALESCE(u2.last_name, '') as seller_name
 		FROM purchases p
@@ -78,8 +184,10 @@ func (r *Repository) GetUserPurchases(userID, page, limit int) (*model.PurchaseR
 	// Get purchases
 	var purchases []model.Purchase
 	err = r.db.Select(&purchases, `
-		SELECT p.id, p.buyer_id as user_id, p.listing_id, p.seller_id, p.price, 
-		       p.purchased_at as created_at, p.purchased_at as updated_at, 
+		SELECT p.id, p.buyer_id as user_id, p.listing_id, p.seller_id, p.price, p.kind, p.swap_id, p.offer_id,
+		       p.status, COALESCE(p.status_comment, '') as status_comment,
+		       p.purchased_at as created_at, p.updated_at,
+		       p.confirmed_at, p.handed_over_at, p.completed_at, p.cancelled_at, p.disputed_at,
 			   u.name || ' ' || COALESCE(u.last_name, '') as seller_name
 		FROM purchases p
 		JOIN users u ON p.seller_id = u.id
@@ -119,8 +227,10 @@ func (r *Repository) GetUserSales(userID, page, limit int) (*model.PurchaseRespo
 	// Get sales
 	var purchases []model.Purchase
 	err = r.db.Select(&purchases, `
-		SELECT p.id, p.buyer_id as user_id, p.listing_id, p.seller_id, p.price, 
-		       p.purchased_at as created_at, p.purchased_at as updated_at, 
+		SELECT p.id, p.buyer_id as user_id, p.listing_id, p.seller_id, p.price, p.kind, p.swap_id, p.offer_id,
+		       p.status, COALESCE(p.status_comment, '') as status_comment,
+		       p.purchased_at as created_at, p.updated_at,
+		       p.confirmed_at, p.handed_over_at, p.completed_at, p.cancelled_at, p.disputed_at,
 			   u.name || ' ' || COALESCE(u.last_name, '') as buyer_name
 		FROM purchases p
 		JOIN users u ON p.buyer_id = u.id
@@ -140,3 +250,55 @@ func (r *Repository) GetUserSales(userID, page, limit int) (*model.PurchaseRespo
 		TotalPages:  totalPages,
 	}, nil
 }
+
+// UpdatePurchaseStatus moves a purchase from one status to another and keeps the
+// listing status in sync: a cancelled order puts the listing back on sale and a
+// completed order marks it as sold. Returns false if the purchase was not in the
+// expected status anymore.
+func (r *Repository) UpdatePurchaseStatus(purchaseID int, fromStatus, toStatus, comment string) (bool, error) {
+	timestampColumn, ok := statusTimestampColumns[toStatus]
+	if !ok {
+		return false, fmt.Errorf("unknown purchase status: %s", toStatus)
+	}
+
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		return false, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var listingID int
+	err = tx.QueryRow(`
+		UPDATE purchases
+		SET status = $1, `+timestampColumn+` = $2, updated_at = $2,
+		    status_comment = CASE WHEN $3 = '' THEN status_comment ELSE $3 END
+		WHERE id = $4 AND status = $5
+		RETURNING listing_id
+	`, toStatus, time.Now(), comment, purchaseID, fromStatus).Scan(&listingID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("Error updating purchase status: %v", err)
+		return false, fmt.Errorf("error updating purchase status: %w", err)
+	}
+
+	switch toStatus {
+	case model.StatusCancelled:
+		_, err = tx.Exec("UPDATE listings SET status = 'active', updated_at = $1 WHERE id = $2 AND status = 'reserved'", time.Now(), listingID)
+	case model.StatusCompleted:
+		_, err = tx.Exec("UPDATE listings SET status = 'sold', updated_at = $1 WHERE id = $2", time.Now(), listingID)
+	}
+	if err != nil {
+		log.Printf("Error updating listing %d status: %v", listingID, err)
+		return false, fmt.Errorf("error updating listing status: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return false, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return true, nil
+}
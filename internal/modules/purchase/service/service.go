@@ -1,9 +1,6 @@
 package service
 
 import (
-	listingModel "FurniSwap/internal/modules/listing/model"
-	"FurniSwap/internal/modules/listing/repository"
-	listingRepo "FurniSwap/internal/modules/listing/repository"
 	"FurniSwap/internal/modules/purchase/model"
 	purchaseRepo "FurniSwap/internal/modules/purchase/repository"
 	"database/sql"
@@ -14,60 +11,119 @@ import (
 
 // Service provides purchase operations
 type Service struct {
-	repo        *purchaseRepo.Repository
-	listingRepo *listingRepo.Repository
+	repo *purchaseRepo.Repository
 }
 
 // NewService creates a new purchase service
-func NewService(repo *purchaseRepo.Repository, listingRepo *repository.Repository) *Service {
+func NewService(repo *purchaseRepo.Repository) *Service {
 	return &Service{
-		repo:        repo,
-		listingRepo: listingRepo,
+		repo: repo,
 	}
 }
 
-// BuyListing handles purchasing a listing
+// BuyListing handles purchasing a listing. The purchase is created and the
+// listing reserved atomically, so only one of several concurrent buyers succeeds.
 func (s *Service) BuyListing(userID int, req model.BuyRequest) (int, error) {
-	// Get the listing
-	listing, err := s.listingRepo.GetListing(req.ListingID)
+	purchaseID, err := s.repo.CreatePurchase(userID, req.ListingID, req.OfferID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			log.Printf("Listing not found: %d", req.ListingID)
-			return 0, errors.New("listing not found")
+		switch {
+		case errors.Is(err, purchaseRepo.ErrListingNotFound),
+			errors.Is(err, purchaseRepo.ErrListingAlreadySold),
+			errors.Is(err, purchaseRepo.ErrOwnListing),
+			errors.Is(err, purchaseRepo.ErrOfferNotFound),
+			errors.Is(err, purchaseRepo.ErrOfferNotAccepted):
+			return 0, err
 		}
-		log.Printf("Error getting listing %d: %v", req.ListingID, err)
-		return 0, fmt.Errorf("error getting listing: %w", err)
+		log.Printf("Error creating purchase for listing %d: %v", req.ListingID, err)
+		return 0, fmt.Errorf("error creating purchase: %w", err)
 	}
 
-	// Check if listing is available
-	if listing.Status != "active" {
-		log.Printf("Listing %d is not available, status: %s", req.ListingID, listing.Status)
-		return 0, errors.New("listing is not available for purchase")
+	return purchaseID, nil
+}
+
+// Parties allowed to perform an order status transition
+const (
+	roleBuyer  = "buyer"
+	roleSeller = "seller"
+)
+
+// transitions lists the allowed order status transitions and who may perform them
+var transitions = map[string]map[string][]string{
+	model.StatusRequested: {
+		model.StatusConfirmed: {roleSeller},
+		model.StatusCancelled: {roleBuyer, roleSeller},
+	},
+	model.StatusConfirmed: {
+		model.StatusHandedOver: {roleSeller},
+		model.StatusCancelled:  {roleBuyer, roleSeller},
+		model.StatusDisputed:   {roleBuyer, roleSeller},
+	},
+	model.StatusHandedOver: {
+		model.StatusCompleted: {roleBuyer},
+		model.StatusDisputed:  {roleBuyer, roleSeller},
+	},
+	model.StatusDisputed: {
+		model.StatusCompleted: {roleBuyer},
+		model.StatusCancelled: {roleSeller},
+	},
+}
+
+// ChangeStatus moves an order to a new status if the transition is allowed for the user
+func (s *Service) ChangeStatus(purchaseID, userID int, toStatus, comment string) error {
+	purchase, err := s.GetPurchase(purchaseID, userID)
+	if err != nil {
+		return err
 	}
 
-	// Check if user is trying to buy own listing
-	if listing.UserID == userID {
-		return 0, errors.New("you cannot buy your own listing")
+	role := roleBuyer
+	if purchase.SellerID == userID {
+		role = roleSeller
 	}
 
-	// Create purchase record
-	purchaseID, err := s.repo.CreatePurchase(userID, listing.ID, listing.UserID, listing.Price)
-	if err != nil {
-		log.Printf("Error creating purchase for listing %d: %v", req.ListingID, err)
-		return 0, fmt.Errorf("error creating purchase: %w", err)
+	allowedRoles, ok := transitions[purchase.Status][toStatus]
+	if !ok {
+		log.Printf("Purchase %d cannot move from %s to %s", purchaseID, purchase.Status, toStatus)
+		return errors.New("status transition is not allowed")
 	}
 
-	// Update listing status to "sold"
-	updateReq := listingModel.UpdateListingRequest{
-		Status: "sold",
+	permitted := false
+	for _, allowedRole := range allowedRoles {
+		if allowedRole == role {
+			permitted = true
+			break
+		}
+	}
+	if !permitted {
+		return errors.New("you are not allowed to perform this action")
 	}
-	err = s.listingRepo.UpdateListing(listing.ID, listing.UserID, updateReq)
+
+	updated, err := s.repo.UpdatePurchaseStatus(purchaseID, purchase.Status, toStatus, comment)
 	if err != nil {
-		log.Printf("Error updating listing %d status to sold: %v", req.ListingID, err)
-		return 0, fmt.Errorf("error updating listing status: %w", err)
+		return fmt.Errorf("error updating purchase status: %w", err)
+	}
+	if !updated {
+		// The order was changed concurrently
+		return errors.New("status transition is not allowed")
 	}
 
-	return purchaseID, nil
+	return nil
+}
+
+// GetPurchase gets a purchase visible to the user (buyer or seller)
+func (s *Service) GetPurchase(purchaseID, userID int) (*model.Purchase, error) {
+	purchase, err := s.repo.GetPurchaseByID(purchaseID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("purchase not found")
+		}
+		return nil, fmt.Errorf("error getting purchase: %w", err)
+	}
+
+	if purchase.UserID != userID && purchase.SellerID != userID {
+		return nil, errors.New("you don't have access to this purchase")
+	}
+
+	return purchase, nil
 }
 
 // GetPurchaseByID gets a purchase by ID
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"FurniSwap/internal/modules/purchase/model"
+	purchaseRepo "FurniSwap/internal/modules/purchase/repository"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// openTestDB connects to the database from TEST_DATABASE_URL (with the schema
+// from migrations/init.sql applied) or skips the test if it is not set
+func openTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set, skipping database test")
+	}
+
+	db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		t.Fatalf("error connecting to test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// createTestUser inserts a verified user and removes it (with its listings and purchases) after the test
+func createTestUser(t *testing.T, db *sqlx.DB, name string) int {
+	t.Helper()
+
+	var userID int
+	email := fmt.Sprintf("%s-%d@example.com", name, time.Now().UnixNano())
+	err := db.QueryRow(`
+		INSERT INTO users (email, password_hash, name, last_name, city, is_verified)
+		VALUES ($1, 'x', $2, '', 'Москва', true) RETURNING id
+	`, email, name).Scan(&userID)
+	if err != nil {
+		t.Fatalf("error creating test user: %v", err)
+	}
+	t.Cleanup(func() { db.Exec("DELETE FROM users WHERE id = $1", userID) })
+
+	return userID
+}
+
+func TestBuyListingConcurrentBuyers(t *testing.T) {
+	db := openTestDB(t)
+	svc := NewService(purchaseRepo.NewRepository(db))
+
+	sellerID := createTestUser(t, db, "seller")
+
+	var listingID int
+	err := db.QueryRow(`
+		INSERT INTO listings (user_id, title, description, price, condition, city, status)
+		VALUES ($1, 'Диван', 'Тестовый диван', 10000, 'good', 'Москва', 'active') RETURNING id
+	`, sellerID).Scan(&listingID)
+	if err != nil {
+		t.Fatalf("error creating test listing: %v", err)
+	}
+
+	const buyers = 10
+	buyerIDs := make([]int, buyers)
+	for i := range buyerIDs {
+		buyerIDs[i] = createTestUser(t, db, fmt.Sprintf("buyer%d", i))
+	}
+
+	// Fire all buys at the same time
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make([]error, buyers)
+	for i, buyerID := range buyerIDs {
+		wg.Add(1)
+		go func(i, buyerID int) {
+			defer wg.Done()
+			<-start
+			_, errs[i] = svc.BuyListing(buyerID, model.BuyRequest{ListingID: listingID})
+		}(i, buyerID)
+	}
+	close(start)
+	wg.Wait()
+
+	succeeded := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, purchaseRepo.ErrListingAlreadySold):
+		default:
+			t.Errorf("buyer %d: unexpected error: %v", i, err)
+		}
+	}
+
+	if succeeded != 1 {
+		t.Errorf("expected exactly one successful purchase, got %d", succeeded)
+	}
+
+	var purchases int
+	if err := db.Get(&purchases, "SELECT COUNT(*) FROM purchases WHERE listing_id = $1", listingID); err != nil {
+		t.Fatalf("error counting purchases: %v", err)
+	}
+	if purchases != 1 {
+		t.Errorf("expected one purchase record, got %d", purchases)
+	}
+
+	var status string
+	if err := db.Get(&status, "SELECT status FROM listings WHERE id = $1", listingID); err != nil {
+		t.Fatalf("error getting listing status: %v", err)
+	}
+	if status != "reserved" {
+		t.Errorf("expected listing to be reserved, got %q", status)
+	}
+}
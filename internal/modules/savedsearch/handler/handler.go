@@ -0,0 +1,271 @@
+package handler
+
+import (
+	"FurniSwap/internal/modules/savedsearch/model"
+	"FurniSwap/internal/modules/savedsearch/service"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler provides saved search handlers
+type Handler struct {
+	service *service.Service
+}
+
+// NewHandler creates a new saved search handler
+func NewHandler(service *service.Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// RegisterPublicRoutes registers public saved search routes (no auth required)
+func (h *Handler) RegisterPublicRoutes(router *gin.RouterGroup) {
+	router.GET("/saved-searches/unsubscribe", h.ConfirmUnsubscribe)
+	router.POST("/saved-searches/unsubscribe", h.Unsubscribe)
+}
+
+// RegisterRoutes registers saved search routes to router
+func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
+	router.POST("/saved-searches", h.CreateSavedSearch)
+	router.GET("/saved-searches", h.GetSavedSearches)
+	router.GET("/saved-searches/matches", h.GetMatches)
+	router.PUT("/saved-searches/:id", h.UpdateSavedSearch)
+	router.DELETE("/saved-searches/:id", h.DeleteSavedSearch)
+	router.POST("/saved-searches/:id/matches/read", h.MarkMatchesRead)
+}
+
+// CreateSavedSearch handles saving a search
+func (h *Handler) CreateSavedSearch(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse request body
+	var req model.SavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	searchID, err := h.service.CreateSavedSearch(userID.(int), req)
+	if err != nil {
+		h.handleSavedSearchError(c, err, "Error saving search")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": searchID, "message": "Search saved"})
+}
+
+// GetSavedSearches handles getting the user's saved searches
+func (h *Handler) GetSavedSearches(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	searches, err := h.service.GetUserSavedSearches(userID.(int))
+	if err != nil {
+		log.Printf("Error getting saved searches: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting saved searches"})
+		return
+	}
+
+	c.JSON(http.StatusOK, searches)
+}
+
+// UpdateSavedSearch handles updating a saved search
+func (h *Handler) UpdateSavedSearch(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse saved search ID
+	searchID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid saved search ID"})
+		return
+	}
+
+	// Parse request body
+	var req model.SavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	if err = h.service.UpdateSavedSearch(searchID, userID.(int), req); err != nil {
+		h.handleSavedSearchError(c, err, "Error updating saved search")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Saved search updated"})
+}
+
+// DeleteSavedSearch handles deleting a saved search
+func (h *Handler) DeleteSavedSearch(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse saved search ID
+	searchID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid saved search ID"})
+		return
+	}
+
+	if err = h.service.DeleteSavedSearch(searchID, userID.(int)); err != nil {
+		h.handleSavedSearchError(c, err, "Error deleting saved search")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Saved search deleted"})
+}
+
+// GetMatches handles getting new listings that matched the user's saved searches
+func (h *Handler) GetMatches(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+
+	matches, err := h.service.GetUserMatches(userID.(int), c.Query("unread") == "true", page, limit)
+	if err != nil {
+		log.Printf("Error getting saved search matches: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting matches"})
+		return
+	}
+
+	c.JSON(http.StatusOK, matches)
+}
+
+// MarkMatchesRead handles marking the matches of a saved search as read
+func (h *Handler) MarkMatchesRead(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse saved search ID
+	searchID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid saved search ID"})
+		return
+	}
+
+	if err = h.service.MarkMatchesRead(searchID, userID.(int)); err != nil {
+		h.handleSavedSearchError(c, err, "Error marking matches as read")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Matches marked as read"})
+}
+
+// unsubscribePage is the page shown for unsubscribe links from emails
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body>
+<p>{{.Message}}</p>
+{{if .Token}}<form method="post" action="/saved-searches/unsubscribe">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">Unsubscribe</button>
+</form>{{end}}
+</body>
+</html>
+`))
+
+// ConfirmUnsubscribe handles opening an unsubscribe link from an email. It only shows a
+// confirmation form, so link scanners and prefetching mail clients don't unsubscribe.
+func (h *Handler) ConfirmUnsubscribe(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		renderUnsubscribePage(c, http.StatusBadRequest, "Invalid unsubscribe link", "")
+		return
+	}
+
+	renderUnsubscribePage(c, http.StatusOK, "Stop receiving emails for this saved search?", token)
+}
+
+// Unsubscribe handles turning off emails for a saved search. The token comes from the
+// confirmation form or, for one-click unsubscribe by mail clients (RFC 8058), from the
+// query string of the List-Unsubscribe URL.
+func (h *Handler) Unsubscribe(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		token = c.PostForm("token")
+	}
+	if token == "" {
+		renderUnsubscribePage(c, http.StatusBadRequest, "Invalid unsubscribe link", "")
+		return
+	}
+
+	if err := h.service.Unsubscribe(token); err != nil {
+		if err.Error() == "invalid unsubscribe link" {
+			renderUnsubscribePage(c, http.StatusNotFound, "Invalid unsubscribe link", "")
+			return
+		}
+		log.Printf("Error unsubscribing: %v", err)
+		renderUnsubscribePage(c, http.StatusInternalServerError, "Error unsubscribing, please try again later", "")
+		return
+	}
+
+	renderUnsubscribePage(c, http.StatusOK, "You will no longer receive emails for this saved search", "")
+}
+
+// renderUnsubscribePage writes the unsubscribe page with a message and, when a token is
+// given, the confirmation form
+func renderUnsubscribePage(c *gin.Context, status int, message, token string) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	err := unsubscribePage.Execute(c.Writer, gin.H{"Message": message, "Token": token})
+	if err != nil {
+		log.Printf("Error rendering unsubscribe page: %v", err)
+	}
+}
+
+// handleSavedSearchError maps saved search service errors to HTTP responses
+func (h *Handler) handleSavedSearchError(c *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "saved search not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+	case "invalid condition":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid condition"})
+	case "min price cannot be greater than max price":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Min price cannot be greater than max price"})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
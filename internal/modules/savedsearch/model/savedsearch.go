@@ -0,0 +1,65 @@
+package model
+
+import (
+	"time"
+)
+
+// Notification frequencies
+const (
+	FrequencyInstant = "instant"
+	FrequencyDaily   = "daily"
+)
+
+// SavedSearch represents a listing search saved by a user to be notified about new matches
+type SavedSearch struct {
+	ID               int        `db:"id" json:"id"`
+	UserID           int        `db:"user_id" json:"user_id"`
+	Name             string     `db:"name" json:"name"`
+	Keyword          string     `db:"keyword" json:"search"`
+	CategoryID       *int       `db:"category_id" json:"category_id,omitempty"`
+	City             string     `db:"city" json:"city"`
+	Condition        string     `db:"condition" json:"condition"`
+	MinPrice         *float64   `db:"min_price" json:"min_price,omitempty"`
+	MaxPrice         *float64   `db:"max_price" json:"max_price,omitempty"`
+	Frequency        string     `db:"frequency" json:"frequency"`
+	EmailEnabled     bool       `db:"email_enabled" json:"email_enabled"`
+	UnsubscribeToken string     `db:"unsubscribe_token" json:"-"`
+	LastDigestAt     *time.Time `db:"last_digest_at" json:"-"`
+	CreatedAt        time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time  `db:"updated_at" json:"updated_at"`
+	NewMatches       int        `db:"new_matches" json:"new_matches"`
+	UserEmail        string     `db:"user_email" json:"-"`
+}
+
+// Match represents a new listing that matched a saved search (an in-app notification)
+type Match struct {
+	SavedSearchID   int       `db:"saved_search_id" json:"saved_search_id"`
+	SavedSearchName string    `db:"saved_search_name" json:"saved_search_name"`
+	ListingID       int       `db:"listing_id" json:"listing_id"`
+	ListingTitle    string    `db:"listing_title" json:"listing_title"`
+	ListingPrice    float64   `db:"listing_price" json:"listing_price"`
+	ListingCity     string    `db:"listing_city" json:"listing_city"`
+	IsRead          bool      `db:"is_read" json:"is_read"`
+	CreatedAt       time.Time `db:"created_at" json:"created_at"`
+}
+
+// SavedSearchRequest represents the data needed to create or update a saved search
+type SavedSearchRequest struct {
+	Name         string   `json:"name" binding:"required,max=100"`
+	Keyword      string   `json:"search" binding:"max=200"`
+	CategoryID   *int     `json:"category_id"`
+	City         string   `json:"city"`
+	Condition    string   `json:"condition"`
+	MinPrice     *float64 `json:"min_price" binding:"omitempty,min=0"`
+	MaxPrice     *float64 `json:"max_price" binding:"omitempty,min=0"`
+	Frequency    string   `json:"frequency" binding:"required,oneof=instant daily"`
+	EmailEnabled *bool    `json:"email_enabled"`
+}
+
+// MatchResponse represents a list of matches with pagination
+type MatchResponse struct {
+	Matches     []Match `json:"matches"`
+	TotalCount  int     `json:"total_count"`
+	CurrentPage int     `json:"current_page"`
+	TotalPages  int     `json:"total_pages"`
+}
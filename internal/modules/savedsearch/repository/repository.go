@@ -0,0 +1,319 @@
+package repository
+
+import (
+	categoryRepo "FurniSwap/internal/modules/category/repository"
+	listingRepo "FurniSwap/internal/modules/listing/repository"
+	"FurniSwap/internal/modules/savedsearch/model"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Repository handles database operations for the saved search module
+type Repository struct {
+	db *sqlx.DB
+}
+
+// NewRepository creates a new saved search repository
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+const savedSearchSelect = `
+	SELECT s.id, s.user_id, s.name, s.keyword, s.category_id, s.city, s.condition, s.min_price, s.max_price,
+		   s.frequency, s.email_enabled, s.unsubscribe_token, s.last_digest_at, s.created_at, s.updated_at,
+		   u.email as user_email
+	FROM saved_searches s
+	JOIN users u ON s.user_id = u.id
+`
+
+// CreateSavedSearch creates a new saved search
+func (r *Repository) CreateSavedSearch(userID int, req model.SavedSearchRequest, emailEnabled bool, unsubscribeToken string) (int, error) {
+	var searchID int
+	err := r.db.QueryRow(`
+		INSERT INTO saved_searches (user_id, name, keyword, category_id, city, condition, min_price, max_price,
+		                            frequency, email_enabled, unsubscribe_token, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $12)
+		RETURNING id
+	`, userID, req.Name, req.Keyword, req.CategoryID, req.City, req.Condition, req.MinPrice, req.MaxPrice,
+		req.Frequency, emailEnabled, unsubscribeToken, time.Now()).Scan(&searchID)
+
+	if err != nil {
+		log.Printf("Error creating saved search: %v", err)
+		return 0, fmt.Errorf("error creating saved search: %w", err)
+	}
+
+	return searchID, nil
+}
+
+// GetSavedSearch gets a saved search by ID
+func (r *Repository) GetSavedSearch(searchID int) (*model.SavedSearch, error) {
+	var search model.SavedSearch
+	err := r.db.Get(&search, savedSearchSelect+" WHERE s.id = $1", searchID)
+	if err != nil {
+		log.Printf("Error getting saved search by ID: %v", err)
+		return nil, fmt.Errorf("error getting saved search: %w", err)
+	}
+	return &search, nil
+}
+
+// GetUserSavedSearches gets all saved searches of a user with their unread match counts
+func (r *Repository) GetUserSavedSearches(userID int) ([]model.SavedSearch, error) {
+	searches := []model.SavedSearch{}
+	err := r.db.Select(&searches, `
+		SELECT s.id, s.user_id, s.name, s.keyword, s.category_id, s.city, s.condition, s.min_price, s.max_price,
+			   s.frequency, s.email_enabled, s.created_at, s.updated_at,
+			   (SELECT COUNT(*) FROM saved_search_matches m
+			    WHERE m.saved_search_id = s.id AND m.is_read = false) as new_matches
+		FROM saved_searches s
+		WHERE s.user_id = $1
+		ORDER BY s.created_at DESC
+	`, userID)
+
+	if err != nil {
+		log.Printf("Error getting saved searches: %v", err)
+		return nil, fmt.Errorf("error getting saved searches: %w", err)
+	}
+
+	return searches, nil
+}
+
+// UpdateSavedSearch updates a saved search of the user and reports whether it exists
+func (r *Repository) UpdateSavedSearch(searchID, userID int, req model.SavedSearchRequest, emailEnabled bool) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE saved_searches
+		SET name = $1, keyword = $2, category_id = $3, city = $4, condition = $5, min_price = $6, max_price = $7,
+		    frequency = $8, email_enabled = $9, updated_at = $10
+		WHERE id = $11 AND user_id = $12
+	`, req.Name, req.Keyword, req.CategoryID, req.City, req.Condition, req.MinPrice, req.MaxPrice,
+		req.Frequency, emailEnabled, time.Now(), searchID, userID)
+
+	if err != nil {
+		log.Printf("Error updating saved search: %v", err)
+		return false, fmt.Errorf("error updating saved search: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// DeleteSavedSearch deletes a saved search of the user and reports whether it existed
+func (r *Repository) DeleteSavedSearch(searchID, userID int) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM saved_searches WHERE id = $1 AND user_id = $2", searchID, userID)
+	if err != nil {
+		log.Printf("Error deleting saved search: %v", err)
+		return false, fmt.Errorf("error deleting saved search: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// Unsubscribe turns off emails for the saved search with the given unsubscribe token
+func (r *Repository) Unsubscribe(token string) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE saved_searches SET email_enabled = false, updated_at = $1
+		WHERE unsubscribe_token = $2
+	`, time.Now(), token)
+
+	if err != nil {
+		log.Printf("Error unsubscribing saved search: %v", err)
+		return false, fmt.Errorf("error unsubscribing saved search: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// FindMatchingSearches finds other users' saved searches whose criteria match an active listing
+func (r *Repository) FindMatchingSearches(listingID int) ([]model.SavedSearch, error) {
+	searches := []model.SavedSearch{}
+	err := r.db.Select(&searches, savedSearchSelect+`
+		JOIN listings l ON l.id = $1
+		WHERE l.status = 'active'
+		  AND s.user_id <> l.user_id
+		  AND (s.category_id IS NULL OR l.category_id IN `+categoryRepo.DescendantsQuery("s.category_id")+`)
+		  AND (s.city = '' OR l.city ILIKE '%' || s.city || '%')
+		  AND (s.condition = '' OR l.condition = s.condition)
+		  AND (s.min_price IS NULL OR l.price >= s.min_price)
+		  AND (s.max_price IS NULL OR l.price <= s.max_price)
+		  AND (s.keyword = '' OR `+listingRepo.SearchVector+` @@ websearch_to_tsquery('russian', s.keyword))
+		  AND l.user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = s.user_id)
+	`, listingID)
+
+	if err != nil {
+		log.Printf("Error finding saved searches for listing %d: %v", listingID, err)
+		return nil, fmt.Errorf("error finding matching saved searches: %w", err)
+	}
+
+	return searches, nil
+}
+
+// AddMatch records a listing as matching a saved search
+func (r *Repository) AddMatch(searchID, listingID int) error {
+	_, err := r.db.Exec(`
+		INSERT INTO saved_search_matches (saved_search_id, listing_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (saved_search_id, listing_id) DO NOTHING
+	`, searchID, listingID, time.Now())
+
+	if err != nil {
+		log.Printf("Error adding saved search match: %v", err)
+		return fmt.Errorf("error adding saved search match: %w", err)
+	}
+
+	return nil
+}
+
+// GetUnsentMatches gets the matches of a saved search that haven't been emailed yet
+func (r *Repository) GetUnsentMatches(searchID int) ([]model.Match, error) {
+	matches := []model.Match{}
+	err := r.db.Select(&matches, `
+		SELECT m.saved_search_id, s.name as saved_search_name, m.listing_id,
+			   l.title as listing_title, l.price as listing_price, l.city as listing_city,
+			   m.is_read, m.created_at
+		FROM saved_search_matches m
+		JOIN saved_searches s ON m.saved_search_id = s.id
+		JOIN listings l ON m.listing_id = l.id
+		WHERE m.saved_search_id = $1 AND m.emailed_at IS NULL AND l.status = 'active'
+		ORDER BY m.created_at ASC
+	`, searchID)
+
+	if err != nil {
+		log.Printf("Error getting unsent saved search matches: %v", err)
+		return nil, fmt.Errorf("error getting unsent matches: %w", err)
+	}
+
+	return matches, nil
+}
+
+// MarkMatchesEmailed marks matches as emailed and, for digests, records the digest time
+func (r *Repository) MarkMatchesEmailed(searchID int, listingIDs []int, digest bool) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	_, err = tx.Exec(`
+		UPDATE saved_search_matches SET emailed_at = $1
+		WHERE saved_search_id = $2 AND listing_id = ANY($3)
+	`, now, searchID, pq.Array(listingIDs))
+	if err != nil {
+		log.Printf("Error marking matches as emailed: %v", err)
+		return fmt.Errorf("error marking matches as emailed: %w", err)
+	}
+
+	if digest {
+		_, err = tx.Exec("UPDATE saved_searches SET last_digest_at = $1 WHERE id = $2", now, searchID)
+		if err != nil {
+			log.Printf("Error updating last digest time: %v", err)
+			return fmt.Errorf("error updating last digest time: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetDueDigests gets daily saved searches with unsent matches whose last digest
+// was sent before the given time
+func (r *Repository) GetDueDigests(sentBefore time.Time) ([]model.SavedSearch, error) {
+	searches := []model.SavedSearch{}
+	err := r.db.Select(&searches, savedSearchSelect+`
+		WHERE s.frequency = $1 AND s.email_enabled = true
+		  AND (s.last_digest_at IS NULL OR s.last_digest_at <= $2)
+		  AND EXISTS(SELECT 1 FROM saved_search_matches m WHERE m.saved_search_id = s.id AND m.emailed_at IS NULL)
+	`, model.FrequencyDaily, sentBefore)
+
+	if err != nil {
+		log.Printf("Error getting due saved search digests: %v", err)
+		return nil, fmt.Errorf("error getting due digests: %w", err)
+	}
+
+	return searches, nil
+}
+
+// GetUserMatches gets the user's saved search matches with pagination, newest first
+func (r *Repository) GetUserMatches(userID int, unreadOnly bool, page, limit int) (*model.MatchResponse, error) {
+	// Calculate offset
+	offset := (page - 1) * limit
+
+	condition := "s.user_id = $1"
+	if unreadOnly {
+		condition += " AND m.is_read = false"
+	}
+
+	// Get total count
+	var totalCount int
+	err := r.db.Get(&totalCount, `
+		SELECT COUNT(*) FROM saved_search_matches m
+		JOIN saved_searches s ON m.saved_search_id = s.id
+		WHERE `+condition, userID)
+	if err != nil {
+		log.Printf("Error getting saved search matches count: %v", err)
+		return nil, fmt.Errorf("error getting matches count: %w", err)
+	}
+
+	// Calculate total pages
+	totalPages := int(math.Ceil(float64(totalCount) / float64(limit)))
+
+	// Get matches
+	matches := []model.Match{}
+	err = r.db.Select(&matches, `
+		SELECT m.saved_search_id, s.name as saved_search_name, m.listing_id,
+			   l.title as listing_title, l.price as listing_price, l.city as listing_city,
+			   m.is_read, m.created_at
+		FROM saved_search_matches m
+		JOIN saved_searches s ON m.saved_search_id = s.id
+		JOIN listings l ON m.listing_id = l.id
+		WHERE `+condition+`
+		ORDER BY m.created_at DESC
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+	if err != nil {
+		log.Printf("Error getting saved search matches: %v", err)
+		return nil, fmt.Errorf("error getting matches: %w", err)
+	}
+
+	return &model.MatchResponse{
+		Matches:     matches,
+		TotalCount:  totalCount,
+		CurrentPage: page,
+		TotalPages:  totalPages,
+	}, nil
+}
+
+// MarkMatchesRead marks all matches of the user's saved search as read and reports whether the search exists
+func (r *Repository) MarkMatchesRead(searchID, userID int) (bool, error) {
+	var exists bool
+	err := r.db.Get(&exists, "SELECT EXISTS(SELECT 1 FROM saved_searches WHERE id = $1 AND user_id = $2)", searchID, userID)
+	if err != nil {
+		log.Printf("Error checking saved search ownership: %v", err)
+		return false, fmt.Errorf("error checking saved search ownership: %w", err)
+	}
+	if !exists {
+		return false, nil
+	}
+
+	_, err = r.db.Exec("UPDATE saved_search_matches SET is_read = true WHERE saved_search_id = $1 AND is_read = false", searchID)
+	if err != nil {
+		log.Printf("Error marking matches as read: %v", err)
+		return false, fmt.Errorf("error marking matches as read: %w", err)
+	}
+
+	return true, nil
+}
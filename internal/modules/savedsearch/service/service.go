@@ -0,0 +1,253 @@
+package service
+
+import (
+	listingModel "FurniSwap/internal/modules/listing/model"
+	"FurniSwap/internal/modules/savedsearch/model"
+	"FurniSwap/internal/modules/savedsearch/repository"
+	"FurniSwap/pkg/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Number of created listings queued for matching before falling back to a goroutine per listing
+	matchQueueSize = 100
+
+	// How often daily digests are checked
+	digestCheckInterval = time.Hour
+
+	// Minimum time between two digests of the same saved search
+	digestPeriod = 24 * time.Hour
+)
+
+// Service provides saved search operations and new listing alerts
+type Service struct {
+	repo    *repository.Repository
+	baseURL string
+	created chan int
+}
+
+// NewService creates a new saved search service; baseURL is used for links in emails
+func NewService(repo *repository.Repository, baseURL string) *Service {
+	return &Service{
+		repo:    repo,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		created: make(chan int, matchQueueSize),
+	}
+}
+
+// Start runs the background matcher and the daily digest sender until the context is cancelled
+func (s *Service) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case listingID := <-s.created:
+				s.matchListing(listingID)
+			}
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(digestCheckInterval)
+		defer ticker.Stop()
+
+		s.sendDueDigests()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.sendDueDigests()
+			}
+		}
+	}()
+}
+
+// ListingCreated queues a newly created listing for matching against saved searches
+func (s *Service) ListingCreated(listingID int) {
+	select {
+	case s.created <- listingID:
+	default:
+		log.Printf("Saved search match queue is full, matching listing %d separately", listingID)
+		go s.matchListing(listingID)
+	}
+}
+
+// CreateSavedSearch saves a search for the user
+func (s *Service) CreateSavedSearch(userID int, req model.SavedSearchRequest) (int, error) {
+	if err := validateRequest(&req); err != nil {
+		return 0, err
+	}
+
+	return s.repo.CreateSavedSearch(userID, req, emailEnabled(req), uuid.New().String())
+}
+
+// GetUserSavedSearches gets the user's saved searches
+func (s *Service) GetUserSavedSearches(userID int) ([]model.SavedSearch, error) {
+	return s.repo.GetUserSavedSearches(userID)
+}
+
+// UpdateSavedSearch updates a saved search of the user
+func (s *Service) UpdateSavedSearch(searchID, userID int, req model.SavedSearchRequest) error {
+	if err := validateRequest(&req); err != nil {
+		return err
+	}
+
+	updated, err := s.repo.UpdateSavedSearch(searchID, userID, req, emailEnabled(req))
+	if err != nil {
+		return err
+	}
+
+	if !updated {
+		return errors.New("saved search not found")
+	}
+
+	return nil
+}
+
+// DeleteSavedSearch deletes a saved search of the user
+func (s *Service) DeleteSavedSearch(searchID, userID int) error {
+	deleted, err := s.repo.DeleteSavedSearch(searchID, userID)
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return errors.New("saved search not found")
+	}
+
+	return nil
+}
+
+// GetUserMatches gets new listings that matched the user's saved searches
+func (s *Service) GetUserMatches(userID int, unreadOnly bool, page, limit int) (*model.MatchResponse, error) {
+	return s.repo.GetUserMatches(userID, unreadOnly, page, limit)
+}
+
+// MarkMatchesRead marks the matches of a saved search as read
+func (s *Service) MarkMatchesRead(searchID, userID int) error {
+	found, err := s.repo.MarkMatchesRead(searchID, userID)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return errors.New("saved search not found")
+	}
+
+	return nil
+}
+
+// Unsubscribe turns off emails for a saved search by its unsubscribe token
+func (s *Service) Unsubscribe(token string) error {
+	found, err := s.repo.Unsubscribe(token)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return errors.New("invalid unsubscribe link")
+	}
+
+	return nil
+}
+
+// matchListing records a listing as a match for every saved search it fits and
+// emails users with instant notifications
+func (s *Service) matchListing(listingID int) {
+	searches, err := s.repo.FindMatchingSearches(listingID)
+	if err != nil {
+		log.Printf("Error matching listing %d against saved searches: %v", listingID, err)
+		return
+	}
+
+	for i := range searches {
+		search := &searches[i]
+		if err := s.repo.AddMatch(search.ID, listingID); err != nil {
+			continue
+		}
+
+		if search.Frequency == model.FrequencyInstant && search.EmailEnabled {
+			s.sendMatches(search, false)
+		}
+	}
+}
+
+// sendDueDigests emails daily digests that are due
+func (s *Service) sendDueDigests() {
+	searches, err := s.repo.GetDueDigests(time.Now().Add(-digestPeriod))
+	if err != nil {
+		log.Printf("Error getting due saved search digests: %v", err)
+		return
+	}
+
+	for i := range searches {
+		s.sendMatches(&searches[i], true)
+	}
+}
+
+// sendMatches emails all not yet emailed matches of a saved search
+func (s *Service) sendMatches(search *model.SavedSearch, digest bool) {
+	matches, err := s.repo.GetUnsentMatches(search.ID)
+	if err != nil || len(matches) == 0 {
+		return
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "New listings matching your saved search \"%s\":\n\n", search.Name)
+	listingIDs := make([]int, len(matches))
+	for i, match := range matches {
+		listingIDs[i] = match.ListingID
+		fmt.Fprintf(&body, "- %s, %.0f RUB, %s\n  %s/listings/%d\n", match.ListingTitle, match.ListingPrice, match.ListingCity, s.baseURL, match.ListingID)
+	}
+	unsubscribeURL := fmt.Sprintf("%s/saved-searches/unsubscribe?token=%s", s.baseURL, search.UnsubscribeToken)
+	fmt.Fprintf(&body, "\nTo stop receiving these emails, open %s\n", unsubscribeURL)
+
+	subject := "New listings for your saved search"
+	if digest {
+		subject = "Daily digest for your saved search"
+	}
+
+	// Lets mail clients offer one-click unsubscribe (RFC 8058)
+	headers := map[string]string{
+		"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+	if err := utils.SendEmailWithHeaders(search.UserEmail, subject, body.String(), headers); err != nil {
+		log.Printf("Error sending saved search %d email: %v", search.ID, err)
+		return
+	}
+
+	if err := s.repo.MarkMatchesEmailed(search.ID, listingIDs, digest); err != nil {
+		log.Printf("Error marking saved search %d matches as emailed: %v", search.ID, err)
+	}
+}
+
+// validateRequest checks the saved search criteria and normalizes the condition
+func validateRequest(req *model.SavedSearchRequest) error {
+	if req.Condition != "" {
+		condition, ok := listingModel.NormalizeCondition(req.Condition)
+		if !ok {
+			return errors.New("invalid condition")
+		}
+		req.Condition = condition
+	}
+
+	if req.MinPrice != nil && req.MaxPrice != nil && *req.MinPrice > *req.MaxPrice {
+		return errors.New("min price cannot be greater than max price")
+	}
+	return nil
+}
+
+// emailEnabled returns whether emails are requested, which is the default
+func emailEnabled(req model.SavedSearchRequest) bool {
+	return req.EmailEnabled == nil || *req.EmailEnabled
+}
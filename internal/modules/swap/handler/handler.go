@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"FurniSwap/internal/modules/swap/model"
+	"FurniSwap/internal/modules/swap/service"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Handler provides swap handlers
+type Handler struct {
+	service *service.Service
+}
+
+// NewHandler creates a new swap handler
+func NewHandler(service *service.Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// RegisterRoutes registers swap routes to router
+func (h *Handler) RegisterRoutes(router *gin.RouterGroup) {
+	router.POST("/listings/:id/swaps", h.ProposeSwap)
+	router.GET("/swaps", h.GetUserSwaps)
+	router.GET("/swaps/:id", h.GetSwap)
+	router.POST("/swaps/:id/accept", h.AcceptSwap)
+	router.POST("/swaps/:id/decline", h.DeclineSwap)
+	router.POST("/swaps/:id/counter", h.CounterSwap)
+	router.POST("/swaps/:id/cancel", h.CancelSwap)
+}
+
+// ProposeSwap handles proposing a swap for a listing
+func (h *Handler) ProposeSwap(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse listing ID
+	listingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid listing ID"})
+		return
+	}
+
+	// Parse request body
+	var req model.ProposeSwapRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	// Propose swap
+	swapID, err := h.service.ProposeSwap(userID.(int), listingID, req)
+	if err != nil {
+		h.handleSwapError(c, err, "Error proposing swap")
+		return
+	}
+
+	h.respondWithSwap(c, swapID, userID.(int), "Swap offer sent")
+}
+
+// GetUserSwaps handles getting the user's swap offers
+func (h *Handler) GetUserSwaps(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse pagination parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+
+	// Get swap offers (role: incoming, outgoing or all)
+	swaps, err := h.service.GetUserSwaps(userID.(int), c.DefaultQuery("role", "all"), page, limit)
+	if err != nil {
+		log.Printf("Error getting swap offers: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting swap offers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, swaps)
+}
+
+// GetSwap handles getting a single swap offer
+func (h *Handler) GetSwap(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse swap ID
+	swapID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid swap ID"})
+		return
+	}
+
+	swap, err := h.service.GetSwapByID(swapID, userID.(int))
+	if err != nil {
+		h.handleSwapError(c, err, "Error getting swap offer")
+		return
+	}
+
+	c.JSON(http.StatusOK, swap)
+}
+
+// AcceptSwap handles accepting a swap offer
+func (h *Handler) AcceptSwap(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse swap ID
+	swapID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid swap ID"})
+		return
+	}
+
+	if err = h.service.AcceptSwap(swapID, userID.(int)); err != nil {
+		h.handleSwapError(c, err, "Error accepting swap offer")
+		return
+	}
+
+	h.respondWithSwap(c, swapID, userID.(int), "Swap offer accepted")
+}
+
+// DeclineSwap handles declining a swap offer
+func (h *Handler) DeclineSwap(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse swap ID
+	swapID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid swap ID"})
+		return
+	}
+
+	if err = h.service.DeclineSwap(swapID, userID.(int)); err != nil {
+		h.handleSwapError(c, err, "Error declining swap offer")
+		return
+	}
+
+	h.respondWithSwap(c, swapID, userID.(int), "Swap offer declined")
+}
+
+// CounterSwap handles making a counter-offer to a swap offer
+func (h *Handler) CounterSwap(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse swap ID
+	swapID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid swap ID"})
+		return
+	}
+
+	// Parse request body
+	var req model.ProposeSwapRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid data"})
+		return
+	}
+
+	newSwapID, err := h.service.CounterSwap(swapID, userID.(int), req)
+	if err != nil {
+		h.handleSwapError(c, err, "Error making counter-offer")
+		return
+	}
+
+	h.respondWithSwap(c, newSwapID, userID.(int), "Counter-offer sent")
+}
+
+// CancelSwap handles withdrawing a swap offer
+func (h *Handler) CancelSwap(c *gin.Context) {
+	// Get user ID from context (set by auth middleware)
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse swap ID
+	swapID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid swap ID"})
+		return
+	}
+
+	if err = h.service.CancelSwap(swapID, userID.(int)); err != nil {
+		h.handleSwapError(c, err, "Error cancelling swap offer")
+		return
+	}
+
+	h.respondWithSwap(c, swapID, userID.(int), "Swap offer cancelled")
+}
+
+// respondWithSwap writes the current state of a swap offer, falling back to a plain message
+func (h *Handler) respondWithSwap(c *gin.Context, swapID, userID int, message string) {
+	swap, err := h.service.GetSwapByID(swapID, userID)
+	if err != nil {
+		log.Printf("Error getting swap offer: %v", err)
+		c.JSON(http.StatusOK, gin.H{"id": swapID, "message": message})
+		return
+	}
+
+	c.JSON(http.StatusOK, swap)
+}
+
+// handleSwapError maps swap service errors to HTTP responses
+func (h *Handler) handleSwapError(c *gin.Context, err error, fallback string) {
+	switch err.Error() {
+	case "listing not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Listing not found"})
+	case "swap offer not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Swap offer not found"})
+	case "you don't have access to this swap offer":
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this swap offer"})
+	case "only the author can cancel the swap offer":
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can cancel the swap offer"})
+	case "you cannot swap for your own listing":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot swap for your own listing"})
+	case "listing is not available for swap":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Listing is not available for swap"})
+	case "invalid offered listings":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Offered listings must be unique and differ from the requested listing"})
+	case "offered listing is not available":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Offered listings must be your own active listings"})
+	case "you cannot respond to your own swap offer":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot respond to your own swap offer"})
+	case "you already have a pending swap offer for this listing":
+		c.JSON(http.StatusConflict, gin.H{"error": "You already have a pending swap offer for this listing"})
+	case "swap offer is not pending":
+		c.JSON(http.StatusConflict, gin.H{"error": "Swap offer is no longer pending"})
+	case "you cannot propose swaps to this user":
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot propose swaps to this user"})
+	case "some listings in the swap are no longer available":
+		c.JSON(http.StatusConflict, gin.H{"error": "Some listings in the swap are no longer available"})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+)
+
+// Swap offer statuses
+const (
+	StatusPending   = "pending"
+	StatusAccepted  = "accepted"
+	StatusDeclined  = "declined"
+	StatusCountered = "countered"
+	StatusCancelled = "cancelled"
+)
+
+// Swap represents an offer to exchange one or more of the proposer's listings
+// (optionally plus a cash top-up) for a listing owned by another user
+type Swap struct {
+	ID                int       `db:"id" json:"id"`
+	ListingID         int       `db:"listing_id" json:"listing_id"`
+	ProposerID        int       `db:"proposer_id" json:"proposer_id"`
+	OwnerID           int       `db:"owner_id" json:"owner_id"`
+	AuthorID          int       `db:"author_id" json:"author_id"`
+	ParentID          *int      `db:"parent_id" json:"parent_id,omitempty"`
+	CashTopUp         float64   `db:"cash_top_up" json:"cash_top_up"`
+	Message           string    `db:"message" json:"message"`
+	Status            string    `db:"status" json:"status"`
+	CreatedAt         time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt         time.Time `db:"updated_at" json:"updated_at"`
+	ListingTitle      string    `db:"listing_title" json:"listing_title,omitempty"`
+	ProposerName      string    `db:"proposer_name" json:"proposer_name,omitempty"`
+	OwnerName         string    `db:"owner_name" json:"owner_name,omitempty"`
+	OfferedListingIDs []int     `json:"offered_listing_ids"`
+}
+
+// SwapResponse represents a list of swap offers with pagination
+type SwapResponse struct {
+	Swaps       []Swap `json:"swaps"`
+	TotalCount  int    `json:"total_count"`
+	CurrentPage int    `json:"current_page"`
+	TotalPages  int    `json:"total_pages"`
+}
+
+// ProposeSwapRequest represents the data needed to propose a swap or counter an existing one
+type ProposeSwapRequest struct {
+	OfferedListingIDs []int   `json:"offered_listing_ids" binding:"required,min=1,max=10"`
+	CashTopUp         float64 `json:"cash_top_up" binding:"min=0"`
+	Message           string  `json:"message"`
+}
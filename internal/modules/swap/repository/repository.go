@@ -0,0 +1,309 @@
+package repository
+
+import (
+	"FurniSwap/internal/modules/swap/model"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ErrListingsUnavailable is returned when a swap can no longer be completed
+// because one of the involved listings was sold, swapped or changed owner
+var ErrListingsUnavailable = errors.New("some listings in the swap are no longer available")
+
+// ErrSwapNotPending is returned when a swap offer has already been answered
+var ErrSwapNotPending = errors.New("swap offer is not pending")
+
+// Repository handles database operations for the swap module
+type Repository struct {
+	db *sqlx.DB
+}
+
+// NewRepository creates a new swap repository
+func NewRepository(db *sqlx.DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+const swapSelect = `
+	SELECT s.id, s.listing_id, s.proposer_id, s.owner_id, s.author_id, s.parent_id,
+		   s.cash_top_up, COALESCE(s.message, '') as message, s.status, s.created_at, s.updated_at,
+		   COALESCE(l.title, '') as listing_title,
+		   u1.name || ' ' || COALESCE(u1.last_name, '') as proposer_name,
+		   u2.name || ' ' || COALESCE(u2.last_name, '') as owner_name
+	FROM swap_offers s
+	JOIN users u1 ON s.proposer_id = u1.id
+	JOIN users u2 ON s.owner_id = u2.id
+	LEFT JOIN listings l ON s.listing_id = l.id
+`
+
+// CreateSwap creates a new swap offer. If parentID is set, the parent offer is
+// marked as countered in the same transaction.
+func (r *Repository) CreateSwap(listingID, proposerID, ownerID, authorID int, parentID *int, req model.ProposeSwapRequest) (int, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		return 0, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if parentID != nil {
+		result, err := tx.Exec(`
+			UPDATE swap_offers SET status = $1, updated_at = $2
+			WHERE id = $3 AND status = $4
+		`, model.StatusCountered, time.Now(), *parentID, model.StatusPending)
+		if err != nil {
+			log.Printf("Error marking swap offer %d as countered: %v", *parentID, err)
+			return 0, fmt.Errorf("error updating swap offer: %w", err)
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return 0, ErrSwapNotPending
+		}
+	}
+
+	var swapID int
+	err = tx.QueryRow(`
+		INSERT INTO swap_offers (listing_id, proposer_id, owner_id, author_id, parent_id, cash_top_up, message, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+		RETURNING id
+	`, listingID, proposerID, ownerID, authorID, parentID, req.CashTopUp, req.Message, model.StatusPending, time.Now()).Scan(&swapID)
+	if err != nil {
+		log.Printf("Error creating swap offer: %v", err)
+		return 0, fmt.Errorf("error creating swap offer: %w", err)
+	}
+
+	for _, offeredID := range req.OfferedListingIDs {
+		_, err = tx.Exec("INSERT INTO swap_offer_items (swap_id, listing_id) VALUES ($1, $2)", swapID, offeredID)
+		if err != nil {
+			log.Printf("Error adding listing %d to swap offer %d: %v", offeredID, swapID, err)
+			return 0, fmt.Errorf("error adding swap offer item: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return 0, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return swapID, nil
+}
+
+// GetSwapByID gets a swap offer by ID together with the offered listing IDs
+func (r *Repository) GetSwapByID(swapID int) (*model.Swap, error) {
+	var swap model.Swap
+	err := r.db.Get(&swap, swapSelect+" WHERE s.id = $1", swapID)
+	if err != nil {
+		log.Printf("Error getting swap offer by ID: %v", err)
+		return nil, fmt.Errorf("error getting swap offer: %w", err)
+	}
+
+	if err = r.loadOfferedListings(&swap); err != nil {
+		return nil, err
+	}
+
+	return &swap, nil
+}
+
+// GetUserSwaps gets swap offers where the user is the proposer ("outgoing"),
+// the listing owner ("incoming") or either of them (any other role)
+func (r *Repository) GetUserSwaps(userID int, role string, page, limit int) (*model.SwapResponse, error) {
+	// Calculate offset
+	offset := (page - 1) * limit
+
+	condition := "(s.proposer_id = $1 OR s.owner_id = $1)"
+	switch role {
+	case "incoming":
+		condition = "s.owner_id = $1"
+	case "outgoing":
+		condition = "s.proposer_id = $1"
+	}
+
+	// Get total count
+	var totalCount int
+	err := r.db.Get(&totalCount, "SELECT COUNT(*) FROM swap_offers s WHERE "+condition, userID)
+	if err != nil {
+		log.Printf("Error getting swap offers count: %v", err)
+		return nil, fmt.Errorf("error getting swap offers count: %w", err)
+	}
+
+	// Calculate total pages
+	totalPages := int(math.Ceil(float64(totalCount) / float64(limit)))
+
+	// Get swap offers
+	swaps := []model.Swap{}
+	err = r.db.Select(&swaps, swapSelect+" WHERE "+condition+" ORDER BY s.updated_at DESC LIMIT $2 OFFSET $3", userID, limit, offset)
+	if err != nil {
+		log.Printf("Error getting swap offers: %v", err)
+		return nil, fmt.Errorf("error getting swap offers: %w", err)
+	}
+
+	for i := range swaps {
+		if err = r.loadOfferedListings(&swaps[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return &model.SwapResponse{
+		Swaps:       swaps,
+		TotalCount:  totalCount,
+		CurrentPage: page,
+		TotalPages:  totalPages,
+	}, nil
+}
+
+// HasPendingSwap checks if the proposer already has a pending offer for the listing
+func (r *Repository) HasPendingSwap(listingID, proposerID int) (bool, error) {
+	var exists bool
+	err := r.db.Get(&exists, `
+		SELECT EXISTS(SELECT 1 FROM swap_offers WHERE listing_id = $1 AND proposer_id = $2 AND status = $3)
+	`, listingID, proposerID, model.StatusPending)
+	if err != nil {
+		log.Printf("Error checking pending swap offers: %v", err)
+		return false, fmt.Errorf("error checking pending swap offers: %w", err)
+	}
+	return exists, nil
+}
+
+// UpdateSwapStatus moves a pending swap offer to a new status
+func (r *Repository) UpdateSwapStatus(swapID int, status string) error {
+	result, err := r.db.Exec(`
+		UPDATE swap_offers SET status = $1, updated_at = $2
+		WHERE id = $3 AND status = $4
+	`, status, time.Now(), swapID, model.StatusPending)
+	if err != nil {
+		log.Printf("Error updating swap offer status: %v", err)
+		return fmt.Errorf("error updating swap offer status: %w", err)
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrSwapNotPending
+	}
+
+	return nil
+}
+
+// AcceptSwap accepts a pending swap offer. All involved listings are locked,
+// re-checked and moved to the "swapped" status, purchase history records are
+// written for both parties and other pending offers on these listings are
+// cancelled, all in a single transaction.
+func (r *Repository) AcceptSwap(swap *model.Swap) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		log.Printf("Error beginning transaction: %v", err)
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	listingIDs := append([]int{swap.ListingID}, swap.OfferedListingIDs...)
+
+	// Lock every listing involved in the swap
+	var listings []struct {
+		ID     int    `db:"id"`
+		UserID int    `db:"user_id"`
+		Status string `db:"status"`
+	}
+	err = tx.Select(&listings, `
+		SELECT id, user_id, status FROM listings
+		WHERE id = ANY($1)
+		ORDER BY id
+		FOR UPDATE
+	`, pq.Array(listingIDs))
+	if err != nil {
+		log.Printf("Error locking swap listings: %v", err)
+		return fmt.Errorf("error locking swap listings: %w", err)
+	}
+
+	if len(listings) != len(listingIDs) {
+		return ErrListingsUnavailable
+	}
+	for _, listing := range listings {
+		expectedOwner := swap.ProposerID
+		if listing.ID == swap.ListingID {
+			expectedOwner = swap.OwnerID
+		}
+		if listing.Status != "active" || listing.UserID != expectedOwner {
+			log.Printf("Listing %d cannot be swapped: status %s, owner %d", listing.ID, listing.Status, listing.UserID)
+			return ErrListingsUnavailable
+		}
+	}
+
+	now := time.Now()
+
+	// Accept the offer
+	result, err := tx.Exec(`
+		UPDATE swap_offers SET status = $1, updated_at = $2
+		WHERE id = $3 AND status = $4
+	`, model.StatusAccepted, now, swap.ID, model.StatusPending)
+	if err != nil {
+		log.Printf("Error accepting swap offer: %v", err)
+		return fmt.Errorf("error accepting swap offer: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrSwapNotPending
+	}
+
+	// Move all listings to "swapped"
+	_, err = tx.Exec("UPDATE listings SET status = 'swapped', updated_at = $1 WHERE id = ANY($2)", now, pq.Array(listingIDs))
+	if err != nil {
+		log.Printf("Error updating swapped listings: %v", err)
+		return fmt.Errorf("error updating swapped listings: %w", err)
+	}
+
+	// The proposer receives the owner's listing for the cash top-up...
+	_, err = tx.Exec(`
+		INSERT INTO purchases (listing_id, buyer_id, seller_id, price, purchased_at, updated_at, completed_at, status, kind, swap_id)
+		VALUES ($1, $2, $3, $4, $5, $5, $5, 'completed', 'swap', $6)
+	`, swap.ListingID, swap.ProposerID, swap.OwnerID, swap.CashTopUp, now, swap.ID)
+	if err != nil {
+		log.Printf("Error creating swap purchase record: %v", err)
+		return fmt.Errorf("error creating swap purchase record: %w", err)
+	}
+
+	// ...and the owner receives every offered listing
+	for _, offeredID := range swap.OfferedListingIDs {
+		_, err = tx.Exec(`
+			INSERT INTO purchases (listing_id, buyer_id, seller_id, price, purchased_at, updated_at, completed_at, status, kind, swap_id)
+			VALUES ($1, $2, $3, 0, $4, $4, $4, 'completed', 'swap', $5)
+		`, offeredID, swap.OwnerID, swap.ProposerID, now, swap.ID)
+		if err != nil {
+			log.Printf("Error creating swap purchase record: %v", err)
+			return fmt.Errorf("error creating swap purchase record: %w", err)
+		}
+	}
+
+	// Cancel other pending offers that involve any of the swapped listings
+	_, err = tx.Exec(`
+		UPDATE swap_offers SET status = $1, updated_at = $2
+		WHERE status = $3 AND id <> $4
+		  AND (listing_id = ANY($5)
+		       OR id IN (SELECT swap_id FROM swap_offer_items WHERE listing_id = ANY($5)))
+	`, model.StatusCancelled, now, model.StatusPending, swap.ID, pq.Array(listingIDs))
+	if err != nil {
+		log.Printf("Error cancelling conflicting swap offers: %v", err)
+		return fmt.Errorf("error cancelling conflicting swap offers: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
+
+// loadOfferedListings fills in the IDs of the listings offered in a swap
+func (r *Repository) loadOfferedListings(swap *model.Swap) error {
+	swap.OfferedListingIDs = []int{} // Initialize with empty slice to avoid null in JSON
+	err := r.db.Select(&swap.OfferedListingIDs, "SELECT listing_id FROM swap_offer_items WHERE swap_id = $1 ORDER BY listing_id", swap.ID)
+	if err != nil {
+		log.Printf("Error getting offered listings for swap %d: %v", swap.ID, err)
+		return fmt.Errorf("error getting offered listings: %w", err)
+	}
+	return nil
+}
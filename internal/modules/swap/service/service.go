@@ -0,0 +1,225 @@
+package service
+
+import (
+	listingRepo "FurniSwap/internal/modules/listing/repository"
+	moderationRepo "FurniSwap/internal/modules/moderation/repository"
+	"FurniSwap/internal/modules/swap/model"
+	swapRepo "FurniSwap/internal/modules/swap/repository"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Service provides swap operations
+type Service struct {
+	repo        *swapRepo.Repository
+	listingRepo *listingRepo.Repository
+	blockRepo   *moderationRepo.Repository
+}
+
+// NewService creates a new swap service
+func NewService(repo *swapRepo.Repository, listingRepo *listingRepo.Repository, blockRepo *moderationRepo.Repository) *Service {
+	return &Service{
+		repo:        repo,
+		listingRepo: listingRepo,
+		blockRepo:   blockRepo,
+	}
+}
+
+// ProposeSwap offers some of the user's listings in exchange for another user's listing
+func (s *Service) ProposeSwap(userID, listingID int, req model.ProposeSwapRequest) (int, error) {
+	// Get the requested listing
+	listing, err := s.listingRepo.GetListing(listingID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, errors.New("listing not found")
+		}
+		return 0, fmt.Errorf("error getting listing: %w", err)
+	}
+
+	if listing.UserID == userID {
+		return 0, errors.New("you cannot swap for your own listing")
+	}
+
+	if listing.Status != "active" {
+		log.Printf("Listing %d is not available for swap, status: %s", listingID, listing.Status)
+		return 0, errors.New("listing is not available for swap")
+	}
+
+	// Users who blocked each other can't reach each other through swap offers
+	if err = s.checkNotBlocked(userID, listing.UserID); err != nil {
+		return 0, err
+	}
+
+	if err = s.validateOfferedListings(userID, listingID, req.OfferedListingIDs); err != nil {
+		return 0, err
+	}
+
+	// Don't allow several parallel offers for the same listing from one user
+	exists, err := s.repo.HasPendingSwap(listingID, userID)
+	if err != nil {
+		return 0, fmt.Errorf("error checking pending swap offers: %w", err)
+	}
+	if exists {
+		return 0, errors.New("you already have a pending swap offer for this listing")
+	}
+
+	return s.repo.CreateSwap(listingID, userID, listing.UserID, userID, nil, req)
+}
+
+// CounterSwap replaces a pending offer with a new one made by the other party
+func (s *Service) CounterSwap(swapID, userID int, req model.ProposeSwapRequest) (int, error) {
+	swap, err := s.getPendingSwapForResponse(swapID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = s.checkNotBlocked(swap.ProposerID, swap.OwnerID); err != nil {
+		return 0, err
+	}
+
+	// The counter-offer may ask for other listings, but they still have to belong to the proposer
+	if err = s.validateOfferedListings(swap.ProposerID, swap.ListingID, req.OfferedListingIDs); err != nil {
+		return 0, err
+	}
+
+	newSwapID, err := s.repo.CreateSwap(swap.ListingID, swap.ProposerID, swap.OwnerID, userID, &swap.ID, req)
+	if err != nil {
+		if errors.Is(err, swapRepo.ErrSwapNotPending) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("error creating counter-offer: %w", err)
+	}
+
+	return newSwapID, nil
+}
+
+// AcceptSwap accepts a pending swap offer and exchanges the listings
+func (s *Service) AcceptSwap(swapID, userID int) error {
+	swap, err := s.getPendingSwapForResponse(swapID, userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.AcceptSwap(swap)
+	if err != nil {
+		if errors.Is(err, swapRepo.ErrSwapNotPending) || errors.Is(err, swapRepo.ErrListingsUnavailable) {
+			return err
+		}
+		return fmt.Errorf("error accepting swap offer: %w", err)
+	}
+
+	return nil
+}
+
+// DeclineSwap declines a pending swap offer
+func (s *Service) DeclineSwap(swapID, userID int) error {
+	if _, err := s.getPendingSwapForResponse(swapID, userID); err != nil {
+		return err
+	}
+	return s.repo.UpdateSwapStatus(swapID, model.StatusDeclined)
+}
+
+// CancelSwap withdraws a pending swap offer made by the user
+func (s *Service) CancelSwap(swapID, userID int) error {
+	swap, err := s.GetSwapByID(swapID, userID)
+	if err != nil {
+		return err
+	}
+
+	if swap.AuthorID != userID {
+		return errors.New("only the author can cancel the swap offer")
+	}
+
+	if swap.Status != model.StatusPending {
+		return swapRepo.ErrSwapNotPending
+	}
+
+	return s.repo.UpdateSwapStatus(swapID, model.StatusCancelled)
+}
+
+// GetSwapByID gets a swap offer visible to the user
+func (s *Service) GetSwapByID(swapID, userID int) (*model.Swap, error) {
+	swap, err := s.repo.GetSwapByID(swapID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("swap offer not found")
+		}
+		return nil, fmt.Errorf("error getting swap offer: %w", err)
+	}
+
+	if swap.ProposerID != userID && swap.OwnerID != userID {
+		return nil, errors.New("you don't have access to this swap offer")
+	}
+
+	return swap, nil
+}
+
+// GetUserSwaps gets the user's swap offers
+func (s *Service) GetUserSwaps(userID int, role string, page, limit int) (*model.SwapResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+	return s.repo.GetUserSwaps(userID, role, page, limit)
+}
+
+// getPendingSwapForResponse gets a pending swap offer that the user is expected to answer
+func (s *Service) getPendingSwapForResponse(swapID, userID int) (*model.Swap, error) {
+	swap, err := s.GetSwapByID(swapID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if swap.Status != model.StatusPending {
+		return nil, swapRepo.ErrSwapNotPending
+	}
+
+	if swap.AuthorID == userID {
+		return nil, errors.New("you cannot respond to your own swap offer")
+	}
+
+	return swap, nil
+}
+
+// validateOfferedListings checks that every offered listing is an active listing of the proposer
+func (s *Service) validateOfferedListings(proposerID, targetListingID int, offeredIDs []int) error {
+	seen := make(map[int]bool, len(offeredIDs))
+	for _, offeredID := range offeredIDs {
+		if seen[offeredID] || offeredID == targetListingID {
+			return errors.New("invalid offered listings")
+		}
+		seen[offeredID] = true
+
+		offered, err := s.listingRepo.GetListing(offeredID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return errors.New("offered listing is not available")
+			}
+			return fmt.Errorf("error getting offered listing: %w", err)
+		}
+
+		if offered.UserID != proposerID || offered.Status != "active" {
+			log.Printf("Listing %d cannot be offered by user %d, owner: %d, status: %s", offeredID, proposerID, offered.UserID, offered.Status)
+			return errors.New("offered listing is not available")
+		}
+	}
+	return nil
+}
+
+// checkNotBlocked returns an error if either user has blocked the other
+func (s *Service) checkNotBlocked(userID, otherID int) error {
+	blocked, err := s.blockRepo.IsBlockedBetween(userID, otherID)
+	if err != nil {
+		return fmt.Errorf("error checking user blocks: %w", err)
+	}
+
+	if blocked {
+		return errors.New("you cannot propose swaps to this user")
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+-- Nested categories with slugs, icons and sort order
+ALTER TABLE categories
+    ADD COLUMN parent_id  INT REFERENCES categories (id) ON DELETE RESTRICT,
+    ADD COLUMN slug       TEXT,
+    ADD COLUMN icon       TEXT NOT NULL DEFAULT '',
+    ADD COLUMN sort_order INT  NOT NULL DEFAULT 0,
+    ADD COLUMN created_at TIMESTAMP DEFAULT NOW(),
+    ADD COLUMN updated_at TIMESTAMP DEFAULT NOW();
+
+UPDATE categories c
+SET slug = s.slug, sort_order = s.sort_order
+FROM (VALUES ('Диваны и кресла', 'sofas-and-armchairs', 10),
+             ('Столы и стулья', 'tables-and-chairs', 20),
+             ('Шкафы и комоды', 'wardrobes-and-dressers', 30),
+             ('Кровати и матрасы', 'beds-and-mattresses', 40),
+             ('Другое', 'other', 1000))
+    AS s (name, slug, sort_order)
+WHERE c.name = s.name;
+
+UPDATE categories SET slug = 'category-' || id WHERE slug IS NULL;
+
+ALTER TABLE categories ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE categories ADD CONSTRAINT categories_slug_key UNIQUE (slug);
+
+-- Names only need to be unique among siblings
+ALTER TABLE categories DROP CONSTRAINT categories_name_key;
+CREATE UNIQUE INDEX categories_parent_name_idx ON categories (COALESCE(parent_id, 0), name);
+
+CREATE INDEX categories_parent_id_idx ON categories (parent_id);
+
+-- Administrators manage categories; grant with UPDATE users SET is_admin = true WHERE email = '...'
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT false;
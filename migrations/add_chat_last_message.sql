@@ -0,0 +1,15 @@
+-- Last activity in chats for ordering the chat list
+ALTER TABLE chats ADD COLUMN last_message_at TIMESTAMP DEFAULT NOW();
+ALTER TABLE chats ADD COLUMN last_message_user_id INT REFERENCES users (id) ON DELETE SET NULL;
+
+UPDATE chats c
+SET last_message_at      = COALESCE(m.created_at, c.created_at),
+    last_message_user_id = m.user_id
+FROM chats c2
+LEFT JOIN LATERAL (SELECT created_at, user_id FROM messages WHERE chat_id = c2.id ORDER BY id DESC LIMIT 1) m ON true
+WHERE c.id = c2.id;
+
+ALTER TABLE chats ALTER COLUMN last_message_at SET NOT NULL;
+
+CREATE INDEX chats_buyer_last_message_idx ON chats (buyer_id, last_message_at DESC, id DESC);
+CREATE INDEX chats_seller_last_message_idx ON chats (seller_id, last_message_at DESC, id DESC);
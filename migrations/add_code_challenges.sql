@@ -0,0 +1,6 @@
+-- Verification and 2FA codes are checked by the challenge ID returned when the code is
+-- sent, instead of by email and code
+ALTER TABLE two_factor_codes
+    ADD COLUMN challenge_id UUID NOT NULL DEFAULT gen_random_uuid();
+
+CREATE UNIQUE INDEX two_factor_codes_challenge_id_idx ON two_factor_codes (challenge_id);
@@ -0,0 +1,12 @@
+-- One-time codes are stored hashed with the purpose they were issued for and a count
+-- of failed attempts. Codes issued before are plain text and can't be kept.
+DELETE FROM two_factor_codes;
+
+ALTER TABLE two_factor_codes
+    RENAME COLUMN code TO code_hash;
+
+ALTER TABLE two_factor_codes
+    ADD COLUMN purpose  TEXT NOT NULL,
+    ADD COLUMN attempts INT  NOT NULL DEFAULT 0;
+
+CREATE INDEX two_factor_codes_user_purpose_idx ON two_factor_codes (user_id, purpose);
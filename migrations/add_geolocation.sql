@@ -0,0 +1,94 @@
+-- Coordinates of Russian cities, used when a listing or user has a city but no coordinates
+CREATE TABLE cities
+(
+    name      TEXT PRIMARY KEY,
+    latitude  DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL
+);
+
+CREATE UNIQUE INDEX cities_lower_name_idx ON cities (lower(name));
+
+INSERT INTO cities (name, latitude, longitude)
+VALUES ('Москва', 55.7558, 37.6173),
+       ('Санкт-Петербург', 59.9343, 30.3351),
+       ('Новосибирск', 55.0084, 82.9357),
+       ('Екатеринбург', 56.8389, 60.6057),
+       ('Казань', 55.7963, 49.1088),
+       ('Нижний Новгород', 56.2965, 43.9361),
+       ('Челябинск', 55.1644, 61.4368),
+       ('Красноярск', 56.0153, 92.8932),
+       ('Самара', 53.1959, 50.1002),
+       ('Уфа', 54.7388, 55.9721),
+       ('Ростов-на-Дону', 47.2357, 39.7015),
+       ('Омск', 54.9885, 73.3242),
+       ('Краснодар', 45.0355, 38.9753),
+       ('Воронеж', 51.6720, 39.1843),
+       ('Пермь', 58.0105, 56.2502),
+       ('Волгоград', 48.7080, 44.5133),
+       ('Саратов', 51.5331, 46.0342),
+       ('Тюмень', 57.1522, 65.5272),
+       ('Тольятти', 53.5303, 49.3461),
+       ('Ижевск', 56.8527, 53.2115),
+       ('Барнаул', 53.3548, 83.7698),
+       ('Ульяновск', 54.3142, 48.4031),
+       ('Иркутск', 52.2870, 104.3050),
+       ('Хабаровск', 48.4802, 135.0719),
+       ('Ярославль', 57.6261, 39.8845),
+       ('Владивосток', 43.1155, 131.8855),
+       ('Махачкала', 42.9849, 47.5047),
+       ('Томск', 56.4846, 84.9476),
+       ('Оренбург', 51.7682, 55.0970),
+       ('Кемерово', 55.3547, 86.0873),
+       ('Новокузнецк', 53.7865, 87.1552),
+       ('Рязань', 54.6292, 39.7364),
+       ('Астрахань', 46.3497, 48.0408),
+       ('Набережные Челны', 55.7436, 52.3958),
+       ('Пенза', 53.1959, 45.0183),
+       ('Киров', 58.6036, 49.6680),
+       ('Липецк', 52.6031, 39.5708),
+       ('Чебоксары', 56.1439, 47.2489),
+       ('Калининград', 54.7104, 20.4522),
+       ('Тула', 54.1931, 37.6173),
+       ('Курск', 51.7373, 36.1874),
+       ('Ставрополь', 45.0445, 41.9691),
+       ('Сочи', 43.5855, 39.7231),
+       ('Улан-Удэ', 51.8335, 107.5841),
+       ('Тверь', 56.8587, 35.9176),
+       ('Магнитогорск', 53.4072, 58.9791),
+       ('Иваново', 57.0004, 40.9739),
+       ('Брянск', 53.2521, 34.3717),
+       ('Белгород', 50.5997, 36.5983),
+       ('Сургут', 61.2540, 73.3962),
+       ('Владимир', 56.1291, 40.4066),
+       ('Архангельск', 64.5393, 40.5170),
+       ('Смоленск', 54.7818, 32.0401),
+       ('Калуга', 54.5293, 36.2754),
+       ('Мурманск', 68.9585, 33.0827),
+       ('Вологда', 59.2181, 39.8886),
+       ('Якутск', 62.0355, 129.6755),
+       ('Петрозаводск', 61.7849, 34.3469),
+       ('Псков', 57.8136, 28.3496),
+       ('Великий Новгород', 58.5213, 31.2755);
+
+-- Optional coordinates of listings and users
+ALTER TABLE listings
+    ADD COLUMN latitude  DOUBLE PRECISION,
+    ADD COLUMN longitude DOUBLE PRECISION;
+
+ALTER TABLE users
+    ADD COLUMN latitude  DOUBLE PRECISION,
+    ADD COLUMN longitude DOUBLE PRECISION;
+
+-- Fill in coordinates of existing listings and users from their city
+UPDATE listings l
+SET latitude = c.latitude, longitude = c.longitude
+FROM cities c
+WHERE lower(c.name) = lower(trim(l.city));
+
+UPDATE users u
+SET latitude = c.latitude, longitude = c.longitude
+FROM cities c
+WHERE lower(c.name) = lower(trim(u.city));
+
+-- Narrows radius searches by latitude before distances are computed
+CREATE INDEX listings_location_idx ON listings (latitude, longitude) WHERE latitude IS NOT NULL;
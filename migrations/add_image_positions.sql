@@ -0,0 +1,12 @@
+-- Explicit order of listing images; the main image is always first (position 0)
+ALTER TABLE listing_images ADD COLUMN position INT NOT NULL DEFAULT 0;
+
+UPDATE listing_images li
+SET position = o.rn - 1
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY listing_id ORDER BY is_main DESC, created_at, id) AS rn
+    FROM listing_images
+) o
+WHERE li.id = o.id;
+
+CREATE INDEX listing_images_listing_position_idx ON listing_images (listing_id, position);
@@ -0,0 +1,4 @@
+-- Resized renditions of uploaded listing images; image_path holds the full size.
+-- Images added before processing and external URLs have no renditions.
+ALTER TABLE listing_images ADD COLUMN thumbnail_path TEXT;
+ALTER TABLE listing_images ADD COLUMN card_path TEXT;
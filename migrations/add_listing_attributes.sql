@@ -0,0 +1,60 @@
+-- Structured furniture attributes of listings
+ALTER TABLE listings
+    ADD COLUMN width_cm          DECIMAL,
+    ADD COLUMN depth_cm          DECIMAL,
+    ADD COLUMN height_cm         DECIMAL,
+    ADD COLUMN weight_kg         DECIMAL,
+    ADD COLUMN material          TEXT,
+    ADD COLUMN color             TEXT,
+    ADD COLUMN style             TEXT,
+    ADD COLUMN assembly_required BOOLEAN;
+
+CREATE INDEX listings_material_idx ON listings (material) WHERE material IS NOT NULL;
+CREATE INDEX listings_color_idx ON listings (color) WHERE color IS NOT NULL;
+CREATE INDEX listings_style_idx ON listings (style) WHERE style IS NOT NULL;
+
+-- Attributes applicable to each category; a category without rows uses its nearest ancestor's schema,
+-- or accepts every attribute as optional if no ancestor has one
+CREATE TABLE category_attributes
+(
+    category_id INT REFERENCES categories (id) ON DELETE CASCADE,
+    attribute   TEXT NOT NULL, -- width_cm, depth_cm, height_cm, weight_kg, material, color, style, assembly_required
+    required    BOOLEAN NOT NULL DEFAULT false,
+    PRIMARY KEY (category_id, attribute)
+);
+
+INSERT INTO category_attributes (category_id, attribute, required)
+SELECT c.id, a.attribute, a.required
+FROM categories c
+JOIN (VALUES ('Диваны и кресла', 'width_cm', false),
+             ('Диваны и кресла', 'depth_cm', false),
+             ('Диваны и кресла', 'height_cm', false),
+             ('Диваны и кресла', 'weight_kg', false),
+             ('Диваны и кресла', 'material', false),
+             ('Диваны и кресла', 'color', false),
+             ('Диваны и кресла', 'style', false),
+             ('Столы и стулья', 'width_cm', false),
+             ('Столы и стулья', 'depth_cm', false),
+             ('Столы и стулья', 'height_cm', false),
+             ('Столы и стулья', 'weight_kg', false),
+             ('Столы и стулья', 'material', false),
+             ('Столы и стулья', 'color', false),
+             ('Столы и стулья', 'style', false),
+             ('Столы и стулья', 'assembly_required', false),
+             ('Шкафы и комоды', 'width_cm', true),
+             ('Шкафы и комоды', 'depth_cm', true),
+             ('Шкафы и комоды', 'height_cm', true),
+             ('Шкафы и комоды', 'weight_kg', false),
+             ('Шкафы и комоды', 'material', false),
+             ('Шкафы и комоды', 'color', false),
+             ('Шкафы и комоды', 'style', false),
+             ('Шкафы и комоды', 'assembly_required', false),
+             ('Кровати и матрасы', 'width_cm', false),
+             ('Кровати и матрасы', 'depth_cm', false),
+             ('Кровати и матрасы', 'height_cm', false),
+             ('Кровати и матрасы', 'weight_kg', false),
+             ('Кровати и матрасы', 'material', false),
+             ('Кровати и матрасы', 'color', false),
+             ('Кровати и матрасы', 'style', false),
+             ('Кровати и матрасы', 'assembly_required', false))
+    AS a (category, attribute, required) ON a.category = c.name;
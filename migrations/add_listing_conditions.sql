@@ -0,0 +1,27 @@
+-- Canonical listing conditions: new, good, fair, poor. Known spellings and common
+-- synonyms are mapped (e.g. 'отличное' and 'как новое' to good, 'б/у' to fair); any
+-- other value, including an empty one, becomes 'fair'. The same aliases are accepted by
+-- the API (conditionAliases in internal/modules/listing/model/condition.go).
+UPDATE listings
+SET condition = CASE
+    WHEN lower(trim(condition)) IN ('new', 'новое', 'новый', 'новая', 'brand new', 'новое в упаковке') THEN 'new'
+    WHEN lower(trim(condition)) IN ('good', 'хорошее', 'хороший', 'хорошая', 'excellent', 'отличное', 'отличный',
+                                    'отличная', 'like new', 'как новое', 'как новый', 'как новая') THEN 'good'
+    WHEN lower(trim(condition)) IN ('poor', 'плохое', 'плохой', 'плохая', 'needs repair', 'требует ремонта') THEN 'poor'
+    ELSE 'fair'
+END;
+
+ALTER TABLE listings ADD CONSTRAINT listings_condition_check CHECK (condition IN ('new', 'good', 'fair', 'poor'));
+
+-- Saved search conditions are mapped the same way, with unknown values also becoming
+-- 'fair' so that saved searches keep matching the listings they were created for. An
+-- empty condition means no filter and is kept.
+UPDATE saved_searches
+SET condition = CASE
+    WHEN trim(condition) = '' THEN ''
+    WHEN lower(trim(condition)) IN ('new', 'новое', 'новый', 'новая', 'brand new', 'новое в упаковке') THEN 'new'
+    WHEN lower(trim(condition)) IN ('good', 'хорошее', 'хороший', 'хорошая', 'excellent', 'отличное', 'отличный',
+                                    'отличная', 'like new', 'как новое', 'как новый', 'как новая') THEN 'good'
+    WHEN lower(trim(condition)) IN ('poor', 'плохое', 'плохой', 'плохая', 'needs repair', 'требует ремонта') THEN 'poor'
+    ELSE 'fair'
+END;
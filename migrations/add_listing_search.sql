@@ -0,0 +1,7 @@
+-- Full-text search over listing titles and descriptions with Russian morphology.
+-- The document is computed in queries rather than stored, so it is an expression
+-- index that must match SearchVector in listing/repository.
+CREATE INDEX listings_search_idx ON listings USING GIN (
+    (setweight(to_tsvector('russian', COALESCE(title, '')), 'A') ||
+     setweight(to_tsvector('russian', COALESCE(description, '')), 'B'))
+);
@@ -0,0 +1,17 @@
+-- Image attachments in chat messages
+CREATE TABLE message_attachments
+(
+    id           SERIAL PRIMARY KEY,
+    message_id   INT REFERENCES messages (id) ON DELETE CASCADE,
+    file_path    TEXT   NOT NULL, -- relative to uploads/, inside the private folder
+    file_name    TEXT   NOT NULL,
+    content_type TEXT   NOT NULL,
+    size         BIGINT NOT NULL,
+    width        INT    NOT NULL DEFAULT 0,
+    height       INT    NOT NULL DEFAULT 0,
+    created_at   TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX message_attachments_message_id_idx ON message_attachments (message_id);
+
+COMMENT ON COLUMN messages.message_type IS 'Possible values: text, system, image';
@@ -0,0 +1,28 @@
+-- Users blocked by other users
+CREATE TABLE user_blocks
+(
+    blocker_id INT REFERENCES users (id) ON DELETE CASCADE,
+    blocked_id INT REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (blocker_id, blocked_id)
+);
+
+CREATE INDEX user_blocks_blocked_id_idx ON user_blocks (blocked_id);
+
+-- Reports about users for moderators
+CREATE TABLE user_reports
+(
+    id               SERIAL PRIMARY KEY,
+    reporter_id      INT REFERENCES users (id) ON DELETE CASCADE,
+    reported_user_id INT REFERENCES users (id) ON DELETE CASCADE,
+    chat_id          INT REFERENCES chats (id) ON DELETE SET NULL,
+    message_id       INT REFERENCES messages (id) ON DELETE SET NULL,
+    message_content  TEXT, -- copy of the reported message at the time of the report
+    reason           TEXT NOT NULL, -- spam, abuse, fraud, other
+    comment          TEXT,
+    status           TEXT NOT NULL DEFAULT 'open',
+    created_at       TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX user_reports_reported_user_id_idx ON user_reports (reported_user_id);
+CREATE INDEX user_reports_status_idx ON user_reports (status);
@@ -0,0 +1,27 @@
+-- Price offers and counter-offers on listings
+CREATE TABLE price_offers
+(
+    id         SERIAL PRIMARY KEY,
+    listing_id INT REFERENCES listings (id) ON DELETE CASCADE,
+    buyer_id   INT REFERENCES users (id) ON DELETE CASCADE,
+    seller_id  INT REFERENCES users (id) ON DELETE CASCADE,
+    author_id  INT REFERENCES users (id) ON DELETE CASCADE, -- who proposed this amount
+    parent_id  INT REFERENCES price_offers (id) ON DELETE SET NULL, -- offer this one counters
+    chat_id    INT REFERENCES chats (id) ON DELETE SET NULL,
+    amount     DECIMAL   NOT NULL CHECK (amount > 0),
+    status     TEXT      NOT NULL DEFAULT 'pending', -- pending, accepted, rejected, countered, cancelled, expired, completed
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX price_offers_listing_id_idx ON price_offers (listing_id);
+CREATE INDEX price_offers_buyer_id_idx ON price_offers (buyer_id);
+CREATE INDEX price_offers_seller_id_idx ON price_offers (seller_id);
+CREATE INDEX price_offers_pending_expires_at_idx ON price_offers (expires_at) WHERE status = 'pending';
+
+-- Purchases made at an agreed offer price
+ALTER TABLE purchases ADD COLUMN offer_id INT REFERENCES price_offers (id) ON DELETE SET NULL;
+
+-- System messages (e.g. offer updates) in chats
+ALTER TABLE messages ADD COLUMN message_type TEXT NOT NULL DEFAULT 'text'; -- text, system
@@ -0,0 +1,14 @@
+-- Password resets use a random token sent by email instead of a short code; only its
+-- hash is stored. Reset codes issued before can't be used anymore.
+CREATE TABLE password_reset_tokens
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INT       NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash TEXT      NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX password_reset_tokens_user_id_idx ON password_reset_tokens (user_id);
+
+DELETE FROM two_factor_codes WHERE purpose = 'reset_password';
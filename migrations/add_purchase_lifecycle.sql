@@ -0,0 +1,19 @@
+-- Purchase lifecycle: requested -> confirmed -> handed_over -> completed, plus cancelled and disputed
+ALTER TABLE purchases ADD COLUMN status TEXT NOT NULL DEFAULT 'requested'; -- requested, confirmed, handed_over, completed, cancelled, disputed
+ALTER TABLE purchases ADD COLUMN status_comment TEXT;
+ALTER TABLE purchases ADD COLUMN updated_at TIMESTAMP DEFAULT NOW();
+ALTER TABLE purchases ADD COLUMN confirmed_at TIMESTAMP;
+ALTER TABLE purchases ADD COLUMN handed_over_at TIMESTAMP;
+ALTER TABLE purchases ADD COLUMN completed_at TIMESTAMP;
+ALTER TABLE purchases ADD COLUMN cancelled_at TIMESTAMP;
+ALTER TABLE purchases ADD COLUMN disputed_at TIMESTAMP;
+
+-- Purchases made before the lifecycle was introduced are considered completed
+UPDATE purchases SET status = 'completed', updated_at = purchased_at, completed_at = purchased_at;
+
+-- A cancelled order frees the listing for other buyers, so only one non-cancelled
+-- purchase per listing is allowed
+ALTER TABLE purchases DROP CONSTRAINT purchases_listing_id_key;
+CREATE UNIQUE INDEX purchases_listing_id_open_idx ON purchases (listing_id) WHERE status <> 'cancelled';
+
+COMMENT ON COLUMN listings.status IS 'Possible values: active, reserved, sold, swapped';
@@ -0,0 +1,2 @@
+-- Fast lookup of unread messages for unread counters
+CREATE INDEX messages_unread_idx ON messages (chat_id, user_id) WHERE is_read = false;
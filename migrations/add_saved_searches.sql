@@ -0,0 +1,34 @@
+-- Saved searches with alerts about new matching listings
+CREATE TABLE saved_searches
+(
+    id                SERIAL PRIMARY KEY,
+    user_id           INT REFERENCES users (id) ON DELETE CASCADE,
+    name              TEXT NOT NULL,
+    keyword           TEXT NOT NULL DEFAULT '',
+    category_id       INT REFERENCES categories (id) ON DELETE SET NULL,
+    city              TEXT NOT NULL DEFAULT '',
+    condition         TEXT NOT NULL DEFAULT '',
+    min_price         DECIMAL,
+    max_price         DECIMAL,
+    frequency         TEXT NOT NULL DEFAULT 'instant', -- instant, daily
+    email_enabled     BOOLEAN NOT NULL DEFAULT true,
+    unsubscribe_token TEXT UNIQUE NOT NULL,
+    last_digest_at    TIMESTAMP,
+    created_at        TIMESTAMP DEFAULT NOW(),
+    updated_at        TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX saved_searches_user_id_idx ON saved_searches (user_id);
+
+-- New listings that matched a saved search
+CREATE TABLE saved_search_matches
+(
+    saved_search_id INT REFERENCES saved_searches (id) ON DELETE CASCADE,
+    listing_id      INT REFERENCES listings (id) ON DELETE CASCADE,
+    created_at      TIMESTAMP DEFAULT NOW(),
+    emailed_at      TIMESTAMP,
+    is_read         BOOLEAN DEFAULT false,
+    PRIMARY KEY (saved_search_id, listing_id)
+);
+
+CREATE INDEX saved_search_matches_unsent_idx ON saved_search_matches (saved_search_id) WHERE emailed_at IS NULL;
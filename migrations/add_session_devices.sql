@@ -0,0 +1,6 @@
+-- Device of each session, shown in the list of the user's active sessions
+ALTER TABLE sessions
+    ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+    ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX sessions_user_last_used_idx ON sessions (user_id, last_used_at DESC);
@@ -0,0 +1,16 @@
+-- Login sessions. Each holds the hash of its current refresh token; the previous hash is
+-- kept to detect reuse of a rotated token, which revokes the session.
+CREATE TABLE sessions
+(
+    id                  SERIAL PRIMARY KEY,
+    user_id             INT       NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    refresh_token_hash  TEXT      NOT NULL UNIQUE,
+    previous_token_hash TEXT,
+    expires_at          TIMESTAMP NOT NULL,
+    revoked_at          TIMESTAMP,
+    created_at          TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at        TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+CREATE INDEX sessions_previous_token_hash_idx ON sessions (previous_token_hash);
@@ -0,0 +1,34 @@
+-- Swap offers: a proposer offers one or more of their listings (plus an optional
+-- cash top-up) in exchange for a listing owned by another user
+CREATE TABLE swap_offers
+(
+    id          SERIAL PRIMARY KEY,
+    listing_id  INT REFERENCES listings (id) ON DELETE CASCADE,
+    proposer_id INT REFERENCES users (id) ON DELETE CASCADE,
+    owner_id    INT REFERENCES users (id) ON DELETE CASCADE,
+    author_id   INT REFERENCES users (id) ON DELETE CASCADE, -- who made this version of the offer
+    parent_id   INT REFERENCES swap_offers (id) ON DELETE SET NULL, -- offer this one counters
+    cash_top_up DECIMAL NOT NULL DEFAULT 0 CHECK (cash_top_up >= 0), -- paid by the proposer
+    message     TEXT,
+    status      TEXT    NOT NULL DEFAULT 'pending', -- pending, accepted, declined, countered, cancelled
+    created_at  TIMESTAMP DEFAULT NOW(),
+    updated_at  TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE swap_offer_items
+(
+    swap_id    INT REFERENCES swap_offers (id) ON DELETE CASCADE,
+    listing_id INT REFERENCES listings (id) ON DELETE CASCADE,
+    PRIMARY KEY (swap_id, listing_id)
+);
+
+CREATE INDEX swap_offers_listing_id_idx ON swap_offers (listing_id);
+CREATE INDEX swap_offers_proposer_id_idx ON swap_offers (proposer_id);
+CREATE INDEX swap_offers_owner_id_idx ON swap_offers (owner_id);
+CREATE INDEX swap_offer_items_listing_id_idx ON swap_offer_items (listing_id);
+
+-- Purchase history also records swaps
+ALTER TABLE purchases ADD COLUMN kind TEXT NOT NULL DEFAULT 'purchase'; -- purchase, swap
+ALTER TABLE purchases ADD COLUMN swap_id INT REFERENCES swap_offers (id) ON DELETE SET NULL;
+
+COMMENT ON COLUMN listings.status IS 'Possible values: active, sold, swapped';
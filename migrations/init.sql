@@ -33,7 +33,7 @@ CREATE TABLE listings
     title       TEXT    NOT NULL,
     description TEXT    NOT NULL,
     price       DECIMAL NOT NULL,
-    condition   TEXT    NOT NULL, -- новое, хорошее, среднее, плохое
+    condition   TEXT    NOT NULL, -- new, good, fair, poor (см. add_listing_conditions.sql)
     city        TEXT    NOT NULL,
     category_id INT REFERENCES categories (id),
     created_at  TIMESTAMP DEFAULT NOW(),
@@ -103,4 +103,490 @@ CREATE TABLE purchases
 -- Add index for better performance
 CREATE INDEX purchases_buyer_id_idx ON purchases (buyer_id);
 CREATE INDEX purchases_seller_id_idx ON purchases (seller_id);
-CREATE INDEX listings_status_idx ON listings (status);
\ No newline at end of file
+CREATE INDEX listings_status_idx ON listings (status);
+
+-- Swap offers: a proposer offers one or more of their listings (plus an optional
+-- cash top-up) in exchange for a listing owned by another user
+CREATE TABLE swap_offers
+(
+    id          SERIAL PRIMARY KEY,
+    listing_id  INT REFERENCES listings (id) ON DELETE CASCADE,
+    proposer_id INT REFERENCES users (id) ON DELETE CASCADE,
+    owner_id    INT REFERENCES users (id) ON DELETE CASCADE,
+    author_id   INT REFERENCES users (id) ON DELETE CASCADE, -- who made this version of the offer
+    parent_id   INT REFERENCES swap_offers (id) ON DELETE SET NULL, -- offer this one counters
+    cash_top_up DECIMAL NOT NULL DEFAULT 0 CHECK (cash_top_up >= 0), -- paid by the proposer
+    message     TEXT,
+    status      TEXT    NOT NULL DEFAULT 'pending', -- pending, accepted, declined, countered, cancelled
+    created_at  TIMESTAMP DEFAULT NOW(),
+    updated_at  TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE swap_offer_items
+(
+    swap_id    INT REFERENCES swap_offers (id) ON DELETE CASCADE,
+    listing_id INT REFERENCES listings (id) ON DELETE CASCADE,
+    PRIMARY KEY (swap_id, listing_id)
+);
+
+CREATE INDEX swap_offers_listing_id_idx ON swap_offers (listing_id);
+CREATE INDEX swap_offers_proposer_id_idx ON swap_offers (proposer_id);
+CREATE INDEX swap_offers_owner_id_idx ON swap_offers (owner_id);
+CREATE INDEX swap_offer_items_listing_id_idx ON swap_offer_items (listing_id);
+
+-- Purchase history also records swaps
+ALTER TABLE purchases ADD COLUMN kind TEXT NOT NULL DEFAULT 'purchase'; -- purchase, swap
+ALTER TABLE purchases ADD COLUMN swap_id INT REFERENCES swap_offers (id) ON DELETE SET NULL;
+
+COMMENT ON COLUMN listings.status IS 'Possible values: active, sold, swapped';
+
+-- Price offers and counter-offers on listings
+CREATE TABLE price_offers
+(
+    id         SERIAL PRIMARY KEY,
+    listing_id INT REFERENCES listings (id) ON DELETE CASCADE,
+    buyer_id   INT REFERENCES users (id) ON DELETE CASCADE,
+    seller_id  INT REFERENCES users (id) ON DELETE CASCADE,
+    author_id  INT REFERENCES users (id) ON DELETE CASCADE, -- who proposed this amount
+    parent_id  INT REFERENCES price_offers (id) ON DELETE SET NULL, -- offer this one counters
+    chat_id    INT REFERENCES chats (id) ON DELETE SET NULL,
+    amount     DECIMAL   NOT NULL CHECK (amount > 0),
+    status     TEXT      NOT NULL DEFAULT 'pending', -- pending, accepted, rejected, countered, cancelled, expired, completed
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX price_offers_listing_id_idx ON price_offers (listing_id);
+CREATE INDEX price_offers_buyer_id_idx ON price_offers (buyer_id);
+CREATE INDEX price_offers_seller_id_idx ON price_offers (seller_id);
+CREATE INDEX price_offers_pending_expires_at_idx ON price_offers (expires_at) WHERE status = 'pending';
+
+-- Purchases made at an agreed offer price
+ALTER TABLE purchases ADD COLUMN offer_id INT REFERENCES price_offers (id) ON DELETE SET NULL;
+
+-- System messages (e.g. offer updates) in chats
+ALTER TABLE messages ADD COLUMN message_type TEXT NOT NULL DEFAULT 'text'; -- text, system
+
+-- Purchase lifecycle: requested -> confirmed -> handed_over -> completed, plus cancelled and disputed
+ALTER TABLE purchases ADD COLUMN status TEXT NOT NULL DEFAULT 'requested'; -- requested, confirmed, handed_over, completed, cancelled, disputed
+ALTER TABLE purchases ADD COLUMN status_comment TEXT;
+ALTER TABLE purchases ADD COLUMN updated_at TIMESTAMP DEFAULT NOW();
+ALTER TABLE purchases ADD COLUMN confirmed_at TIMESTAMP;
+ALTER TABLE purchases ADD COLUMN handed_over_at TIMESTAMP;
+ALTER TABLE purchases ADD COLUMN completed_at TIMESTAMP;
+ALTER TABLE purchases ADD COLUMN cancelled_at TIMESTAMP;
+ALTER TABLE purchases ADD COLUMN disputed_at TIMESTAMP;
+
+-- Purchases made before the lifecycle was introduced are considered completed
+UPDATE purchases SET status = 'completed', updated_at = purchased_at, completed_at = purchased_at;
+
+-- A cancelled order frees the listing for other buyers, so only one non-cancelled
+-- purchase per listing is allowed
+ALTER TABLE purchases DROP CONSTRAINT purchases_listing_id_key;
+CREATE UNIQUE INDEX purchases_listing_id_open_idx ON purchases (listing_id) WHERE status <> 'cancelled';
+
+COMMENT ON COLUMN listings.status IS 'Possible values: active, reserved, sold, swapped';
+
+-- Fast lookup of unread messages for unread counters
+CREATE INDEX messages_unread_idx ON messages (chat_id, user_id) WHERE is_read = false;
+
+-- Last activity in chats for ordering the chat list
+ALTER TABLE chats ADD COLUMN last_message_at TIMESTAMP DEFAULT NOW();
+ALTER TABLE chats ADD COLUMN last_message_user_id INT REFERENCES users (id) ON DELETE SET NULL;
+
+UPDATE chats c
+SET last_message_at      = COALESCE(m.created_at, c.created_at),
+    last_message_user_id = m.user_id
+FROM chats c2
+LEFT JOIN LATERAL (SELECT created_at, user_id FROM messages WHERE chat_id = c2.id ORDER BY id DESC LIMIT 1) m ON true
+WHERE c.id = c2.id;
+
+ALTER TABLE chats ALTER COLUMN last_message_at SET NOT NULL;
+
+CREATE INDEX chats_buyer_last_message_idx ON chats (buyer_id, last_message_at DESC, id DESC);
+CREATE INDEX chats_seller_last_message_idx ON chats (seller_id, last_message_at DESC, id DESC);
+
+-- Image attachments in chat messages
+CREATE TABLE message_attachments
+(
+    id           SERIAL PRIMARY KEY,
+    message_id   INT REFERENCES messages (id) ON DELETE CASCADE,
+    file_path    TEXT   NOT NULL, -- relative to uploads/, inside the private folder
+    file_name    TEXT   NOT NULL,
+    content_type TEXT   NOT NULL,
+    size         BIGINT NOT NULL,
+    width        INT    NOT NULL DEFAULT 0,
+    height       INT    NOT NULL DEFAULT 0,
+    created_at   TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX message_attachments_message_id_idx ON message_attachments (message_id);
+
+COMMENT ON COLUMN messages.message_type IS 'Possible values: text, system, image';
+
+-- Users blocked by other users
+CREATE TABLE user_blocks
+(
+    blocker_id INT REFERENCES users (id) ON DELETE CASCADE,
+    blocked_id INT REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (blocker_id, blocked_id)
+);
+
+CREATE INDEX user_blocks_blocked_id_idx ON user_blocks (blocked_id);
+
+-- Reports about users for moderators
+CREATE TABLE user_reports
+(
+    id               SERIAL PRIMARY KEY,
+    reporter_id      INT REFERENCES users (id) ON DELETE CASCADE,
+    reported_user_id INT REFERENCES users (id) ON DELETE CASCADE,
+    chat_id          INT REFERENCES chats (id) ON DELETE SET NULL,
+    message_id       INT REFERENCES messages (id) ON DELETE SET NULL,
+    message_content  TEXT, -- copy of the reported message at the time of the report
+    reason           TEXT NOT NULL, -- spam, abuse, fraud, other
+    comment          TEXT,
+    status           TEXT NOT NULL DEFAULT 'open',
+    created_at       TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX user_reports_reported_user_id_idx ON user_reports (reported_user_id);
+CREATE INDEX user_reports_status_idx ON user_reports (status);
+
+-- Full-text search over listing titles and descriptions with Russian morphology.
+-- The document is computed in queries rather than stored, so it is an expression
+-- index that must match SearchVector in listing/repository.
+CREATE INDEX listings_search_idx ON listings USING GIN (
+    (setweight(to_tsvector('russian', COALESCE(title, '')), 'A') ||
+     setweight(to_tsvector('russian', COALESCE(description, '')), 'B'))
+);
+
+-- Saved searches with alerts about new matching listings
+CREATE TABLE saved_searches
+(
+    id                SERIAL PRIMARY KEY,
+    user_id           INT REFERENCES users (id) ON DELETE CASCADE,
+    name              TEXT NOT NULL,
+    keyword           TEXT NOT NULL DEFAULT '',
+    category_id       INT REFERENCES categories (id) ON DELETE SET NULL,
+    city              TEXT NOT NULL DEFAULT '',
+    condition         TEXT NOT NULL DEFAULT '',
+    min_price         DECIMAL,
+    max_price         DECIMAL,
+    frequency         TEXT NOT NULL DEFAULT 'instant', -- instant, daily
+    email_enabled     BOOLEAN NOT NULL DEFAULT true,
+    unsubscribe_token TEXT UNIQUE NOT NULL,
+    last_digest_at    TIMESTAMP,
+    created_at        TIMESTAMP DEFAULT NOW(),
+    updated_at        TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX saved_searches_user_id_idx ON saved_searches (user_id);
+
+-- New listings that matched a saved search
+CREATE TABLE saved_search_matches
+(
+    saved_search_id INT REFERENCES saved_searches (id) ON DELETE CASCADE,
+    listing_id      INT REFERENCES listings (id) ON DELETE CASCADE,
+    created_at      TIMESTAMP DEFAULT NOW(),
+    emailed_at      TIMESTAMP,
+    is_read         BOOLEAN DEFAULT false,
+    PRIMARY KEY (saved_search_id, listing_id)
+);
+
+CREATE INDEX saved_search_matches_unsent_idx ON saved_search_matches (saved_search_id) WHERE emailed_at IS NULL;
+
+-- Coordinates of Russian cities, used when a listing or user has a city but no coordinates
+CREATE TABLE cities
+(
+    name      TEXT PRIMARY KEY,
+    latitude  DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL
+);
+
+CREATE UNIQUE INDEX cities_lower_name_idx ON cities (lower(name));
+
+INSERT INTO cities (name, latitude, longitude)
+VALUES ('Москва', 55.7558, 37.6173),
+       ('Санкт-Петербург', 59.9343, 30.3351),
+       ('Новосибирск', 55.0084, 82.9357),
+       ('Екатеринбург', 56.8389, 60.6057),
+       ('Казань', 55.7963, 49.1088),
+       ('Нижний Новгород', 56.2965, 43.9361),
+       ('Челябинск', 55.1644, 61.4368),
+       ('Красноярск', 56.0153, 92.8932),
+       ('Самара', 53.1959, 50.1002),
+       ('Уфа', 54.7388, 55.9721),
+       ('Ростов-на-Дону', 47.2357, 39.7015),
+       ('Омск', 54.9885, 73.3242),
+       ('Краснодар', 45.0355, 38.9753),
+       ('Воронеж', 51.6720, 39.1843),
+       ('Пермь', 58.0105, 56.2502),
+       ('Волгоград', 48.7080, 44.5133),
+       ('Саратов', 51.5331, 46.0342),
+       ('Тюмень', 57.1522, 65.5272),
+       ('Тольятти', 53.5303, 49.3461),
+       ('Ижевск', 56.8527, 53.2115),
+       ('Барнаул', 53.3548, 83.7698),
+       ('Ульяновск', 54.3142, 48.4031),
+       ('Иркутск', 52.2870, 104.3050),
+       ('Хабаровск', 48.4802, 135.0719),
+       ('Ярославль', 57.6261, 39.8845),
+       ('Владивосток', 43.1155, 131.8855),
+       ('Махачкала', 42.9849, 47.5047),
+       ('Томск', 56.4846, 84.9476),
+       ('Оренбург', 51.7682, 55.0970),
+       ('Кемерово', 55.3547, 86.0873),
+       ('Новокузнецк', 53.7865, 87.1552),
+       ('Рязань', 54.6292, 39.7364),
+       ('Астрахань', 46.3497, 48.0408),
+       ('Набережные Челны', 55.7436, 52.3958),
+       ('Пенза', 53.1959, 45.0183),
+       ('Киров', 58.6036, 49.6680),
+       ('Липецк', 52.6031, 39.5708),
+       ('Чебоксары', 56.1439, 47.2489),
+       ('Калининград', 54.7104, 20.4522),
+       ('Тула', 54.1931, 37.6173),
+       ('Курск', 51.7373, 36.1874),
+       ('Ставрополь', 45.0445, 41.9691),
+       ('Сочи', 43.5855, 39.7231),
+       ('Улан-Удэ', 51.8335, 107.5841),
+       ('Тверь', 56.8587, 35.9176),
+       ('Магнитогорск', 53.4072, 58.9791),
+       ('Иваново', 57.0004, 40.9739),
+       ('Брянск', 53.2521, 34.3717),
+       ('Белгород', 50.5997, 36.5983),
+       ('Сургут', 61.2540, 73.3962),
+       ('Владимир', 56.1291, 40.4066),
+       ('Архангельск', 64.5393, 40.5170),
+       ('Смоленск', 54.7818, 32.0401),
+       ('Калуга', 54.5293, 36.2754),
+       ('Мурманск', 68.9585, 33.0827),
+       ('Вологда', 59.2181, 39.8886),
+       ('Якутск', 62.0355, 129.6755),
+       ('Петрозаводск', 61.7849, 34.3469),
+       ('Псков', 57.8136, 28.3496),
+       ('Великий Новгород', 58.5213, 31.2755);
+
+-- Optional coordinates of listings and users
+ALTER TABLE listings
+    ADD COLUMN latitude  DOUBLE PRECISION,
+    ADD COLUMN longitude DOUBLE PRECISION;
+
+ALTER TABLE users
+    ADD COLUMN latitude  DOUBLE PRECISION,
+    ADD COLUMN longitude DOUBLE PRECISION;
+
+-- Fill in coordinates of existing listings and users from their city
+UPDATE listings l
+SET latitude = c.latitude, longitude = c.longitude
+FROM cities c
+WHERE lower(c.name) = lower(trim(l.city));
+
+UPDATE users u
+SET latitude = c.latitude, longitude = c.longitude
+FROM cities c
+WHERE lower(c.name) = lower(trim(u.city));
+
+-- Narrows radius searches by latitude before distances are computed
+CREATE INDEX listings_location_idx ON listings (latitude, longitude) WHERE latitude IS NOT NULL;
+
+-- Structured furniture attributes of listings
+ALTER TABLE listings
+    ADD COLUMN width_cm          DECIMAL,
+    ADD COLUMN depth_cm          DECIMAL,
+    ADD COLUMN height_cm         DECIMAL,
+    ADD COLUMN weight_kg         DECIMAL,
+    ADD COLUMN material          TEXT,
+    ADD COLUMN color             TEXT,
+    ADD COLUMN style             TEXT,
+    ADD COLUMN assembly_required BOOLEAN;
+
+CREATE INDEX listings_material_idx ON listings (material) WHERE material IS NOT NULL;
+CREATE INDEX listings_color_idx ON listings (color) WHERE color IS NOT NULL;
+CREATE INDEX listings_style_idx ON listings (style) WHERE style IS NOT NULL;
+
+-- Attributes applicable to each category; a category without rows uses its nearest ancestor's schema,
+-- or accepts every attribute as optional if no ancestor has one
+CREATE TABLE category_attributes
+(
+    category_id INT REFERENCES categories (id) ON DELETE CASCADE,
+    attribute   TEXT NOT NULL, -- width_cm, depth_cm, height_cm, weight_kg, material, color, style, assembly_required
+    required    BOOLEAN NOT NULL DEFAULT false,
+    PRIMARY KEY (category_id, attribute)
+);
+
+INSERT INTO category_attributes (category_id, attribute, required)
+SELECT c.id, a.attribute, a.required
+FROM categories c
+JOIN (VALUES ('Диваны и кресла', 'width_cm', false),
+             ('Диваны и кресла', 'depth_cm', false),
+             ('Диваны и кресла', 'height_cm', false),
+             ('Диваны и кресла', 'weight_kg', false),
+             ('Диваны и кресла', 'material', false),
+             ('Диваны и кресла', 'color', false),
+             ('Диваны и кресла', 'style', false),
+             ('Столы и стулья', 'width_cm', false),
+             ('Столы и стулья', 'depth_cm', false),
+             ('Столы и стулья', 'height_cm', false),
+             ('Столы и стулья', 'weight_kg', false),
+             ('Столы и стулья', 'material', false),
+             ('Столы и стулья', 'color', false),
+             ('Столы и стулья', 'style', false),
+             ('Столы и стулья', 'assembly_required', false),
+             ('Шкафы и комоды', 'width_cm', true),
+             ('Шкафы и комоды', 'depth_cm', true),
+             ('Шкафы и комоды', 'height_cm', true),
+             ('Шкафы и комоды', 'weight_kg', false),
+             ('Шкафы и комоды', 'material', false),
+             ('Шкафы и комоды', 'color', false),
+             ('Шкафы и комоды', 'style', false),
+             ('Шкафы и комоды', 'assembly_required', false),
+             ('Кровати и матрасы', 'width_cm', false),
+             ('Кровати и матрасы', 'depth_cm', false),
+             ('Кровати и матрасы', 'height_cm', false),
+             ('Кровати и матрасы', 'weight_kg', false),
+             ('Кровати и матрасы', 'material', false),
+             ('Кровати и матрасы', 'color', false),
+             ('Кровати и матрасы', 'style', false),
+             ('Кровати и матрасы', 'assembly_required', false))
+    AS a (category, attribute, required) ON a.category = c.name;
+
+-- Nested categories with slugs, icons and sort order
+ALTER TABLE categories
+    ADD COLUMN parent_id  INT REFERENCES categories (id) ON DELETE RESTRICT,
+    ADD COLUMN slug       TEXT,
+    ADD COLUMN icon       TEXT NOT NULL DEFAULT '',
+    ADD COLUMN sort_order INT  NOT NULL DEFAULT 0,
+    ADD COLUMN created_at TIMESTAMP DEFAULT NOW(),
+    ADD COLUMN updated_at TIMESTAMP DEFAULT NOW();
+
+UPDATE categories c
+SET slug = s.slug, sort_order = s.sort_order
+FROM (VALUES ('Диваны и кресла', 'sofas-and-armchairs', 10),
+             ('Столы и стулья', 'tables-and-chairs', 20),
+             ('Шкафы и комоды', 'wardrobes-and-dressers', 30),
+             ('Кровати и матрасы', 'beds-and-mattresses', 40),
+             ('Другое', 'other', 1000))
+    AS s (name, slug, sort_order)
+WHERE c.name = s.name;
+
+UPDATE categories SET slug = 'category-' || id WHERE slug IS NULL;
+
+ALTER TABLE categories ALTER COLUMN slug SET NOT NULL;
+ALTER TABLE categories ADD CONSTRAINT categories_slug_key UNIQUE (slug);
+
+-- Names only need to be unique among siblings
+ALTER TABLE categories DROP CONSTRAINT categories_name_key;
+CREATE UNIQUE INDEX categories_parent_name_idx ON categories (COALESCE(parent_id, 0), name);
+
+CREATE INDEX categories_parent_id_idx ON categories (parent_id);
+
+-- Administrators manage categories; grant with UPDATE users SET is_admin = true WHERE email = '...'
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT false;
+
+-- Canonical listing conditions: new, good, fair, poor. Known spellings and common
+-- synonyms are mapped (e.g. 'отличное' and 'как новое' to good, 'б/у' to fair); any
+-- other value, including an empty one, becomes 'fair'. The same aliases are accepted by
+-- the API (conditionAliases in internal/modules/listing/model/condition.go).
+UPDATE listings
+SET condition = CASE
+    WHEN lower(trim(condition)) IN ('new', 'новое', 'новый', 'новая', 'brand new', 'новое в упаковке') THEN 'new'
+    WHEN lower(trim(condition)) IN ('good', 'хорошее', 'хороший', 'хорошая', 'excellent', 'отличное', 'отличный',
+                                    'отличная', 'like new', 'как новое', 'как новый', 'как новая') THEN 'good'
+    WHEN lower(trim(condition)) IN ('poor', 'плохое', 'плохой', 'плохая', 'needs repair', 'требует ремонта') THEN 'poor'
+    ELSE 'fair'
+END;
+
+ALTER TABLE listings ADD CONSTRAINT listings_condition_check CHECK (condition IN ('new', 'good', 'fair', 'poor'));
+
+-- Saved search conditions are mapped the same way, with unknown values also becoming
+-- 'fair' so that saved searches keep matching the listings they were created for. An
+-- empty condition means no filter and is kept.
+UPDATE saved_searches
+SET condition = CASE
+    WHEN trim(condition) = '' THEN ''
+    WHEN lower(trim(condition)) IN ('new', 'новое', 'новый', 'новая', 'brand new', 'новое в упаковке') THEN 'new'
+    WHEN lower(trim(condition)) IN ('good', 'хорошее', 'хороший', 'хорошая', 'excellent', 'отличное', 'отличный',
+                                    'отличная', 'like new', 'как новое', 'как новый', 'как новая') THEN 'good'
+    WHEN lower(trim(condition)) IN ('poor', 'плохое', 'плохой', 'плохая', 'needs repair', 'требует ремонта') THEN 'poor'
+    ELSE 'fair'
+END;
+
+-- Resized renditions of uploaded listing images; image_path holds the full size.
+-- Images added before processing and external URLs have no renditions.
+ALTER TABLE listing_images ADD COLUMN thumbnail_path TEXT;
+ALTER TABLE listing_images ADD COLUMN card_path TEXT;
+
+-- Explicit order of listing images; the main image is always first (position 0)
+ALTER TABLE listing_images ADD COLUMN position INT NOT NULL DEFAULT 0;
+
+UPDATE listing_images li
+SET position = o.rn - 1
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY listing_id ORDER BY is_main DESC, created_at, id) AS rn
+    FROM listing_images
+) o
+WHERE li.id = o.id;
+
+CREATE INDEX listing_images_listing_position_idx ON listing_images (listing_id, position);
+
+-- Login sessions. Each holds the hash of its current refresh token; the previous hash is
+-- kept to detect reuse of a rotated token, which revokes the session.
+CREATE TABLE sessions
+(
+    id                  SERIAL PRIMARY KEY,
+    user_id             INT       NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    refresh_token_hash  TEXT      NOT NULL UNIQUE,
+    previous_token_hash TEXT,
+    expires_at          TIMESTAMP NOT NULL,
+    revoked_at          TIMESTAMP,
+    created_at          TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at        TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+CREATE INDEX sessions_previous_token_hash_idx ON sessions (previous_token_hash);
+
+-- Device of each session, shown in the list of the user's active sessions
+ALTER TABLE sessions
+    ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+    ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX sessions_user_last_used_idx ON sessions (user_id, last_used_at DESC);
+
+-- Verification and 2FA codes are checked by the challenge ID returned when the code is
+-- sent, instead of by email and code
+ALTER TABLE two_factor_codes
+    ADD COLUMN challenge_id UUID NOT NULL DEFAULT gen_random_uuid();
+
+CREATE UNIQUE INDEX two_factor_codes_challenge_id_idx ON two_factor_codes (challenge_id);
+
+-- One-time codes are stored hashed with the purpose they were issued for and a count
+-- of failed attempts. Codes issued before are plain text and can't be kept.
+DELETE FROM two_factor_codes;
+
+ALTER TABLE two_factor_codes
+    RENAME COLUMN code TO code_hash;
+
+ALTER TABLE two_factor_codes
+    ADD COLUMN purpose  TEXT NOT NULL,
+    ADD COLUMN attempts INT  NOT NULL DEFAULT 0;
+
+CREATE INDEX two_factor_codes_user_purpose_idx ON two_factor_codes (user_id, purpose);
+
+-- Password resets use a random token sent by email instead of a short code; only its
+-- hash is stored. Reset codes issued before can't be used anymore.
+CREATE TABLE password_reset_tokens
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INT       NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash TEXT      NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX password_reset_tokens_user_id_idx ON password_reset_tokens (user_id);
+
+DELETE FROM two_factor_codes WHERE purpose = 'reset_password';
@@ -36,60 +36,60 @@ INSERT INTO users (email, password_hash, name, last_name, city, avatar, is_verif
 -- Создание объявлений (по 3-5 для каждого пользователя)
 INSERT INTO listings (user_id, title, description, price, condition, city, category_id, status, created_at, updated_at) VALUES
 -- Пользователь 1
-(1, 'Кожаный диван', 'Кожаный диван в отличном состоянии, использовался меньше года', 25000, 'хорошее', 'Москва', 1, 'active', NOW() - INTERVAL '29 days', NOW() - INTERVAL '29 days'),
-(1, 'Обеденный стол', 'Деревянный обеденный стол, вместимость 6 человек', 12000, 'среднее', 'Москва', 2, 'active', NOW() - INTERVAL '28 days', NOW() - INTERVAL '28 days'),
-(1, 'Кровать с матрасом', 'Двуспальная кровать с ортопедическим матрасом', 18000, 'хорошее', 'Москва', 4, 'active', NOW() - INTERVAL '27 days', NOW() - INTERVAL '27 days'),
-(1, 'Книжный шкаф', 'Вместительный книжный шкаф из натурального дерева', 8500, 'среднее', 'Москва', 3, 'active', NOW() - INTERVAL '26 days', NOW() - INTERVAL '26 days'),
+(1, 'Кожаный диван', 'Кожаный диван в отличном состоянии, использовался меньше года', 25000, 'good', 'Москва', 1, 'active', NOW() - INTERVAL '29 days', NOW() - INTERVAL '29 days'),
+(1, 'Обеденный стол', 'Деревянный обеденный стол, вместимость 6 человек', 12000, 'fair', 'Москва', 2, 'active', NOW() - INTERVAL '28 days', NOW() - INTERVAL '28 days'),
+(1, 'Кровать с матрасом', 'Двуспальная кровать с ортопедическим матрасом', 18000, 'good', 'Москва', 4, 'active', NOW() - INTERVAL '27 days', NOW() - INTERVAL '27 days'),
+(1, 'Книжный шкаф', 'Вместительный книжный шкаф из натурального дерева', 8500, 'fair', 'Москва', 3, 'active', NOW() - INTERVAL '26 days', NOW() - INTERVAL '26 days'),
 
 -- Пользователь 2
-(2, 'Кресло-качалка', 'Удобное кресло-качалка для отдыха', 7500, 'хорошее', 'Санкт-Петербург', 1, 'active', NOW() - INTERVAL '28 days', NOW() - INTERVAL '28 days'),
-(2, 'Компьютерный стол', 'Эргономичный компьютерный стол с полками', 6000, 'среднее', 'Санкт-Петербург', 2, 'active', NOW() - INTERVAL '27 days', NOW() - INTERVAL '27 days'),
-(2, 'Комод для одежды', 'Комод с 5 ящиками, цвет - венге', 9000, 'хорошее', 'Санкт-Петербург', 3, 'active', NOW() - INTERVAL '26 days', NOW() - INTERVAL '26 days'),
+(2, 'Кресло-качалка', 'Удобное кресло-качалка для отдыха', 7500, 'good', 'Санкт-Петербург', 1, 'active', NOW() - INTERVAL '28 days', NOW() - INTERVAL '28 days'),
+(2, 'Компьютерный стол', 'Эргономичный компьютерный стол с полками', 6000, 'fair', 'Санкт-Петербург', 2, 'active', NOW() - INTERVAL '27 days', NOW() - INTERVAL '27 days'),
+(2, 'Комод для одежды', 'Комод с 5 ящиками, цвет - венге', 9000, 'good', 'Санкт-Петербург', 3, 'active', NOW() - INTERVAL '26 days', NOW() - INTERVAL '26 days'),
 
 -- Пользователь 3
-(3, 'Диван-кровать', 'Раскладной диван-кровать, механизм "еврокнижка"', 15000, 'среднее', 'Новосибирск', 1, 'active', NOW() - INTERVAL '27 days', NOW() - INTERVAL '27 days'),
-(3, 'Журнальный столик', 'Стильный журнальный столик из стекла и металла', 4500, 'хорошее', 'Новосибирск', 2, 'active', NOW() - INTERVAL '26 days', NOW() - INTERVAL '26 days'),
-(3, 'Шкаф-купе', 'Вместительный шкаф-купе с зеркальными дверями', 22000, 'хорошее', 'Новосибирск', 3, 'active', NOW() - INTERVAL '25 days', NOW() - INTERVAL '25 days'),
-(3, 'Пуфик для прихожей', 'Практичный пуфик с отсеком для хранения', 3000, 'новое', 'Новосибирск', 5, 'active', NOW() - INTERVAL '24 days', NOW() - INTERVAL '24 days'),
+(3, 'Диван-кровать', 'Раскладной диван-кровать, механизм "еврокнижка"', 15000, 'fair', 'Новосибирск', 1, 'active', NOW() - INTERVAL '27 days', NOW() - INTERVAL '27 days'),
+(3, 'Журнальный столик', 'Стильный журнальный столик из стекла и металла', 4500, 'good', 'Новосибирск', 2, 'active', NOW() - INTERVAL '26 days', NOW() - INTERVAL '26 days'),
+(3, 'Шкаф-купе', 'Вместительный шкаф-купе с зеркальными дверями', 22000, 'good', 'Новосибирск', 3, 'active', NOW() - INTERVAL '25 days', NOW() - INTERVAL '25 days'),
+(3, 'Пуфик для прихожей', 'Практичный пуфик с отсеком для хранения', 3000, 'new', 'Новосибирск', 5, 'active', NOW() - INTERVAL '24 days', NOW() - INTERVAL '24 days'),
 
 -- Пользователь 4
-(4, 'Мягкое кресло', 'Уютное мягкое кресло, обивка - микровелюр', 8200, 'хорошее', 'Екатеринбург', 1, 'active', NOW() - INTERVAL '26 days', NOW() - INTERVAL '26 days'),
-(4, 'Барный стул', 'Высокий барный стул, регулируемая высота', 3500, 'среднее', 'Екатеринбург', 2, 'active', NOW() - INTERVAL '25 days', NOW() - INTERVAL '25 days'),
-(4, 'Детская кроватка', 'Детская кроватка с матрасом, бортиками и ящиком', 7000, 'хорошее', 'Екатеринбург', 4, 'active', NOW() - INTERVAL '24 days', NOW() - INTERVAL '24 days'),
+(4, 'Мягкое кресло', 'Уютное мягкое кресло, обивка - микровелюр', 8200, 'good', 'Екатеринбург', 1, 'active', NOW() - INTERVAL '26 days', NOW() - INTERVAL '26 days'),
+(4, 'Барный стул', 'Высокий барный стул, регулируемая высота', 3500, 'fair', 'Екатеринбург', 2, 'active', NOW() - INTERVAL '25 days', NOW() - INTERVAL '25 days'),
+(4, 'Детская кроватка', 'Детская кроватка с матрасом, бортиками и ящиком', 7000, 'good', 'Екатеринбург', 4, 'active', NOW() - INTERVAL '24 days', NOW() - INTERVAL '24 days'),
 
 -- Пользователь 5
-(5, 'Угловой диван', 'Большой угловой диван, раскладывается', 28000, 'хорошее', 'Казань', 1, 'active', NOW() - INTERVAL '25 days', NOW() - INTERVAL '25 days'),
-(5, 'Обеденные стулья', 'Комплект из 4 обеденных стульев, дерево и ткань', 10000, 'среднее', 'Казань', 2, 'active', NOW() - INTERVAL '24 days', NOW() - INTERVAL '24 days'),
-(5, 'Тумба под ТВ', 'Современная тумба под ТВ с ящиками', 6500, 'хорошее', 'Казань', 3, 'active', NOW() - INTERVAL '23 days', NOW() - INTERVAL '23 days'),
-(5, 'Полка настенная', 'Декоративная полка для книг и сувениров', 2200, 'хорошее', 'Казань', 5, 'active', NOW() - INTERVAL '22 days', NOW() - INTERVAL '22 days'),
+(5, 'Угловой диван', 'Большой угловой диван, раскладывается', 28000, 'good', 'Казань', 1, 'active', NOW() - INTERVAL '25 days', NOW() - INTERVAL '25 days'),
+(5, 'Обеденные стулья', 'Комплект из 4 обеденных стульев, дерево и ткань', 10000, 'fair', 'Казань', 2, 'active', NOW() - INTERVAL '24 days', NOW() - INTERVAL '24 days'),
+(5, 'Тумба под ТВ', 'Современная тумба под ТВ с ящиками', 6500, 'good', 'Казань', 3, 'active', NOW() - INTERVAL '23 days', NOW() - INTERVAL '23 days'),
+(5, 'Полка настенная', 'Декоративная полка для книг и сувениров', 2200, 'good', 'Казань', 5, 'active', NOW() - INTERVAL '22 days', NOW() - INTERVAL '22 days'),
 
 -- Пользователь 6
-(6, 'Офисное кресло', 'Эргономичное офисное кресло на колесиках', 5500, 'среднее', 'Самара', 1, 'active', NOW() - INTERVAL '24 days', NOW() - INTERVAL '24 days'),
-(6, 'Письменный стол', 'Классический письменный стол с ящиками', 7800, 'хорошее', 'Самара', 2, 'active', NOW() - INTERVAL '23 days', NOW() - INTERVAL '23 days'),
-(6, 'Шкаф для одежды', 'Двухстворчатый шкаф для одежды, цвет - белый', 11000, 'хорошее', 'Самара', 3, 'active', NOW() - INTERVAL '22 days', NOW() - INTERVAL '22 days'),
+(6, 'Офисное кресло', 'Эргономичное офисное кресло на колесиках', 5500, 'fair', 'Самара', 1, 'active', NOW() - INTERVAL '24 days', NOW() - INTERVAL '24 days'),
+(6, 'Письменный стол', 'Классический письменный стол с ящиками', 7800, 'good', 'Самара', 2, 'active', NOW() - INTERVAL '23 days', NOW() - INTERVAL '23 days'),
+(6, 'Шкаф для одежды', 'Двухстворчатый шкаф для одежды, цвет - белый', 11000, 'good', 'Самара', 3, 'active', NOW() - INTERVAL '22 days', NOW() - INTERVAL '22 days'),
 
 -- Пользователь 7
-(7, 'Мягкий уголок', 'Мягкий уголок с механизмом трансформации', 20000, 'среднее', 'Ростов-на-Дону', 1, 'active', NOW() - INTERVAL '23 days', NOW() - INTERVAL '23 days'),
-(7, 'Стеклянный стол', 'Стильный обеденный стол со стеклянной столешницей', 9500, 'хорошее', 'Ростов-на-Дону', 2, 'active', NOW() - INTERVAL '22 days', NOW() - INTERVAL '22 days'),
-(7, 'Комод с зеркалом', 'Комод с большим зеркалом для спальни', 13500, 'хорошее', 'Ростов-на-Дону', 3, 'active', NOW() - INTERVAL '21 days', NOW() - INTERVAL '21 days'),
-(7, 'Подставка для цветов', 'Металлическая подставка для комнатных растений', 1800, 'новое', 'Ростов-на-Дону', 5, 'active', NOW() - INTERVAL '20 days', NOW() - INTERVAL '20 days'),
+(7, 'Мягкий уголок', 'Мягкий уголок с механизмом трансформации', 20000, 'fair', 'Ростов-на-Дону', 1, 'active', NOW() - INTERVAL '23 days', NOW() - INTERVAL '23 days'),
+(7, 'Стеклянный стол', 'Стильный обеденный стол со стеклянной столешницей', 9500, 'good', 'Ростов-на-Дону', 2, 'active', NOW() - INTERVAL '22 days', NOW() - INTERVAL '22 days'),
+(7, 'Комод с зеркалом', 'Комод с большим зеркалом для спальни', 13500, 'good', 'Ростов-на-Дону', 3, 'active', NOW() - INTERVAL '21 days', NOW() - INTERVAL '21 days'),
+(7, 'Подставка для цветов', 'Металлическая подставка для комнатных растений', 1800, 'new', 'Ростов-на-Дону', 5, 'active', NOW() - INTERVAL '20 days', NOW() - INTERVAL '20 days'),
 
 -- Пользователь 8
-(8, 'Кожаное кресло', 'Кожаное кресло для гостиной, цвет - коричневый', 12000, 'хорошее', 'Уфа', 1, 'active', NOW() - INTERVAL '22 days', NOW() - INTERVAL '22 days'),
-(8, 'Складной стол', 'Компактный складной стол для дачи', 3000, 'среднее', 'Уфа', 2, 'active', NOW() - INTERVAL '21 days', NOW() - INTERVAL '21 days'),
-(8, 'Двуспальная кровать', 'Двуспальная кровать с подъемным механизмом', 17000, 'хорошее', 'Уфа', 4, 'active', NOW() - INTERVAL '20 days', NOW() - INTERVAL '20 days'),
+(8, 'Кожаное кресло', 'Кожаное кресло для гостиной, цвет - коричневый', 12000, 'good', 'Уфа', 1, 'active', NOW() - INTERVAL '22 days', NOW() - INTERVAL '22 days'),
+(8, 'Складной стол', 'Компактный складной стол для дачи', 3000, 'fair', 'Уфа', 2, 'active', NOW() - INTERVAL '21 days', NOW() - INTERVAL '21 days'),
+(8, 'Двуспальная кровать', 'Двуспальная кровать с подъемным механизмом', 17000, 'good', 'Уфа', 4, 'active', NOW() - INTERVAL '20 days', NOW() - INTERVAL '20 days'),
 
 -- Пользователь 9
-(9, 'Диван для офиса', 'Компактный диван для офиса или приемной', 14000, 'хорошее', 'Краснодар', 1, 'active', NOW() - INTERVAL '21 days', NOW() - INTERVAL '21 days'),
-(9, 'Стулья для кухни', 'Набор из 4 стульев для кухни, металл и пластик', 6000, 'среднее', 'Краснодар', 2, 'active', NOW() - INTERVAL '20 days', NOW() - INTERVAL '20 days'),
-(9, 'Шкаф для посуды', 'Шкаф-витрина для посуды и декора', 9800, 'хорошее', 'Краснодар', 3, 'active', NOW() - INTERVAL '19 days', NOW() - INTERVAL '19 days'),
-(9, 'Вешалка напольная', 'Деревянная напольная вешалка для одежды', 2500, 'хорошее', 'Краснодар', 5, 'active', NOW() - INTERVAL '18 days', NOW() - INTERVAL '18 days'),
+(9, 'Диван для офиса', 'Компактный диван для офиса или приемной', 14000, 'good', 'Краснодар', 1, 'active', NOW() - INTERVAL '21 days', NOW() - INTERVAL '21 days'),
+(9, 'Стулья для кухни', 'Набор из 4 стульев для кухни, металл и пластик', 6000, 'fair', 'Краснодар', 2, 'active', NOW() - INTERVAL '20 days', NOW() - INTERVAL '20 days'),
+(9, 'Шкаф для посуды', 'Шкаф-витрина для посуды и декора', 9800, 'good', 'Краснодар', 3, 'active', NOW() - INTERVAL '19 days', NOW() - INTERVAL '19 days'),
+(9, 'Вешалка напольная', 'Деревянная напольная вешалка для одежды', 2500, 'good', 'Краснодар', 5, 'active', NOW() - INTERVAL '18 days', NOW() - INTERVAL '18 days'),
 
 -- Пользователь 10
-(10, 'Кресло-мешок', 'Удобное кресло-мешок, наполнитель - пенополистирол', 4000, 'хорошее', 'Воронеж', 1, 'active', NOW() - INTERVAL '20 days', NOW() - INTERVAL '20 days'),
-(10, 'Компьютерное кресло', 'Компьютерное кресло с подлокотниками', 6500, 'среднее', 'Воронеж', 1, 'active', NOW() - INTERVAL '19 days', NOW() - INTERVAL '19 days'),
-(10, 'Стол-трансформер', 'Стол-трансформер, регулируемая высота', 8500, 'хорошее', 'Воронеж', 2, 'active', NOW() - INTERVAL '18 days', NOW() - INTERVAL '18 days'),
-(10, 'Детский шкаф', 'Яркий шкаф для детской комнаты', 7200, 'хорошее', 'Воронеж', 3, 'active', NOW() - INTERVAL '17 days', NOW() - INTERVAL '17 days');
+(10, 'Кресло-мешок', 'Удобное кресло-мешок, наполнитель - пенополистирол', 4000, 'good', 'Воронеж', 1, 'active', NOW() - INTERVAL '20 days', NOW() - INTERVAL '20 days'),
+(10, 'Компьютерное кресло', 'Компьютерное кресло с подлокотниками', 6500, 'fair', 'Воронеж', 1, 'active', NOW() - INTERVAL '19 days', NOW() - INTERVAL '19 days'),
+(10, 'Стол-трансформер', 'Стол-трансформер, регулируемая высота', 8500, 'good', 'Воронеж', 2, 'active', NOW() - INTERVAL '18 days', NOW() - INTERVAL '18 days'),
+(10, 'Детский шкаф', 'Яркий шкаф для детской комнаты', 7200, 'good', 'Воронеж', 3, 'active', NOW() - INTERVAL '17 days', NOW() - INTERVAL '17 days');
 
 -- Добавление изображений к объявлениям
 INSERT INTO listing_images (listing_id, image_path, is_main, created_at) VALUES
@@ -284,13 +284,8 @@ INSERT INTO messages (chat_id, user_id, content, created_at, is_read) VALUES
 UPDATE messages SET is_read = false WHERE chat_id = 2 AND user_id = 1 AND created_at > NOW() - INTERVAL '26 days';
 UPDATE messages SET is_read = false WHERE chat_id = 3 AND user_id = 3 AND created_at > NOW() - INTERVAL '25 days';
 
--- Добавление 2ФА кодов для некоторых пользователей (для демонстрационных целей)
-INSERT INTO two_factor_codes (user_id, code, expires_at) VALUES
-(1, '123456', NOW() + INTERVAL '15 minutes'),
-(2, '654321', NOW() + INTERVAL '15 minutes'),
-(3, '789012', NOW() + INTERVAL '15 minutes'),
-(4, '456789', NOW() + INTERVAL '15 minutes'),
-(5, '321654', NOW() + INTERVAL '15 minutes');
+-- Коды 2ФА хранятся в виде хэшей и не создаются в тестовых данных:
+-- для входа без почты запустите сервер с AUTH_DEV_MODE=true, код вернется в ответе /auth/login
 
 -- Создание дополнительных чатов между пользователями (без привязки к объявлениям)
 INSERT INTO chats (buyer_id, seller_id, created_at) VALUES
@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -12,12 +14,23 @@ type AppConfig struct {
 	// Server settings
 	Port string
 
+	// Public URL of the application, used for links in emails
+	BaseURL string
+
 	// CORS settings
 	AllowedOrigins []string
 
 	// JWT Settings
 	JWTSecret string
 
+	// Session settings: lifetime of access tokens and of refresh tokens since their last use
+	AccessTokenMinutes int
+	RefreshTokenDays   int
+
+	// Development mode of authentication: verification and 2FA codes are returned in
+	// API responses in addition to being emailed. Never enable in production.
+	AuthDevMode bool
+
 	// Database settings
 	DBHost     string
 	DBPort     string
@@ -33,6 +46,19 @@ type AppConfig struct {
 
 	// File upload settings
 	UploadsDir string
+
+	// Storage settings: "local" keeps uploads in UploadsDir, "s3" in an S3-compatible bucket
+	StorageDriver   string
+	S3Endpoint      string
+	S3Region        string
+	S3Bucket        string
+	S3AccessKey     string
+	S3SecretKey     string
+	S3PublicURL     string
+	SignedURLExpiry int
+
+	// Offer settings
+	OfferExpirationHours int
 }
 
 // Config is the global application configuration
@@ -57,6 +83,11 @@ func Load() {
 		port = "8080"
 	}
 
+	baseURL := strings.TrimSuffix(os.Getenv("APP_BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = "http://localhost:" + port
+	}
+
 	// CORS settings
 	allowedOrigins := []string{"http://localhost:3000"}
 	if origins := os.Getenv("ALLOWED_ORIGINS"); origins != "" {
@@ -70,6 +101,37 @@ func Load() {
 		log.Println("WARNING: JWT_SECRET_KEY not configured, using default value")
 	}
 
+	// Session settings
+	accessTokenMinutes := 15
+	if minutes := os.Getenv("ACCESS_TOKEN_TTL_MINUTES"); minutes != "" {
+		if parsed, err := strconv.Atoi(minutes); err == nil && parsed > 0 {
+			accessTokenMinutes = parsed
+		} else {
+			log.Printf("WARNING: invalid ACCESS_TOKEN_TTL_MINUTES %q, using default value", minutes)
+		}
+	}
+
+	refreshTokenDays := 30
+	if days := os.Getenv("REFRESH_TOKEN_TTL_DAYS"); days != "" {
+		if parsed, err := strconv.Atoi(days); err == nil && parsed > 0 {
+			refreshTokenDays = parsed
+		} else {
+			log.Printf("WARNING: invalid REFRESH_TOKEN_TTL_DAYS %q, using default value", days)
+		}
+	}
+
+	authDevMode := false
+	if devMode := os.Getenv("AUTH_DEV_MODE"); devMode != "" {
+		parsed, err := strconv.ParseBool(devMode)
+		if err != nil {
+			log.Printf("WARNING: invalid AUTH_DEV_MODE %q, development mode disabled", devMode)
+		}
+		authDevMode = parsed
+	}
+	if authDevMode {
+		log.Println("WARNING: AUTH_DEV_MODE enabled, verification codes are returned in API responses")
+	}
+
 	// Database settings
 	dbHost := os.Getenv("DB_HOST")
 	if dbHost == "" {
@@ -116,26 +178,69 @@ func Load() {
 		uploadsDir = "uploads"
 	}
 
-	// Create the uploads directory if it doesn't exist
-	if err := os.MkdirAll(uploadsDir, 0755); err != nil {
-		log.Fatal("Error creating uploads directory:", err)
+	// Storage settings
+	storageDriver := os.Getenv("STORAGE_DRIVER")
+	if storageDriver == "" {
+		storageDriver = "local"
+	}
+
+	s3Region := os.Getenv("S3_REGION")
+	if s3Region == "" {
+		s3Region = "us-east-1"
+	}
+
+	// Lifetime of signed URLs, in minutes
+	signedURLExpiry := 60
+	if minutes := os.Getenv("SIGNED_URL_EXPIRY_MINUTES"); minutes != "" {
+		if parsed, err := strconv.Atoi(minutes); err == nil && parsed > 0 {
+			signedURLExpiry = parsed
+		} else {
+			log.Printf("WARNING: invalid SIGNED_URL_EXPIRY_MINUTES %q, using default value", minutes)
+		}
+	}
+
+	// Offer settings
+	offerExpirationHours := 48
+	if hours := os.Getenv("OFFER_EXPIRATION_HOURS"); hours != "" {
+		if parsed, err := strconv.Atoi(hours); err == nil && parsed > 0 {
+			offerExpirationHours = parsed
+		} else {
+			log.Printf("WARNING: invalid OFFER_EXPIRATION_HOURS %q, using default value", hours)
+		}
 	}
 
 	// Set the global configuration
 	Config = AppConfig{
 		Port:           port,
+		BaseURL:        baseURL,
 		AllowedOrigins: allowedOrigins,
 		JWTSecret:      jwtSecret,
-		DBHost:         dbHost,
-		DBPort:         dbPort,
-		DBUser:         dbUser,
-		DBPassword:     dbPassword,
-		DBName:         dbName,
-		SMTPHost:       smtpHost,
-		SMTPPort:       smtpPort,
-		SMTPUsername:   smtpUsername,
-		SMTPPassword:   smtpPassword,
-		UploadsDir:     uploadsDir,
+
+		AccessTokenMinutes: accessTokenMinutes,
+		RefreshTokenDays:   refreshTokenDays,
+		AuthDevMode:        authDevMode,
+
+		DBHost:       dbHost,
+		DBPort:       dbPort,
+		DBUser:       dbUser,
+		DBPassword:   dbPassword,
+		DBName:       dbName,
+		SMTPHost:     smtpHost,
+		SMTPPort:     smtpPort,
+		SMTPUsername: smtpUsername,
+		SMTPPassword: smtpPassword,
+		UploadsDir:   uploadsDir,
+
+		StorageDriver:   storageDriver,
+		S3Endpoint:      strings.TrimSuffix(os.Getenv("S3_ENDPOINT"), "/"),
+		S3Region:        s3Region,
+		S3Bucket:        os.Getenv("S3_BUCKET"),
+		S3AccessKey:     os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey:     os.Getenv("S3_SECRET_KEY"),
+		S3PublicURL:     strings.TrimSuffix(os.Getenv("S3_PUBLIC_URL"), "/"),
+		SignedURLExpiry: signedURLExpiry,
+
+		OfferExpirationHours: offerExpirationHours,
 	}
 
 	log.Println("Configuration loaded successfully")
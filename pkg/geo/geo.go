@@ -0,0 +1,86 @@
+package geo
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// KmPerDegreeLatitude is the length of one degree of latitude in kilometers
+const KmPerDegreeLatitude = 111.045
+
+// Location represents geographic coordinates in degrees
+type Location struct {
+	Latitude  float64 `db:"latitude" json:"latitude"`
+	Longitude float64 `db:"longitude" json:"longitude"`
+}
+
+// ParseLocation parses coordinates in the "lat,lon" format
+func ParseLocation(value string) (*Location, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return nil, errors.New("invalid location")
+	}
+
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return nil, errors.New("invalid location")
+	}
+
+	longitude, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return nil, errors.New("invalid location")
+	}
+
+	return NewLocation(&latitude, &longitude)
+}
+
+// NewLocation builds a location from optional coordinates; both must be set or both omitted
+func NewLocation(latitude, longitude *float64) (*Location, error) {
+	if latitude == nil && longitude == nil {
+		return nil, nil
+	}
+	if latitude == nil || longitude == nil {
+		return nil, errors.New("latitude and longitude must be set together")
+	}
+	if *latitude < -90 || *latitude > 90 || *longitude < -180 || *longitude > 180 {
+		return nil, errors.New("invalid location")
+	}
+
+	return &Location{Latitude: *latitude, Longitude: *longitude}, nil
+}
+
+// DistanceSQL returns a SQL expression for the great-circle distance in kilometers
+// between the latitude/longitude columns and a point given as query parameters
+func DistanceSQL(latColumn, lonColumn string, latParam, lonParam int) string {
+	return fmt.Sprintf("(6371 * 2 * asin(sqrt("+
+		"power(sin(radians(%[1]s - $%[3]d::float8) / 2), 2) + "+
+		"cos(radians($%[3]d::float8)) * cos(radians(%[1]s)) * power(sin(radians(%[2]s - $%[4]d::float8) / 2), 2))))",
+		latColumn, lonColumn, latParam, lonParam)
+}
+
+// CityLocation looks up the coordinates of a city in the bundled cities table;
+// it returns nil if the city is unknown
+func CityLocation(db *sqlx.DB, city string) (*Location, error) {
+	city = strings.TrimSpace(city)
+	if city == "" {
+		return nil, nil
+	}
+
+	var location Location
+	err := db.Get(&location, "SELECT latitude, longitude FROM cities WHERE lower(name) = lower($1)", city)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error getting city location: %v", err)
+		return nil, fmt.Errorf("error getting city location: %w", err)
+	}
+
+	return &location, nil
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -15,6 +16,12 @@ func AuthRequired(db *sqlx.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get Authorization header
 		authHeader := c.GetHeader("Authorization")
+
+		// Browsers can't set headers on WebSocket handshakes, so accept the token from the query there
+		if authHeader == "" && websocket.IsWebSocketUpgrade(c.Request) && c.Query("token") != "" {
+			authHeader = "Bearer " + c.Query("token")
+		}
+
 		if authHeader == "" {
 			log.Println("Missing authorization token in request")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing authorization token"})
@@ -25,7 +32,7 @@ func AuthRequired(db *sqlx.DB) gin.HandlerFunc {
 		// Check "Bearer <token>" format
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			log.Println("Invalid token format:", authHeader)
+			log.Println("Invalid token format in authorization header")
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization token format"})
 			c.Abort()
 			return
@@ -42,8 +49,8 @@ func AuthRequired(db *sqlx.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Validate token and get user ID
-		userID, err := utils.ValidateToken(token)
+		// Validate token and get user and session IDs
+		claims, err := utils.ValidateToken(token)
 		if err != nil {
 			log.Printf("Token validation error: %v\n", err)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
@@ -51,9 +58,10 @@ func AuthRequired(db *sqlx.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Check if userID is positive
-		if userID <= 0 {
-			log.Printf("Invalid userID from token: %d\n", userID)
+		// Check if userID and sessionID are positive; tokens issued before sessions have none
+		userID, sessionID := claims.UserID, claims.SessionID
+		if userID <= 0 || sessionID <= 0 {
+			log.Printf("Invalid userID or sessionID from token: %d, %d\n", userID, sessionID)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			c.Abort()
 			return
@@ -61,9 +69,8 @@ func AuthRequired(db *sqlx.DB) gin.HandlerFunc {
 
 		log.Printf("Token successfully validated for user ID: %d\n", userID)
 
-		// Check if user exists in the database
-		var exists bool
-		err = db.Get(&exists, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND is_verified = true)", userID)
+		// Check if user exists and the session is still active
+		active, err := sessionActive(db, userID, sessionID)
 		if err != nil {
 			log.Printf("Error checking user in database: %v\n", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "error checking user"})
@@ -71,16 +78,94 @@ func AuthRequired(db *sqlx.DB) gin.HandlerFunc {
 			return
 		}
 
-		if !exists {
-			log.Printf("User ID: %d not found or not verified\n", userID)
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found or not verified"})
+		if !active {
+			log.Printf("User ID: %d not found, not verified or session %d revoked\n", userID, sessionID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "session expired or revoked"})
 			c.Abort()
 			return
 		}
 
-		// Set user ID in context for further use
+		// Set user and session IDs in context for further use
 		c.Set("userID", userID)
+		c.Set("sessionID", sessionID)
 		log.Printf("User ID: %d successfully authenticated\n", userID)
 		c.Next()
 	}
 }
+
+// OptionalAuth middleware authorizes the user if a valid JWT token is present
+// and lets anonymous requests through otherwise
+func OptionalAuth(db *sqlx.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Check "Bearer <token>" format
+		parts := strings.Split(c.GetHeader("Authorization"), " ")
+		if len(parts) != 2 || parts[0] != "Bearer" || parts[1] == "" {
+			c.Next()
+			return
+		}
+
+		// Validate token and get user and session IDs
+		claims, err := utils.ValidateToken(parts[1])
+		if err != nil || claims.UserID <= 0 || claims.SessionID <= 0 {
+			log.Printf("Ignoring invalid optional token: %v\n", err)
+			c.Next()
+			return
+		}
+
+		// Check if user exists and the session is still active
+		active, err := sessionActive(db, claims.UserID, claims.SessionID)
+		if err != nil || !active {
+			c.Next()
+			return
+		}
+
+		// Set user and session IDs in context for further use
+		c.Set("userID", claims.UserID)
+		c.Set("sessionID", claims.SessionID)
+		c.Next()
+	}
+}
+
+// sessionActive checks that a session of a verified user is neither revoked nor expired
+func sessionActive(db *sqlx.DB, userID, sessionID int) (bool, error) {
+	var active bool
+	err := db.Get(&active, `
+		SELECT EXISTS(
+			SELECT 1 FROM sessions s
+			JOIN users u ON u.id = s.user_id
+			WHERE s.id = $1 AND s.user_id = $2 AND s.revoked_at IS NULL AND s.expires_at > NOW()
+				AND u.is_verified = true
+		)
+	`, sessionID, userID)
+	return active, err
+}
+
+// AdminRequired middleware allows only administrators; it must run after AuthRequired
+func AdminRequired(db *sqlx.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			c.Abort()
+			return
+		}
+
+		var isAdmin bool
+		err := db.Get(&isAdmin, "SELECT is_admin FROM users WHERE id = $1", userID)
+		if err != nil {
+			log.Printf("Error checking admin rights: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error checking user"})
+			c.Abort()
+			return
+		}
+
+		if !isAdmin {
+			log.Printf("User ID: %d is not an administrator\n", userID)
+			c.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
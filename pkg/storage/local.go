@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LocalURLPrefix is the URL path local files are served under
+const LocalURLPrefix = "/uploads/"
+
+// Local stores files in a directory on the local disk
+type Local struct {
+	dir string
+}
+
+// NewLocal creates a local storage in the directory, creating it if it doesn't exist
+func NewLocal(dir string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("Error creating uploads directory %s: %v", dir, err)
+		return nil, fmt.Errorf("error creating uploads directory: %w", err)
+	}
+
+	return &Local{
+		dir: dir,
+	}, nil
+}
+
+// Save writes the content to a file, creating its folder if needed
+func (s *Local) Save(filePath string, content io.Reader, size int64, contentType string) error {
+	fullPath := s.fullPath(filePath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		log.Printf("Error creating directory for %s: %v", fullPath, err)
+		return fmt.Errorf("error creating directory: %w", err)
+	}
+
+	dst, err := os.Create(fullPath)
+	if err != nil {
+		log.Printf("Error creating file %s: %v", fullPath, err)
+		return fmt.Errorf("error creating file: %w", err)
+	}
+	defer dst.Close()
+
+	if _, err = io.Copy(dst, content); err != nil {
+		log.Printf("Error writing file %s: %v", fullPath, err)
+		os.Remove(fullPath)
+		return fmt.Errorf("error writing file: %w", err)
+	}
+
+	return nil
+}
+
+// Open opens a file for reading
+func (s *Local) Open(filePath string) (*Object, error) {
+	file, err := os.Open(s.fullPath(filePath))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		log.Printf("Error opening file %s: %v", filePath, err)
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		log.Printf("Error reading file info %s: %v", filePath, err)
+		return nil, fmt.Errorf("error reading file info: %w", err)
+	}
+
+	return &Object{
+		ReadCloser:  file,
+		Size:        info.Size(),
+		ContentType: contentTypeByPath(filePath),
+	}, nil
+}
+
+// Delete removes a file
+func (s *Local) Delete(filePath string) error {
+	fullPath := s.fullPath(filePath)
+	if err := os.Remove(fullPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// File does not exist, consider deletion successful
+			log.Printf("File %s doesn't exist, skipping deletion", fullPath)
+			return nil
+		}
+		log.Printf("Error deleting file %s: %v", fullPath, err)
+		return fmt.Errorf("error deleting file: %w", err)
+	}
+
+	log.Printf("File %s successfully deleted", fullPath)
+	return nil
+}
+
+// URL returns the URL the file is served under by the public file system
+func (s *Local) URL(filePath string) string {
+	return LocalURLPrefix + filePath
+}
+
+// PublicFileSystem returns the file system to serve public files from, without directory
+// listings; the private folder is hidden
+func (s *Local) PublicFileSystem() http.FileSystem {
+	return publicFiles{gin.Dir(s.dir, false)}
+}
+
+// fullPath returns the path on disk of a stored file; the path can't escape the storage directory
+func (s *Local) fullPath(filePath string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(filepath.Clean("/"+filePath)))
+}
+
+// publicFiles hides the private folder from static file serving
+type publicFiles struct {
+	http.FileSystem
+}
+
+// Open opens a file unless it is inside the private folder
+func (fs publicFiles) Open(name string) (http.File, error) {
+	if IsPrivate(name) {
+		return nil, os.ErrNotExist
+	}
+	return fs.FileSystem.Open(name)
+}
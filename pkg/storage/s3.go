@@ -0,0 +1,310 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// s3Algorithm is the AWS Signature Version 4 algorithm name
+	s3Algorithm = "AWS4-HMAC-SHA256"
+
+	// s3UnsignedPayload skips hashing the request body, which S3 allows for object requests
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// s3Timeout is the timeout of a single request to the storage
+	s3Timeout = 30 * time.Second
+)
+
+// S3Config holds the settings of an S3-compatible storage
+type S3Config struct {
+	// Endpoint is the base URL of the storage API, e.g. "https://s3.eu-central-1.amazonaws.com"
+	// or "http://localhost:9000" for MinIO
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+
+	// PublicURL is the base URL public files are readable under without signing, e.g. a CDN
+	// or a public-read bucket; if empty, signed URLs are used for every file
+	PublicURL string
+
+	// URLExpiry is the lifetime of signed URLs
+	URLExpiry time.Duration
+}
+
+// S3 stores files in a bucket of an S3-compatible storage, using path-style requests
+type S3 struct {
+	config S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3 creates an S3-compatible storage
+func NewS3(config S3Config) (*S3, error) {
+	if config.Endpoint == "" || config.Bucket == "" || config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("S3 endpoint, bucket and credentials are required")
+	}
+	if _, err := url.ParseRequestURI(config.Endpoint); err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+	config.PublicURL = strings.TrimSuffix(config.PublicURL, "/")
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.URLExpiry <= 0 {
+		config.URLExpiry = time.Hour
+	}
+
+	return &S3{
+		config: config,
+		client: &http.Client{Timeout: s3Timeout},
+		now:    time.Now,
+	}, nil
+}
+
+// Save uploads the content as an object
+func (s *S3) Save(filePath string, content io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(filePath), content)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.do(req)
+	if err != nil {
+		log.Printf("Error uploading file %s: %v", filePath, err)
+		return fmt.Errorf("error uploading file: %w", err)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// Open downloads an object
+func (s *S3) Open(filePath string) (*Object, error) {
+	req, err := http.NewRequest(http.MethodGet, s.objectURL(filePath), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		log.Printf("Error opening file %s: %v", filePath, err)
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = contentTypeByPath(filePath)
+	}
+
+	return &Object{
+		ReadCloser:  resp.Body,
+		Size:        resp.ContentLength,
+		ContentType: contentType,
+	}, nil
+}
+
+// Delete deletes an object; S3 treats deleting a missing object as success
+func (s *S3) Delete(filePath string) error {
+	req, err := http.NewRequest(http.MethodDelete, s.objectURL(filePath), nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+
+	resp, err := s.do(req)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		log.Printf("Error deleting file %s: %v", filePath, err)
+		return fmt.Errorf("error deleting file: %w", err)
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+
+	log.Printf("File %s successfully deleted", filePath)
+	return nil
+}
+
+// URL returns the public URL of a public file if a public URL is configured, otherwise
+// a signed URL that expires after the configured lifetime
+func (s *S3) URL(filePath string) string {
+	if s.config.PublicURL != "" && !IsPrivate(filePath) {
+		return s.config.PublicURL + "/" + encodePath(filePath)
+	}
+	return s.PresignGet(filePath, s.config.URLExpiry)
+}
+
+// PresignGet returns a signed URL to download an object, valid for the given time
+func (s *S3) PresignGet(filePath string, expiry time.Duration) string {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := s.scope(now)
+
+	objectURL, _ := url.Parse(s.objectURL(filePath))
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", s3Algorithm)
+	query.Set("X-Amz-Credential", s.config.AccessKey+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expiry.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		objectURL.EscapedPath(),
+		canonicalQuery(query),
+		"host:" + objectURL.Host + "\n",
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+
+	query.Set("X-Amz-Signature", s.signature(now, amzDate, scope, canonicalRequest))
+	objectURL.RawQuery = canonicalQuery(query)
+	return objectURL.String()
+}
+
+// do signs and sends a request, returning ErrNotFound for missing objects and an error
+// for any other unsuccessful status
+func (s *S3) do(req *http.Request) (*http.Response, error) {
+	s.sign(req)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("storage responded with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return resp, nil
+}
+
+// sign adds the Signature Version 4 authorization headers to a request
+func (s *S3) sign(req *http.Request) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := s.scope(now)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": s3UnsignedPayload,
+		"x-amz-date":           amzDate,
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		headers["content-type"] = contentType
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		s3UnsignedPayload,
+	}, "\n")
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.config.AccessKey, scope, signedHeaders, s.signature(now, amzDate, scope, canonicalRequest)))
+}
+
+// signature computes the Signature Version 4 signature of a canonical request
+func (s *S3) signature(now time.Time, amzDate, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{s3Algorithm, amzDate, scope, hex.EncodeToString(hash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// scope returns the credential scope of a request made at the given time
+func (s *S3) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.config.Region + "/s3/aws4_request"
+}
+
+// objectURL returns the path-style URL of an object
+func (s *S3) objectURL(filePath string) string {
+	return s.config.Endpoint + "/" + url.PathEscape(s.config.Bucket) + "/" + encodePath(filePath)
+}
+
+// encodePath escapes every segment of an object key, keeping the slashes
+func encodePath(filePath string) string {
+	segments := strings.Split(strings.TrimPrefix(filePath, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = encodeValue(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// encodeValue escapes a string as required by Signature Version 4: everything except
+// unreserved characters, with spaces as %20
+func encodeValue(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+// canonicalQuery encodes query parameters sorted by name
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		values := query[name]
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, encodeValue(name)+"="+encodeValue(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// hmacSHA256 computes the HMAC-SHA256 of data with the key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
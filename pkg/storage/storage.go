@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"FurniSwap/pkg/config"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+// PrivateFolder is the folder for files that are never served publicly;
+// they are only available through access-controlled endpoints
+const PrivateFolder = "private"
+
+// ErrNotFound is returned when a stored file doesn't exist
+var ErrNotFound = errors.New("file not found")
+
+// Storage stores uploaded files by their path, e.g. "listings/1/image.jpg"
+type Storage interface {
+	// Save stores the content of the given size under the path
+	Save(filePath string, content io.Reader, size int64, contentType string) error
+	// Open opens a stored file for reading
+	Open(filePath string) (*Object, error)
+	// Delete deletes a stored file; deleting a missing file is not an error
+	Delete(filePath string) error
+	// URL returns the public or signed URL of a stored file
+	URL(filePath string) string
+}
+
+// Object is a stored file opened for reading
+type Object struct {
+	io.ReadCloser
+	Size        int64
+	ContentType string
+}
+
+// Default is the global storage instance
+var Default Storage
+
+// Init initializes the storage selected by the configuration
+func Init() (Storage, error) {
+	var (
+		store Storage
+		err   error
+	)
+
+	switch config.Config.StorageDriver {
+	case "local":
+		store, err = NewLocal(config.Config.UploadsDir)
+	case "s3":
+		store, err = NewS3(S3Config{
+			Endpoint:  config.Config.S3Endpoint,
+			Region:    config.Config.S3Region,
+			Bucket:    config.Config.S3Bucket,
+			AccessKey: config.Config.S3AccessKey,
+			SecretKey: config.Config.S3SecretKey,
+			PublicURL: config.Config.S3PublicURL,
+			URLExpiry: time.Duration(config.Config.SignedURLExpiry) * time.Minute,
+		})
+	default:
+		err = fmt.Errorf("unknown storage driver %q", config.Config.StorageDriver)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Set the global storage instance
+	Default = store
+
+	log.Printf("Using %s file storage", config.Config.StorageDriver)
+	return store, nil
+}
+
+// IsPrivate checks if a file is inside the private folder
+func IsPrivate(filePath string) bool {
+	cleaned := path.Clean("/" + filePath)
+	return cleaned == "/"+PrivateFolder || strings.HasPrefix(cleaned, "/"+PrivateFolder+"/")
+}
+
+// contentTypeByPath guesses the content type of a file from its extension
+func contentTypeByPath(filePath string) string {
+	if contentType := mime.TypeByExtension(path.Ext(filePath)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
@@ -1,8 +1,13 @@
 package utils
 
 import (
-	"math/rand"
-	"time"
+	"FurniSwap/pkg/config"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
 )
 
 const (
@@ -12,15 +17,32 @@ const (
 	codeLength = 6
 )
 
-// GenerateCode generates a random 6-digit verification code
-func GenerateCode() string {
-	// Create a properly seeded random source
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+// GenerateCode generates a random 6-digit verification code using a cryptographic RNG
+func GenerateCode() (string, error) {
+	limit := big.NewInt(int64(len(codeChars)))
 
 	code := make([]byte, codeLength)
 	for i := 0; i < codeLength; i++ {
-		code[i] = codeChars[r.Intn(len(codeChars))]
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", fmt.Errorf("error generating code: %w", err)
+		}
+		code[i] = codeChars[n.Int64()]
 	}
 
-	return string(code)
+	return string(code), nil
+}
+
+// HashCode hashes a verification code for storage. Codes are short enough to be guessed
+// offline from a plain hash, so it is keyed with the server secret and bound to the
+// challenge the code was issued for.
+func HashCode(challengeID, code string) string {
+	mac := hmac.New(sha256.New, []byte(config.Config.JWTSecret))
+	mac.Write([]byte(challengeID + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CodeHashesEqual compares two code hashes in constant time
+func CodeHashesEqual(a, b string) bool {
+	return hmac.Equal([]byte(a), []byte(b))
 }
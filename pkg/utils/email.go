@@ -5,10 +5,18 @@ import (
 	"log"
 	"net/smtp"
 	"os"
+	"sort"
+	"strings"
 )
 
 // SendEmail sends an email to the specified address
 func SendEmail(to, subject, body string) error {
+	return SendEmailWithHeaders(to, subject, body, nil)
+}
+
+// SendEmailWithHeaders sends an email to the specified address with additional headers,
+// such as List-Unsubscribe
+func SendEmailWithHeaders(to, subject, body string, headers map[string]string) error {
 	// Get email configuration from environment
 	smtpHost := os.Getenv("SMTP_HOST")
 	if smtpHost == "" {
@@ -39,12 +47,23 @@ func SendEmail(to, subject, body string) error {
 	// Set up authentication for SMTP server
 	auth := smtp.PlainAuth("", smtpUsername, smtpPassword, smtpHost)
 
-	// Format the email
+	// Format the email; extra headers are sorted so the message is deterministic
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var extraHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&extraHeaders, "%s: %s\r\n", name, headers[name])
+	}
+
 	message := []byte(fmt.Sprintf("From: %s\r\n"+
 		"To: %s\r\n"+
 		"Subject: %s\r\n"+
+		"%s"+
 		"\r\n"+
-		"%s\r\n", smtpUsername, to, subject, body))
+		"%s\r\n", smtpUsername, to, subject, extraHeaders.String(), body))
 
 	// Send the email
 	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, smtpUsername, []string{to}, message)
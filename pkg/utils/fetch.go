@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+const (
+	// fetchTimeout limits the whole download of a remote image, including redirects
+	fetchTimeout = 15 * time.Second
+
+	// fetchMaxRedirects is the maximum number of redirects followed when downloading
+	fetchMaxRedirects = 5
+)
+
+// Remote image errors
+var (
+	ErrInvalidImageURL = errors.New("invalid image URL")
+	ErrImageURLBlocked = errors.New("image URL points to a private network address")
+	ErrImageDownload   = errors.New("error downloading image")
+)
+
+// blockedPrefixes are the non-public networks not covered by the netip.Addr checks
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "This" network
+	netip.MustParsePrefix("100.64.0.0/10"),  // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // Benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // Reserved
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64
+	netip.MustParsePrefix("64:ff9b:1::/48"), // Local-use NAT64
+}
+
+// fetchClient downloads remote images; it only connects to public addresses, which is
+// checked on every connection so redirects and DNS changes can't reach internal hosts
+var fetchClient = &http.Client{
+	Timeout: fetchTimeout,
+	Transport: &http.Transport{
+		// Never go through a proxy: the address check must see the real destination
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: checkDialAddress,
+		}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 10 * time.Second,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= fetchMaxRedirects {
+			return errors.New("too many redirects")
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return ErrInvalidImageURL
+		}
+		return nil
+	},
+}
+
+// FetchImage downloads an image from an http(s) URL on a public host, limited in size
+// and time. The content is not validated; use ProcessImageData to store it.
+func FetchImage(rawURL string) ([]byte, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, ErrInvalidImageURL
+	}
+
+	req, err := http.NewRequest(http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return nil, ErrInvalidImageURL
+	}
+	req.Header.Set("User-Agent", "FurniSwap image fetcher")
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		log.Printf("Error downloading image %s: %v", rawURL, err)
+		switch {
+		case errors.Is(err, ErrImageURLBlocked):
+			return nil, ErrImageURLBlocked
+		case errors.Is(err, ErrInvalidImageURL):
+			return nil, ErrInvalidImageURL
+		}
+		return nil, fmt.Errorf("%w: %v", ErrImageDownload, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", ErrImageDownload, resp.StatusCode)
+	}
+	if resp.ContentLength > MaxImageSize {
+		return nil, ErrImageTooLarge
+	}
+
+	// Read one byte over the limit to detect oversized images without a Content-Length
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxImageSize+1))
+	if err != nil {
+		log.Printf("Error reading image %s: %v", rawURL, err)
+		return nil, fmt.Errorf("%w: %v", ErrImageDownload, err)
+	}
+	if len(data) > MaxImageSize {
+		return nil, ErrImageTooLarge
+	}
+
+	return data, nil
+}
+
+// IsPermanentImageError checks if downloading or storing a remote image failed in a way
+// that retrying won't fix, as opposed to a network or storage error
+func IsPermanentImageError(err error) bool {
+	return errors.Is(err, ErrInvalidImageURL) || errors.Is(err, ErrImageURLBlocked) ||
+		errors.Is(err, ErrInvalidImage) || errors.Is(err, ErrImageTooLarge)
+}
+
+// checkDialAddress refuses connections to non-public addresses
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return ErrImageURLBlocked
+	}
+	if !IsPublicAddress(addrPort.Addr()) {
+		return ErrImageURLBlocked
+	}
+	return nil
+}
+
+// IsPublicAddress checks if an IP address is routable on the public internet
+func IsPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() ||
+		addr.IsUnspecified() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
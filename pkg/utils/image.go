@@ -0,0 +1,333 @@
+package utils
+
+import (
+	"FurniSwap/pkg/storage"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+
+	// Register decoders of the accepted image formats
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/google/uuid"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// MaxImageSize is the maximum size of an uploaded image file
+	MaxImageSize = 10 << 20
+
+	// maxImagePixels guards against decompression bombs: small files that decode to huge images
+	maxImagePixels = 50_000_000
+
+	// jpegQuality is the quality of re-encoded renditions
+	jpegQuality = 85
+)
+
+// Image processing errors
+var (
+	ErrInvalidImage  = errors.New("invalid image")
+	ErrImageTooLarge = errors.New("image is too large")
+)
+
+// acceptedImageTypes are the image formats accepted for upload, detected by magic bytes
+var acceptedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Rendition is a resized version of an image that fits in a MaxSize x MaxSize square
+type Rendition struct {
+	Name    string
+	MaxSize int
+}
+
+// StoredImage describes a stored rendition of an image
+type StoredImage struct {
+	Path        string
+	ContentType string
+	Size        int64
+	Width       int
+	Height      int
+}
+
+// ProcessImage verifies that the upload is a real image, applies its EXIF orientation,
+// and stores it as re-encoded JPEG renditions without metadata. Renditions must be ordered
+// from largest to smallest. It returns the path of each rendition by name.
+func ProcessImage(file *multipart.FileHeader, folderName string, renditions []Rendition) (map[string]string, error) {
+	data, err := readUpload(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return ProcessImageData(data, folderName, renditions)
+}
+
+// ProcessImageUpload processes an upload like ProcessImage and describes each stored rendition
+func ProcessImageUpload(file *multipart.FileHeader, folderName string, renditions []Rendition) (map[string]StoredImage, error) {
+	data, err := readUpload(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return storeRenditions(data, folderName, renditions)
+}
+
+// readUpload reads an uploaded image, limited to MaxImageSize
+func readUpload(file *multipart.FileHeader) ([]byte, error) {
+	if file.Size > MaxImageSize {
+		return nil, ErrImageTooLarge
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		log.Printf("Error opening uploaded file: %v", err)
+		return nil, fmt.Errorf("error opening uploaded file: %w", err)
+	}
+	defer src.Close()
+
+	// Read one byte over the limit to detect oversized uploads
+	data, err := io.ReadAll(io.LimitReader(src, MaxImageSize+1))
+	if err != nil {
+		log.Printf("Error reading uploaded file: %v", err)
+		return nil, fmt.Errorf("error reading uploaded file: %w", err)
+	}
+	if len(data) > MaxImageSize {
+		return nil, ErrImageTooLarge
+	}
+
+	return data, nil
+}
+
+// ProcessImageData processes image content the same way as ProcessImage, e.g. an image
+// downloaded by FetchImage
+func ProcessImageData(data []byte, folderName string, renditions []Rendition) (map[string]string, error) {
+	stored, err := storeRenditions(data, folderName, renditions)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]string, len(stored))
+	for name, rendition := range stored {
+		paths[name] = rendition.Path
+	}
+	return paths, nil
+}
+
+// storeRenditions decodes the image and stores its renditions
+func storeRenditions(data []byte, folderName string, renditions []Rendition) (map[string]StoredImage, error) {
+	img, err := decodeImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each rendition is scaled from the previous, larger one
+	id := uuid.New().String()
+	stored := make(map[string]StoredImage, len(renditions))
+	paths := make(map[string]string, len(renditions))
+	for _, rendition := range renditions {
+		img = fitImage(img, rendition.MaxSize)
+
+		filePath := fmt.Sprintf("%s/%s-%s.jpg", folderName, id, rendition.Name)
+		size, err := saveJPEG(filePath, img)
+		if err != nil {
+			DeleteFiles(paths)
+			return nil, err
+		}
+		paths[rendition.Name] = filePath
+		stored[rendition.Name] = StoredImage{
+			Path:        filePath,
+			ContentType: "image/jpeg",
+			Size:        size,
+			Width:       img.Bounds().Dx(),
+			Height:      img.Bounds().Dy(),
+		}
+	}
+
+	return stored, nil
+}
+
+// DeleteFiles removes the given files from the uploads directory; failures are only logged
+func DeleteFiles(paths map[string]string) {
+	for _, filePath := range paths {
+		_ = DeleteFile(filePath)
+	}
+}
+
+// decodeImage checks the image format by its magic bytes and dimensions, then decodes
+// it with the EXIF orientation applied
+func decodeImage(data []byte) (image.Image, error) {
+	contentType := http.DetectContentType(data)
+	if !acceptedImageTypes[contentType] {
+		return nil, ErrInvalidImage
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	if contentType == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	return img, nil
+}
+
+// fitImage scales the image down to fit in a maxSize x maxSize square on a white
+// background; smaller images keep their size
+func fitImage(img image.Image, maxSize int) *image.RGBA {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxSize || height > maxSize {
+		if width >= height {
+			height = max(1, height*maxSize/width)
+			width = maxSize
+		} else {
+			width = max(1, width*maxSize/height)
+			height = maxSize
+		}
+	}
+
+	// JPEG has no transparency, so transparent areas become white
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+	return dst
+}
+
+// saveJPEG encodes the image as JPEG and stores it, returning the file size; the encoder
+// writes no metadata
+func saveJPEG(filePath string, img image.Image) (int64, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		log.Printf("Error encoding image %s: %v", filePath, err)
+		return 0, fmt.Errorf("error encoding image: %w", err)
+	}
+
+	size := int64(buf.Len())
+	return size, storage.Default.Save(filePath, &buf, size, "image/jpeg")
+}
+
+// jpegOrientation reads the EXIF orientation (1-8) of a JPEG image, returning 1 if it has none
+func jpegOrientation(data []byte) int {
+	// Walk the JPEG segments looking for the APP1 Exif segment
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xDA || length < 2 || i+2+length > len(data) {
+			// Start of scan: no more metadata
+			break
+		}
+
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation reads the orientation tag from the first IFD of TIFF-formatted EXIF data
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			break
+		}
+	}
+	return 1
+}
+
+// applyOrientation rotates and flips the image so that it displays upright
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	width, height := src.Rect.Dx(), src.Rect.Dy()
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		// Orientations 5-8 turn the image by 90 degrees
+		dstWidth, dstHeight = height, width
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+			// Source pixel shown at (x, y) after the transformation
+			var sx, sy int
+			switch orientation {
+			case 2: // Mirrored horizontally
+				sx, sy = width-1-x, y
+			case 3: // Rotated 180
+				sx, sy = width-1-x, height-1-y
+			case 4: // Mirrored vertically
+				sx, sy = x, height-1-y
+			case 5: // Transposed
+				sx, sy = y, x
+			case 6: // Rotated 90 clockwise
+				sx, sy = y, height-1-x
+			case 7: // Transversed
+				sx, sy = width-1-y, height-1-x
+			case 8: // Rotated 90 counterclockwise
+				sx, sy = width-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+
+	return dst
+}
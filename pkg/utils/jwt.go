@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"FurniSwap/pkg/config"
 	"errors"
 	"fmt"
 	"log"
@@ -12,12 +13,14 @@ import (
 
 // JWTClaims struct contains custom claims for JWT
 type JWTClaims struct {
-	UserID int `json:"user_id"`
+	UserID    int `json:"user_id"`
+	SessionID int `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken generates a new JWT token for a user
-func GenerateToken(userID int) (string, error) {
+// GenerateToken generates a new short-lived JWT access token for a user session and
+// returns it with its expiration time
+func GenerateToken(userID, sessionID int) (string, time.Time, error) {
 	// Get secret key from environment variables
 	secretKey := os.Getenv("JWT_SECRET_KEY")
 	if secretKey == "" {
@@ -25,12 +28,15 @@ func GenerateToken(userID int) (string, error) {
 		secretKey = "default_secret_key_change_in_production" // Fallback for development
 	}
 
-	// Create new claims with user ID
+	// Create new claims with user and session IDs
+	now := time.Now()
+	expiresAt := now.Add(time.Duration(config.Config.AccessTokenMinutes) * time.Minute)
 	claims := JWTClaims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // Token valid for 24 hours
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
@@ -40,14 +46,14 @@ func GenerateToken(userID int) (string, error) {
 	// Sign token with secret key
 	tokenString, err := token.SignedString([]byte(secretKey))
 	if err != nil {
-		return "", fmt.Errorf("error signing token: %w", err)
+		return "", time.Time{}, fmt.Errorf("error signing token: %w", err)
 	}
 
-	return tokenString, nil
+	return tokenString, expiresAt, nil
 }
 
-// ValidateToken validates token and returns user ID
-func ValidateToken(tokenString string) (int, error) {
+// ValidateToken validates token and returns its claims
+func ValidateToken(tokenString string) (*JWTClaims, error) {
 	// Get secret key from environment variables
 	secretKey := os.Getenv("JWT_SECRET_KEY")
 	if secretKey == "" {
@@ -65,19 +71,19 @@ func ValidateToken(tokenString string) (int, error) {
 	})
 
 	if err != nil {
-		return 0, fmt.Errorf("error parsing token: %w", err)
+		return nil, fmt.Errorf("error parsing token: %w", err)
 	}
 
 	// Check if token is valid
 	if !token.Valid {
-		return 0, errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
 	// Get claims from token
 	claims, ok := token.Claims.(*JWTClaims)
 	if !ok {
-		return 0, errors.New("unable to get claims from token")
+		return nil, errors.New("unable to get claims from token")
 	}
 
-	return claims.UserID, nil
+	return claims, nil
 }